@@ -8,6 +8,7 @@ require (
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/joho/godotenv v1.4.0
 	golang.org/x/crypto v0.14.0
+	golang.org/x/image v0.18.0
 	gorm.io/driver/postgres v1.5.2
 	gorm.io/driver/sqlite v1.6.0
 	gorm.io/gorm v1.30.0
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"snapadeal/config"
 	"snapadeal/models"
 )
@@ -38,13 +39,16 @@ func GetAdminDashboardStats(c *gin.Context) {
 	config.DB.Model(&models.Deal{}).Where("status = ?", "rejected").Count(&stats.RejectedDeals)
 
 	// Calculate revenue and sales from approved deals
-	var deals []models.Deal
-	config.DB.Where("status = ?", "approved").Find(&deals)
-	
-	for _, deal := range deals {
-		stats.TotalSales += int64(deal.SoldQuantity)
-		stats.TotalRevenue += float64(deal.SoldQuantity) * deal.DiscountPrice
+	var sales struct {
+		TotalSales   int64
+		TotalRevenue float64
 	}
+	config.DB.Model(&models.Deal{}).
+		Select("COALESCE(SUM(sold_quantity), 0) AS total_sales, COALESCE(SUM(sold_quantity * discount_price), 0) AS total_revenue").
+		Where("status = ?", "approved").
+		Scan(&sales)
+	stats.TotalSales = sales.TotalSales
+	stats.TotalRevenue = sales.TotalRevenue
 
 	// Get recent activity (last 10 deals)
 	var recentDeals []models.Deal
@@ -73,12 +77,10 @@ func GetAdminDashboardStats(c *gin.Context) {
 	})
 }
 
-// Admin - Get ALL deals (all statuses, all merchants)
-func GetAllDealsAdmin(c *gin.Context) {
-	fmt.Println("🔄 Admin: GetAllDealsAdmin called")
-
-	var deals []models.Deal
-	query := config.DB.Preload("Category").Preload("Merchant")
+// adminDealListQuery applies the filters shared by the admin deal list and
+// export
+func adminDealListQuery(c *gin.Context) *gorm.DB {
+	query := config.DB.Model(&models.Deal{})
 
 	// Filter by status if provided
 	if status := c.Query("status"); status != "" {
@@ -92,6 +94,16 @@ func GetAllDealsAdmin(c *gin.Context) {
 		fmt.Printf("🔍 Filtering by merchant: %s\n", merchantID)
 	}
 
+	return query
+}
+
+// Admin - Get ALL deals (all statuses, all merchants)
+func GetAllDealsAdmin(c *gin.Context) {
+	fmt.Println("🔄 Admin: GetAllDealsAdmin called")
+
+	var deals []models.Deal
+	query := adminDealListQuery(c).Preload("Category").Preload("Merchant")
+
 	// Pagination
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// analyticsQuery reads from, to (inclusive dates, the last 30 days by
+// default), interval, group_by and category_id from the request
+func analyticsQuery(c *gin.Context) (services.AnalyticsQuery, error) {
+	q := services.AnalyticsQuery{
+		Interval: c.DefaultQuery("interval", services.IntervalDay),
+		GroupBy:  c.Query("group_by"),
+	}
+
+	today := time.Now()
+	q.To = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	if to := c.Query("to"); to != "" {
+		date, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			return q, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", to)
+		}
+		q.To = date.AddDate(0, 0, 1)
+	}
+
+	q.From = q.To.AddDate(0, 0, -30)
+	if from := c.Query("from"); from != "" {
+		date, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			return q, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", from)
+		}
+		q.From = date
+	}
+
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		id, err := strconv.ParseUint(categoryID, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("invalid category_id %q", categoryID)
+		}
+		q.CategoryID = uint(id)
+	}
+
+	return q, nil
+}
+
+func analyticsRange(q services.AnalyticsQuery) gin.H {
+	return gin.H{
+		"from":     q.From.Format("2006-01-02"),
+		"to":       q.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"interval": q.Interval,
+		"group_by": q.GroupBy,
+	}
+}
+
+// ADMIN - Revenue, orders, units, conversion and new customers over time,
+// optionally for one merchant (merchant_id) and split by category or
+// merchant, plus new user registrations
+func GetAdminAnalytics(c *gin.Context) {
+	q, err := analyticsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if merchantID := c.Query("merchant_id"); merchantID != "" {
+		id, err := strconv.ParseUint(merchantID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant_id"})
+			return
+		}
+		q.MerchantID = uint(id)
+	}
+	if err := services.ValidateAnalyticsQuery(q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔄 Admin: GetAdminAnalytics %s to %s by %s\n", q.From.Format("2006-01-02"), q.To.Format("2006-01-02"), q.Interval)
+
+	series, totals, err := services.TransactionAnalytics(q)
+	if err != nil {
+		fmt.Printf("❌ Analytics query failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics"})
+		return
+	}
+
+	newUsers, err := services.NewUserAnalytics(q)
+	if err != nil {
+		fmt.Printf("❌ New user analytics failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"range":     analyticsRange(q),
+		"series":    series,
+		"totals":    totals,
+		"new_users": newUsers,
+	})
+}
+
+// MERCHANT - The same metrics for the merchant's own deals, optionally split
+// by category
+func GetMerchantAnalytics(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	q, err := analyticsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.GroupBy == services.GroupByMerchant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be category"})
+		return
+	}
+	q.MerchantID = member.MerchantID()
+	if err := services.ValidateAnalyticsQuery(q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔄 GetMerchantAnalytics called for merchant %d\n", q.MerchantID)
+
+	series, totals, err := services.TransactionAnalytics(q)
+	if err != nil {
+		fmt.Printf("❌ Analytics query failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"range":  analyticsRange(q),
+		"series": series,
+		"totals": totals,
+	})
+}
+
+// funnelQuery reads the same date range and category filter as the
+// analytics endpoints, plus limit for the per-deal list
+func funnelQuery(c *gin.Context) (services.FunnelQuery, error) {
+	q, err := analyticsQuery(c)
+	if err != nil {
+		return services.FunnelQuery{}, err
+	}
+	if !q.To.After(q.From) {
+		return services.FunnelQuery{}, fmt.Errorf("to must be after from")
+	}
+	days := int(q.To.Sub(q.From).Hours()/24 + 0.5)
+	if days > services.MaxAnalyticsBuckets {
+		return services.FunnelQuery{}, fmt.Errorf("range too long, at most %d days", services.MaxAnalyticsBuckets)
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	return services.FunnelQuery{
+		From:       q.From,
+		To:         q.To.AddDate(0, 0, -1),
+		CategoryID: q.CategoryID,
+		Limit:      limit,
+	}, nil
+}
+
+func respondFunnel(c *gin.Context, q services.FunnelQuery) {
+	// Include events still waiting in the buffer
+	if err := services.Funnel.Flush(); err != nil {
+		fmt.Printf("⚠️ Funnel flush failed: %v\n", err)
+	}
+
+	deals, daily, totals, err := services.DealFunnels(q)
+	if err != nil {
+		fmt.Printf("❌ Funnel query failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute funnel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"range": gin.H{
+			"from": q.From.Format("2006-01-02"),
+			"to":   q.To.Format("2006-01-02"),
+		},
+		"totals": totals,
+		"daily":  daily,
+		"deals":  deals,
+	})
+}
+
+// ADMIN - Impressions, views, checkout starts and purchases across all
+// deals, optionally for one merchant_id or category_id
+func GetAdminFunnel(c *gin.Context) {
+	q, err := funnelQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if merchantID := c.Query("merchant_id"); merchantID != "" {
+		id, err := strconv.ParseUint(merchantID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant_id"})
+			return
+		}
+		q.MerchantID = uint(id)
+	}
+
+	fmt.Println("🔄 Admin: GetAdminFunnel called")
+	respondFunnel(c, q)
+}
+
+// MERCHANT - The funnel for the merchant's own deals
+func GetMerchantFunnel(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	q, err := funnelQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.MerchantID = member.MerchantID()
+
+	fmt.Printf("🔄 GetMerchantFunnel called for merchant %d\n", q.MerchantID)
+	respondFunnel(c, q)
+}
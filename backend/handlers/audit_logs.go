@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+// auditLogQuery applies the filters shared by the audit log list and export.
+// from and to accept a date (to is inclusive) or an RFC 3339 timestamp.
+func auditLogQuery(c *gin.Context) (*gorm.DB, error) {
+	query := config.DB.Model(&models.AuditLog{})
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+
+	switch c.Query("outcome") {
+	case "success":
+		query = query.Where("status_code < ?", 400)
+	case "failed":
+		query = query.Where("status_code >= ?", 400)
+	}
+
+	return dateRangeFilter(c, query, "created_at")
+}
+
+// parseDateFilter reads a date, as the start of that day or with endOfDay
+// the start of the next, or an RFC 3339 timestamp
+func parseDateFilter(value string, endOfDay bool) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC 3339", value)
+	}
+	return timestamp, nil
+}
+
+func preloadAuditActor(db *gorm.DB) *gorm.DB {
+	return db.Select("id, first_name, last_name, email, role")
+}
+
+// ADMIN - List audit log entries, newest first
+func GetAuditLogs(c *gin.Context) {
+	query, err := auditLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var logs []models.AuditLog
+	if err := query.Preload("Actor", preloadAuditActor).
+		Order("created_at DESC, id DESC").
+		Offset(offset).Limit(limit).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"audit_logs": logs,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ADMIN - Get a single audit log entry with its snapshots
+func GetAuditLog(c *gin.Context) {
+	var entry models.AuditLog
+	if err := config.DB.Preload("Actor", preloadAuditActor).First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audit log entry not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit_log": entry})
+}
+
+// ADMIN - Download the filtered audit log as CSV or XLSX, oldest first. Rows
+// are streamed in batches so large ranges don't have to fit in memory.
+func ExportAuditLogs(c *gin.Context) {
+	query, err := auditLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔄 ExportAuditLogs called by admin %d\n", c.GetUint("user_id"))
+
+	w, ok := startExport(c, "audit-log", []string{
+		"id", "created_at", "actor_id", "actor_email", "actor_role", "action",
+		"entity_type", "entity_id", "status_code", "method", "path",
+		"ip_address", "user_agent", "before", "after",
+	})
+	if !ok {
+		return
+	}
+
+	var logs []models.AuditLog
+	err = query.Preload("Actor", preloadAuditActor).
+		FindInBatches(&logs, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, entry := range logs {
+				actorEmail := ""
+				if entry.Actor != nil {
+					actorEmail = entry.Actor.Email
+				}
+				w.Row(entry.ID, entry.CreatedAt, entry.ActorID, actorEmail, entry.ActorRole, entry.Action,
+					entry.EntityType, entry.EntityID, entry.StatusCode, entry.Method, entry.Path,
+					entry.IPAddress, entry.UserAgent, string(entry.Before), string(entry.After))
+			}
+			return w.Flush()
+		}).Error
+	w.Close("audit-log", err)
+}
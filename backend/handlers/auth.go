@@ -20,7 +20,8 @@ type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
 	Phone     string `json:"phone" binding:"required"`
 	Password  string `json:"password" binding:"required,min=6"`
-	Role      string `json:"role"` // customer, merchant, admin
+	Role      string `json:"role"`   // customer, merchant (admins join by invitation)
+	Locale    string `json:"locale"` // email language, defaults to en
 }
 
 type LoginRequest struct {
@@ -71,10 +72,26 @@ func Register(c *gin.Context) {
 		req.Role = "customer"
 	}
 
-	// Validate role - NOW INCLUDES ADMIN
-	if req.Role != "customer" && req.Role != "merchant" && req.Role != "admin" {
+	// Admins can only be created through an invitation from an existing admin
+	if req.Role == "admin" {
+		fmt.Println("❌ Attempted self-registration as admin")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin accounts can only be created by invitation"})
+		return
+	}
+
+	// Validate role
+	if req.Role != "customer" && req.Role != "merchant" {
 		fmt.Printf("❌ Invalid role: %s\n", req.Role)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'customer', 'merchant', or 'admin'"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'customer' or 'merchant'"})
+		return
+	}
+
+	// Email language
+	if req.Locale == "" {
+		req.Locale = services.DefaultEmailLocale
+	}
+	if !services.IsEmailLocale(req.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale", "locales": services.EmailLocales})
 		return
 	}
 
@@ -86,6 +103,7 @@ func Register(c *gin.Context) {
 		Phone:      req.Phone,
 		Password:   req.Password,
 		Role:       req.Role,
+		Locale:     req.Locale,
 		Status:     "pending", // User must verify email first
 		IsVerified: false,
 	}
@@ -112,7 +130,7 @@ func Register(c *gin.Context) {
 	fmt.Printf("✅ User created with ID: %d, Role: %s\n", user.ID, user.Role)
 
 	// Send OTP email
-	emailService := services.NewEmailService()
+	emailService := services.NewEmailService().WithLocale(user.Locale)
 	if err := emailService.SendOTPEmail(user.Email, user.FirstName, user.OTPCode); err != nil {
 		fmt.Printf("⚠️ Failed to send OTP email: %v\n", err)
 		// Don't fail registration if email fails
@@ -123,8 +141,6 @@ func Register(c *gin.Context) {
 	// Custom message based on role
 	var message string
 	switch req.Role {
-	case "admin":
-		message = "Admin registration successful! Please check your email for the OTP code to verify your account."
 	case "merchant":
 		message = "Merchant registration successful! Please check your email for the OTP code to verify your account."
 	default:
@@ -182,13 +198,13 @@ func VerifyOTP(c *gin.Context) {
 	fmt.Printf("✅ User verified successfully: %s (Role: %s)\n", user.Email, user.Role)
 
 	// Send welcome email
-	emailService := services.NewEmailService()
+	emailService := services.NewEmailService().WithLocale(user.Locale)
 	if err := emailService.SendWelcomeEmail(user.Email, user.FirstName, user.Role); err != nil {
 		fmt.Printf("⚠️ Failed to send welcome email: %v\n", err)
 	}
 
 	// Generate JWT token
-	token, err := generateJWT(user.ID, user.Role)
+	token, err := generateJWT(c, user.ID, user.Role)
 	if err != nil {
 		fmt.Printf("❌ Token generation error: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
@@ -252,7 +268,7 @@ func ResendOTP(c *gin.Context) {
 	}
 
 	// Send OTP email
-	emailService := services.NewEmailService()
+	emailService := services.NewEmailService().WithLocale(user.Locale)
 	if err := emailService.SendOTPEmail(user.Email, user.FirstName, user.OTPCode); err != nil {
 		fmt.Printf("❌ Failed to send OTP email: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP email"})
@@ -328,7 +344,7 @@ func Login(c *gin.Context) {
 	config.DB.Save(&user)
 
 	// Generate JWT token
-	token, err := generateJWT(user.ID, user.Role)
+	token, err := generateJWT(c, user.ID, user.Role)
 	if err != nil {
 		fmt.Printf("❌ Token generation error: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
@@ -378,6 +394,20 @@ func ForgotPassword(c *gin.Context) {
 		return
 	}
 
+	if err := sendPasswordResetLink(user); err != nil {
+		fmt.Printf("❌ Failed to send reset email: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
+		return
+	}
+
+	fmt.Printf("📧 Password reset email sent to: %s\n", user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset link sent to your email"})
+}
+
+// sendPasswordResetLink emails the user a link to the reset page of their
+// app. The user must have a fresh reset token.
+func sendPasswordResetLink(user models.User) error {
 	// Determine the correct frontend URL based on user role
 	var frontendURL string
 	switch user.Role {
@@ -401,16 +431,8 @@ func ForgotPassword(c *gin.Context) {
 	resetURL := fmt.Sprintf("%s/reset-password?token=%s", frontendURL, user.ResetToken)
 
 	// Send reset email
-	emailService := services.NewEmailService()
-	if err := emailService.SendPasswordResetEmail(user.Email, user.FirstName, resetURL); err != nil {
-		fmt.Printf("❌ Failed to send reset email: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
-		return
-	}
-
-	fmt.Printf("📧 Password reset email sent to: %s\n", user.Email)
-
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset link sent to your email"})
+	emailService := services.NewEmailService().WithLocale(user.Locale)
+	return emailService.SendPasswordResetEmail(user.Email, user.FirstName, resetURL)
 }
 
 func ResetPassword(c *gin.Context) {
@@ -459,14 +481,32 @@ func ResetPassword(c *gin.Context) {
 
 	fmt.Printf("✅ Password reset successful for user: %s\n", user.Email)
 
+	// Log out everywhere the old password was used
+	if _, err := models.RevokeUserSessions(user.ID, "password reset"); err != nil {
+		fmt.Printf("⚠️ Failed to end sessions after password reset: %v\n", err)
+	}
+
+	// Security alert - mandatory, cannot be switched off
+	if _, err := services.Notifications.Notify(services.PasswordChangedEvent(user)); err != nil {
+		fmt.Printf("⚠️ Failed to send password change alert: %v\n", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successful"})
 }
 
-func generateJWT(userID uint, role string) (string, error) {
+// generateJWT starts a session for the user and returns its token. The
+// session ID lets admins revoke the token before it expires.
+func generateJWT(c *gin.Context, userID uint, role string) (string, error) {
+	session, err := models.CreateUserSession(userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
+		"sid":     session.TokenID,
+		"exp":     session.ExpiresAt.Unix(), // 7 days
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -503,6 +543,7 @@ func UpdateProfile(c *gin.Context) {
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
 		Phone     string `json:"phone"`
+		Locale    string `json:"locale"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -520,6 +561,13 @@ func UpdateProfile(c *gin.Context) {
 	if req.Phone != "" {
 		user.Phone = req.Phone
 	}
+	if req.Locale != "" {
+		if !services.IsEmailLocale(req.Locale) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale", "locales": services.EmailLocales})
+			return
+		}
+		user.Locale = req.Locale
+	}
 
 	if err := config.DB.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
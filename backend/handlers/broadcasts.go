@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+type BroadcastRequest struct {
+	Title       string                  `json:"title" binding:"required"`
+	Message     string                  `json:"message" binding:"required"`
+	Segment     models.BroadcastSegment `json:"segment"`
+	UserRole    string                  `json:"user_role"`    // shorthand for segment.role
+	UserIDs     []uint                  `json:"user_ids"`     // shorthand for segment.user_ids
+	Channels    []string                `json:"channels"`     // in_app (default), email
+	ScheduledAt *time.Time              `json:"scheduled_at"` // send now when empty
+}
+
+// validateBroadcastSegment checks the segment filters, returning a message
+// for the admin when one is invalid
+func validateBroadcastSegment(segment models.BroadcastSegment) string {
+	switch segment.Role {
+	case "", "all", "customer", "merchant", "admin":
+	default:
+		return "Invalid role: " + segment.Role
+	}
+	if segment.InactiveDays < 0 {
+		return "inactive_days must not be negative"
+	}
+	if segment.PurchasedCategoryID != nil {
+		var category models.Category
+		if err := config.DB.First(&category, *segment.PurchasedCategoryID).Error; err != nil {
+			return "Category not found"
+		}
+	}
+	return ""
+}
+
+// ADMIN - Send a broadcast to a segment of users now or at scheduled_at
+func BroadcastNotification(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+	fmt.Printf("🔄 BroadcastNotification called by admin %d\n", adminID)
+
+	var req BroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	segment := req.Segment
+	if segment.Role == "" {
+		segment.Role = req.UserRole
+	}
+	if len(segment.UserIDs) == 0 {
+		segment.UserIDs = req.UserIDs
+	}
+	if message := validateBroadcastSegment(segment); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	channels := models.StringArray{}
+	for _, channel := range req.Channels {
+		if channel != models.NotificationChannelInApp && channel != models.NotificationChannelEmail {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Broadcasts can be sent in_app or by email, not " + channel})
+			return
+		}
+		if !stringInSlice(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		channels = models.StringArray{models.NotificationChannelInApp}
+	}
+
+	scheduledAt := time.Now()
+	scheduled := req.ScheduledAt != nil && req.ScheduledAt.After(scheduledAt)
+	if scheduled {
+		scheduledAt = *req.ScheduledAt
+	}
+
+	broadcast := models.Broadcast{
+		Title:       req.Title,
+		Message:     req.Message,
+		Segment:     segment,
+		Channels:    channels,
+		Status:      models.BroadcastStatusScheduled,
+		ScheduledAt: scheduledAt,
+		CreatedByID: adminID,
+	}
+	if err := config.DB.Create(&broadcast).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create broadcast"})
+		return
+	}
+
+	var audience int64
+	segment.Users(config.DB).Count(&audience)
+
+	message := "Broadcast scheduled"
+	if !scheduled {
+		message = "Broadcast is being sent"
+		services.Broadcasts.Wake()
+	}
+
+	fmt.Printf("✅ Broadcast %d created for %d users, due %s\n", broadcast.ID, audience, scheduledAt.Format(time.RFC3339))
+	middleware.RecordAudit(c, "broadcast.create", "broadcast", broadcast.ID, nil, broadcast)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   message,
+		"broadcast": broadcast,
+		"audience":  audience,
+	})
+}
+
+// ADMIN - Count the users a segment would reach, before sending
+func PreviewBroadcastAudience(c *gin.Context) {
+	var segment models.BroadcastSegment
+	if err := c.ShouldBindJSON(&segment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if message := validateBroadcastSegment(segment); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	var audience int64
+	if err := segment.Users(config.DB).Count(&audience).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count audience"})
+		return
+	}
+
+	var byRole []struct {
+		Role  string `json:"role"`
+		Count int64  `json:"count"`
+	}
+	segment.Users(config.DB).Select("role, COUNT(*) AS count").Group("role").Scan(&byRole)
+
+	c.JSON(http.StatusOK, gin.H{
+		"audience": audience,
+		"by_role":  byRole,
+	})
+}
+
+// ADMIN - List broadcasts, newest first, with their delivery and read stats
+func GetBroadcasts(c *gin.Context) {
+	query := config.DB.Model(&models.Broadcast{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var broadcasts []models.Broadcast
+	if err := query.Preload("CreatedBy").
+		Order("created_at DESC").
+		Offset(offset).Limit(limit).
+		Find(&broadcasts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch broadcasts"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(broadcasts))
+	for _, broadcast := range broadcasts {
+		results = append(results, gin.H{
+			"broadcast": broadcast,
+			"stats":     broadcast.Stats(config.DB),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"broadcasts": results,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ADMIN - One broadcast with its stats
+func GetBroadcast(c *gin.Context) {
+	var broadcast models.Broadcast
+	if err := config.DB.Preload("CreatedBy").First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"broadcast": broadcast,
+		"stats":     broadcast.Stats(config.DB),
+	})
+}
+
+// ADMIN - Cancel a broadcast that hasn't started sending
+func CancelBroadcast(c *gin.Context) {
+	var broadcast models.Broadcast
+	if err := config.DB.First(&broadcast, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
+		return
+	}
+
+	// Conditional so a broadcast the scheduler has just claimed isn't cancelled
+	result := config.DB.Model(&models.Broadcast{}).
+		Where("id = ? AND status = ?", broadcast.ID, models.BroadcastStatusScheduled).
+		Update("status", models.BroadcastStatusCancelled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel broadcast"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only scheduled broadcasts can be cancelled"})
+		return
+	}
+
+	fmt.Printf("✅ Broadcast %d cancelled by admin %d\n", broadcast.ID, c.GetUint("user_id"))
+	after := broadcast
+	after.Status = models.BroadcastStatusCancelled
+	middleware.RecordAudit(c, "broadcast.cancel", "broadcast", broadcast.ID, broadcast, after)
+	c.JSON(http.StatusOK, gin.H{"message": "Broadcast cancelled"})
+}
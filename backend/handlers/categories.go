@@ -1,13 +1,37 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"snapadeal/config"
+	"snapadeal/middleware"
 	"snapadeal/models"
+	"snapadeal/services"
 )
 
+type CategoryRequest struct {
+	Name        *string `json:"name"`
+	Slug        *string `json:"slug"`
+	Description *string `json:"description"`
+	IconURL     *string `json:"icon_url"`
+	ParentID    *uint   `json:"parent_id"` // 0 moves the category to the top level
+	SortOrder   *int    `json:"sort_order"`
+}
+
+type CategoryOrderRequest struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+type DeactivateCategoryRequest struct {
+	ReassignTo *uint `json:"reassign_to"` // move the category's deals here first
+}
+
+// PUBLIC - Active categories as a tree, with live deal counts
 func GetCategories(c *gin.Context) {
 	// Explicit CORS headers for this endpoint
 	c.Header("Access-Control-Allow-Origin", "*")
@@ -15,9 +39,9 @@ func GetCategories(c *gin.Context) {
 	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With")
 
 	var categories []models.Category
-	
+
 	// Fetch active categories with better error handling
-	if err := config.DB.Where("is_active = ?", true).Order("name ASC").Find(&categories).Error; err != nil {
+	if err := config.DB.Where("is_active = ?", true).Find(&categories).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch categories",
 			"message": "Database error occurred",
@@ -28,8 +52,381 @@ func GetCategories(c *gin.Context) {
 
 	// Return categories with additional metadata
 	c.JSON(http.StatusOK, gin.H{
-		"categories": categories,
+		"categories": models.BuildCategoryTree(categories, models.LiveDealCounts()),
 		"count": len(categories),
 		"message": "Categories fetched successfully",
 	})
 }
+
+// ADMIN - Every category, including inactive ones, as a tree
+func GetAdminCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := config.DB.Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": models.BuildCategoryTree(categories, models.LiveDealCounts()),
+		"count":      len(categories),
+	})
+}
+
+// applyCategoryRequest copies the fields present in req onto category
+func applyCategoryRequest(category *models.Category, req CategoryRequest) string {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return "Category name is required"
+		}
+		var count int64
+		config.DB.Model(&models.Category{}).Unscoped().Where("name = ? AND id <> ?", name, category.ID).Count(&count)
+		if count > 0 {
+			return "A category with this name already exists"
+		}
+		category.Name = name
+	}
+
+	if req.Slug != nil {
+		slug := strings.Trim(strings.ToLower(strings.TrimSpace(*req.Slug)), "-")
+		if slug == "" || strings.Trim(slug, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return "Slugs may only contain lowercase letters, numbers and hyphens"
+		}
+		var count int64
+		config.DB.Model(&models.Category{}).Unscoped().Where("slug = ? AND id <> ?", slug, category.ID).Count(&count)
+		if count > 0 {
+			return "A category with this slug already exists"
+		}
+		category.Slug = slug
+	}
+
+	if req.Description != nil {
+		category.Description = *req.Description
+	}
+	if req.IconURL != nil {
+		category.IconURL = *req.IconURL
+	}
+	if req.SortOrder != nil {
+		category.SortOrder = *req.SortOrder
+	}
+
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if err := models.CheckCategoryParent(category.ID, *req.ParentID); err != nil {
+				if errors.Is(err, models.ErrCategoryCycle) {
+					return err.Error()
+				}
+				return "Parent category not found"
+			}
+			parentID := *req.ParentID
+			category.ParentID = &parentID
+		}
+	}
+
+	return ""
+}
+
+// ADMIN - Create a category, optionally under a parent
+func CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+		return
+	}
+
+	category := models.Category{IsActive: true}
+	if message := applyCategoryRequest(&category, req); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	// New categories go last among their siblings unless told otherwise
+	if req.SortOrder == nil {
+		var last struct{ Max int }
+		siblings := config.DB.Model(&models.Category{})
+		if category.ParentID == nil {
+			siblings = siblings.Where("parent_id IS NULL")
+		} else {
+			siblings = siblings.Where("parent_id = ?", *category.ParentID)
+		}
+		siblings.Select("COALESCE(MAX(sort_order), 0) AS max").Scan(&last)
+		category.SortOrder = last.Max + 1
+	}
+
+	if err := config.DB.Create(&category).Error; err != nil {
+		fmt.Printf("❌ Failed to create category: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	fmt.Printf("✅ Category created: %s (%s)\n", category.Name, category.Slug)
+	middleware.RecordAudit(c, "category.create", "category", category.ID, nil, category)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Category created successfully",
+		"category": category,
+	})
+}
+
+// ADMIN - Rename, move or re-slug a category. Only the fields sent change.
+func UpdateCategory(c *gin.Context) {
+	var category models.Category
+	if err := config.DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := category
+	if message := applyCategoryRequest(&category, req); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	if err := config.DB.Save(&category).Error; err != nil {
+		fmt.Printf("❌ Failed to update category: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	middleware.RecordAudit(c, "category.update", "category", category.ID, before, category)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Category updated successfully",
+		"category": category,
+	})
+}
+
+// ADMIN - Set the display order of categories: each listed category gets
+// its position in ids as its sort order
+func ReorderCategories(c *gin.Context) {
+	var req CategoryOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.Category{}).Where("id IN ?", req.IDs).Count(&count)
+	if int(count) != len(req.IDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or duplicate category IDs"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range req.IDs {
+			if err := tx.Model(&models.Category{}).Where("id = ?", id).Update("sort_order", position+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder categories"})
+		return
+	}
+
+	middleware.RecordAudit(c, "category.reorder", "category", "", nil, req.IDs)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Categories reordered successfully"})
+}
+
+// ADMIN - Upload an image to use as the category icon
+func UploadCategoryIcon(c *gin.Context) {
+	var category models.Category
+	if err := config.DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxImageUploadSize+(1<<20))
+	if err := c.Request.ParseMultipartForm(10 << 20); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large", "code": "file_too_large"})
+		return
+	}
+
+	_, header, err := c.Request.FormFile("icon")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No icon uploaded"})
+		return
+	}
+
+	processed, err := processImageUpload(header)
+	if err != nil {
+		respondImageUploadError(c, header.Filename, err)
+		return
+	}
+
+	asset, _, err := services.StoreImage(c.GetUint("user_id"), processed)
+	if err != nil {
+		fmt.Printf("❌ Failed to save category icon: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save icon"})
+		return
+	}
+
+	// The previous icon, if uploaded, is left for the media cleanup job
+	before := category
+	category.IconURL = asset.URL()
+	if err := config.DB.Model(&category).Update("icon_url", category.IconURL).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	middleware.RecordAudit(c, "category.icon_upload", "category", category.ID, before, category)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Category icon uploaded successfully",
+		"category": category,
+		"icon":     mediaAssetResponse(*asset),
+	})
+}
+
+// ADMIN - Hide a category from merchants and customers. A category with
+// active subcategories can't be deactivated, and one that deals still use
+// needs reassign_to so those deals move to another active category first.
+func DeactivateCategory(c *gin.Context) {
+	var category models.Category
+	if err := config.DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req DeactivateCategoryRequest
+	c.ShouldBindJSON(&req)
+
+	if !category.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category is already inactive"})
+		return
+	}
+
+	var subcategories []models.Category
+	config.DB.Where("parent_id = ? AND is_active = ?", category.ID, true).Find(&subcategories)
+	if len(subcategories) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Deactivate or move this category's subcategories first",
+			"subcategories": subcategories,
+		})
+		return
+	}
+
+	var usage []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	config.DB.Model(&models.Deal{}).
+		Select("status, COUNT(*) AS count").
+		Where("category_id = ?", category.ID).
+		Group("status").
+		Scan(&usage)
+
+	var dealCount int64
+	for _, row := range usage {
+		dealCount += row.Count
+	}
+
+	var target models.Category
+	if dealCount > 0 {
+		if req.ReassignTo == nil {
+			var deals []struct {
+				ID         uint   `json:"id"`
+				Title      string `json:"title"`
+				Status     string `json:"status"`
+				MerchantID uint   `json:"merchant_id"`
+			}
+			config.DB.Model(&models.Deal{}).
+				Select("id, title, status, merchant_id").
+				Where("category_id = ?", category.ID).
+				Order("created_at DESC").Limit(20).
+				Scan(&deals)
+
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "Deals still use this category. Send reassign_to with another category to move them",
+				"deal_count": dealCount,
+				"by_status":  usage,
+				"deals":      deals,
+			})
+			return
+		}
+
+		if *req.ReassignTo == category.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Deals must be moved to a different category"})
+			return
+		}
+		if err := config.DB.Where("id = ? AND is_active = ?", *req.ReassignTo, true).First(&target).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reassign_to must be an active category"})
+			return
+		}
+	}
+
+	var moved int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if dealCount > 0 {
+			result := tx.Model(&models.Deal{}).
+				Where("category_id = ?", category.ID).
+				Update("category_id", target.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			moved = result.RowsAffected
+		}
+		return tx.Model(&category).Update("is_active", false).Error
+	})
+	if err != nil {
+		fmt.Printf("❌ Failed to deactivate category %d: %v\n", category.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate category"})
+		return
+	}
+
+	fmt.Printf("✅ Category %s deactivated, %d deals moved\n", category.Name, moved)
+	after := gin.H{"is_active": false, "deals_moved": moved}
+	if moved > 0 {
+		after["reassigned_to"] = target.ID
+	}
+	middleware.RecordAudit(c, "category.deactivate", "category", category.ID, gin.H{"is_active": true, "deal_count": dealCount}, after)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Category deactivated successfully",
+		"deals_moved": moved,
+	})
+}
+
+// ADMIN - Make an inactive category available again
+func ActivateCategory(c *gin.Context) {
+	var category models.Category
+	if err := config.DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if category.ParentID != nil {
+		var parent models.Category
+		if err := config.DB.First(&parent, *category.ParentID).Error; err != nil || !parent.IsActive {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Activate the parent category first"})
+			return
+		}
+	}
+
+	if err := config.DB.Model(&category).Update("is_active", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate category"})
+		return
+	}
+	category.IsActive = true
+
+	middleware.RecordAudit(c, "category.activate", "category", category.ID, gin.H{"is_active": false}, gin.H{"is_active": true})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Category activated successfully",
+		"category": category,
+	})
+}
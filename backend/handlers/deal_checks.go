@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+type UpdateDealCheckRuleRequest struct {
+	Severity  *string  `json:"severity"`
+	Threshold *float64 `json:"threshold"`
+}
+
+type CreateBlockedTermRequest struct {
+	Term     string `json:"term" binding:"required"`
+	Severity string `json:"severity"` // warning or block, block by default
+}
+
+// ADMIN - Automated deal check rules and the blocked terms list
+func GetDealCheckRules(c *gin.Context) {
+	var rules []models.DealCheckRule
+	if err := config.DB.Order("id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deal check rules"})
+		return
+	}
+
+	var terms []models.BlockedTerm
+	config.DB.Order("term ASC").Find(&terms)
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":         rules,
+		"blocked_terms": terms,
+	})
+}
+
+// ADMIN - Change a rule's severity (off, warning or block) or threshold.
+// Pending deals aren't rechecked until POST /admin/deal-checks/run.
+func UpdateDealCheckRule(c *gin.Context) {
+	var rule models.DealCheckRule
+	if err := config.DB.Where("key = ?", c.Param("key")).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deal check rule not found"})
+		return
+	}
+
+	var req UpdateDealCheckRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := rule
+	if req.Severity != nil {
+		if !models.ValidCheckSeverity(*req.Severity) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Severity must be off, warning or block"})
+			return
+		}
+		rule.Severity = *req.Severity
+	}
+	if req.Threshold != nil {
+		if *req.Threshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Threshold can't be negative"})
+			return
+		}
+		rule.Threshold = *req.Threshold
+	}
+
+	if err := config.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deal check rule"})
+		return
+	}
+
+	middleware.RecordAudit(c, "deal_check.update", "deal_check_rule", rule.Key, before, rule)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deal check rule updated",
+		"rule":    rule,
+	})
+}
+
+// ADMIN - Run the checks again on every pending deal
+func RunDealChecks(c *gin.Context) {
+	fmt.Printf("🔄 Admin %d: rechecking pending deals\n", c.GetUint("user_id"))
+
+	checked, blocked, err := services.RecheckPendingDeals()
+	if err != nil {
+		fmt.Printf("❌ Deal recheck failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recheck deals"})
+		return
+	}
+
+	fmt.Printf("✅ Rechecked %d pending deals, %d blocked\n", checked, blocked)
+	middleware.RecordAudit(c, "deal_check.run", "deal_check_rule", "", nil, gin.H{"checked": checked, "blocked": blocked})
+
+	c.JSON(http.StatusOK, gin.H{
+		"checked": checked,
+		"blocked": blocked,
+	})
+}
+
+// ADMIN - Add a term that deals may not contain
+func CreateBlockedTerm(c *gin.Context) {
+	var req CreateBlockedTermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	term := strings.ToLower(strings.Join(strings.Fields(req.Term), " "))
+	if term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Term is required"})
+		return
+	}
+	if req.Severity == "" {
+		req.Severity = models.CheckSeverityBlock
+	}
+	if req.Severity != models.CheckSeverityWarning && req.Severity != models.CheckSeverityBlock {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Severity must be warning or block"})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.BlockedTerm{}).Where("term = ?", term).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This term is already blocked"})
+		return
+	}
+
+	blocked := models.BlockedTerm{
+		Term:        term,
+		Severity:    req.Severity,
+		CreatedByID: c.GetUint("user_id"),
+	}
+	if err := config.DB.Create(&blocked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add blocked term"})
+		return
+	}
+
+	middleware.RecordAudit(c, "blocked_term.create", "blocked_term", blocked.ID, nil, blocked)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Term blocked",
+		"blocked_term": blocked,
+	})
+}
+
+// ADMIN - Remove a term from the blocked list
+func DeleteBlockedTerm(c *gin.Context) {
+	var blocked models.BlockedTerm
+	if err := config.DB.First(&blocked, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blocked term not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&blocked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove blocked term"})
+		return
+	}
+
+	middleware.RecordAudit(c, "blocked_term.delete", "blocked_term", blocked.ID, blocked, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Term unblocked"})
+}
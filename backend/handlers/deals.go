@@ -7,8 +7,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"snapadeal/config"
+	"snapadeal/middleware"
 	"snapadeal/models"
+	"snapadeal/services"
 )
 
 type CreateDealRequest struct {
@@ -27,6 +30,53 @@ type CreateDealRequest struct {
 	FinePrints       string    `json:"fine_prints"`
 }
 
+// PublicDeal is the shape of a deal in public responses. The Merchant field
+// shadows Deal.Merchant so the merchant's personal details are never exposed.
+type PublicDeal struct {
+	models.Deal
+	Merchant models.PublicMerchant `json:"merchant"`
+
+	// Moderation details stay internal. Each nil field below shadows the Deal
+	// field with the same JSON name, so it's left out.
+	ClaimedByID     *struct{} `json:"claimed_by_id,omitempty"`
+	ClaimedBy       *struct{} `json:"claimed_by,omitempty"`
+	ClaimedAt       *struct{} `json:"claimed_at,omitempty"`
+	ReviewedByID    *struct{} `json:"reviewed_by_id,omitempty"`
+	ReviewedAt      *struct{} `json:"reviewed_at,omitempty"`
+	RejectionReason *struct{} `json:"rejection_reason,omitempty"`
+	CheckFindings   *struct{} `json:"check_findings,omitempty"`
+	CheckBlocked    *struct{} `json:"check_blocked,omitempty"`
+	CheckedAt       *struct{} `json:"checked_at,omitempty"`
+}
+
+// recordImpressions counts the deals as shown in a listing
+func recordImpressions(deals []models.Deal) {
+	ids := make([]uint, 0, len(deals))
+	for _, deal := range deals {
+		ids = append(ids, deal.ID)
+	}
+	services.Funnel.Record(models.FunnelImpression, ids...)
+}
+
+func toPublicDeals(deals []models.Deal) []PublicDeal {
+	var merchantIDs []uint
+	seen := make(map[uint]bool)
+	for _, deal := range deals {
+		if !seen[deal.MerchantID] {
+			seen[deal.MerchantID] = true
+			merchantIDs = append(merchantIDs, deal.MerchantID)
+		}
+	}
+
+	merchants := models.GetPublicMerchants(merchantIDs)
+
+	publicDeals := make([]PublicDeal, 0, len(deals))
+	for _, deal := range deals {
+		publicDeals = append(publicDeals, PublicDeal{Deal: deal, Merchant: merchants[deal.MerchantID]})
+	}
+	return publicDeals
+}
+
 func CreateDeal(c *gin.Context) {
 	fmt.Println("🔄 CreateDeal handler called")
 	
@@ -74,9 +124,9 @@ func CreateDeal(c *gin.Context) {
 		req.MaxQuantity = 100
 	}
 
-	// Check if category exists
+	// Check if category exists and is still offered
 	var category models.Category
-	if err := config.DB.First(&category, req.CategoryID).Error; err != nil {
+	if err := config.DB.Where("is_active = ?", true).First(&category, req.CategoryID).Error; err != nil {
 		fmt.Printf("❌ Category not found: %v\n", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category selected"})
 		return
@@ -98,6 +148,23 @@ func CreateDeal(c *gin.Context) {
 		return
 	}
 
+	// Owners and managers create deals on behalf of their organisation
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+	merchantID := member.MerchantID()
+
+	// Merchant must have passed KYC review before publishing deals
+	if !models.IsMerchantApproved(merchantID) {
+		fmt.Printf("❌ Merchant %d has no approved application\n", merchantID)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Your merchant account must be verified before you can create deals",
+			"status": "merchant_not_approved",
+		})
+		return
+	}
+
 	fmt.Printf("✅ User verified: %s %s (Role: %s)\n", user.FirstName, user.LastName, user.Role)
 
 	// Convert []string to StringArray for database storage
@@ -112,7 +179,7 @@ func CreateDeal(c *gin.Context) {
 		ImageURL:         req.ImageURL,
 		Images:           imageArray,
 		CategoryID:       req.CategoryID,
-		MerchantID:       userID,
+		MerchantID:       merchantID,
 		StartDate:        req.StartDate,
 		EndDate:          req.EndDate,
 		MaxQuantity:      req.MaxQuantity,
@@ -122,6 +189,9 @@ func CreateDeal(c *gin.Context) {
 		IsActive:         true,
 	}
 
+	// Automated content checks, blocked deals wait for the merchant to fix them
+	findings := services.CheckDeal(&deal)
+
 	fmt.Printf("💾 Creating deal with images: %v\n", req.Images)
 
 	if err := config.DB.Create(&deal).Error; err != nil {
@@ -134,6 +204,7 @@ func CreateDeal(c *gin.Context) {
 	}
 
 	fmt.Printf("✅ Deal created with ID: %d\n", deal.ID)
+	middleware.RecordAudit(c, "deal.create", "deal", deal.ID, nil, deal)
 
 	// Load relations for response
 	if err := config.DB.Preload("Category").Preload("Merchant").First(&deal, deal.ID).Error; err != nil {
@@ -142,11 +213,17 @@ func CreateDeal(c *gin.Context) {
 
 	fmt.Println("✅ Deal creation successful - Status: PENDING (awaiting admin approval)")
 
+	note := "Your deal will be visible to customers once approved by an administrator"
+	if deal.CheckBlocked {
+		note = "Your deal can't be reviewed until the blocking problems in findings are fixed"
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Deal created successfully and is pending admin approval",
-		"deal":    deal,
-		"status":  "pending",
-		"note":    "Your deal will be visible to customers once approved by an administrator",
+		"message":  "Deal created successfully and is pending admin approval",
+		"deal":     deal,
+		"status":   "pending",
+		"findings": findings,
+		"note":     note,
 	})
 }
 
@@ -161,12 +238,18 @@ func GetDeals(c *gin.Context) {
 	
 	var deals []models.Deal
 	// Only show approved and active deals to public
-	query := config.DB.Preload("Category").Preload("Merchant").Where("status = ? AND is_active = ?", "approved", true)
+	query := config.DB.Preload("Category").Where("status = ? AND is_active = ?", "approved", true)
 
-	// Filter by category
+	// Filter by category, including its subcategories
 	if categoryID := c.Query("category_id"); categoryID != "" {
-		query = query.Where("category_id = ?", categoryID)
+		id, _ := strconv.ParseUint(categoryID, 10, 64)
+		query = query.Where("category_id IN ?", models.CategoryDescendantIDs(uint(id)))
 		fmt.Printf("🔍 Filtering by category: %s\n", categoryID)
+	} else if slug := c.Query("category"); slug != "" {
+		var category models.Category
+		config.DB.Select("id").Where("slug = ?", slug).First(&category)
+		query = query.Where("category_id IN ?", models.CategoryDescendantIDs(category.ID))
+		fmt.Printf("🔍 Filtering by category: %s\n", slug)
 	}
 
 	// Filter by location
@@ -189,6 +272,7 @@ func GetDeals(c *gin.Context) {
 	}
 
 	fmt.Printf("✅ Found %d approved deals\n", len(deals))
+	recordImpressions(deals)
 
 	// Debug: Let's also check total approved deals in database
 	var totalApproved int64
@@ -206,7 +290,7 @@ func GetDeals(c *gin.Context) {
 	fmt.Printf("📊 Total pending deals in database: %d\n", totalPending)
 
 	c.JSON(http.StatusOK, gin.H{
-		"deals": deals,
+		"deals": toPublicDeals(deals),
 		"debug": gin.H{
 			"total_approved": totalApproved,
 			"total_deals": totalDeals,
@@ -218,11 +302,15 @@ func GetDeals(c *gin.Context) {
 
 // MERCHANT ENDPOINT - Shows merchant's own deals (all statuses)
 func GetMerchantDeals(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	fmt.Printf("🔄 GetMerchantDeals called for merchant %d\n", userID)
+	member, ok := merchantMembership(c)
+	if !ok {
+		return
+	}
+	merchantID := member.MerchantID()
+	fmt.Printf("🔄 GetMerchantDeals called for merchant %d by user %d\n", merchantID, member.UserID)
 
 	var deals []models.Deal
-	query := config.DB.Preload("Category").Where("merchant_id = ?", userID)
+	query := config.DB.Preload("Category").Where("merchant_id = ?", merchantID)
 
 	// Filter by status if provided
 	if status := c.Query("status"); status != "" {
@@ -243,15 +331,19 @@ func GetMerchantDeals(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("✅ Found %d deals for merchant %d\n", len(deals), userID)
+	fmt.Printf("✅ Found %d deals for merchant %d\n", len(deals), merchantID)
 
 	c.JSON(http.StatusOK, gin.H{"deals": deals})
 }
 
 // MERCHANT DASHBOARD STATS
 func GetMerchantDashboardStats(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	fmt.Printf("🔄 GetMerchantDashboardStats called for merchant %d\n", userID)
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+	merchantID := member.MerchantID()
+	fmt.Printf("🔄 GetMerchantDashboardStats called for merchant %d\n", merchantID)
 
 	var stats struct {
 		TotalDeals    int64 `json:"total_deals"`
@@ -263,23 +355,26 @@ func GetMerchantDashboardStats(c *gin.Context) {
 	}
 
 	// Get deal counts
-	config.DB.Model(&models.Deal{}).Where("merchant_id = ?", userID).Count(&stats.TotalDeals)
-	config.DB.Model(&models.Deal{}).Where("merchant_id = ? AND status = ?", userID, "approved").Count(&stats.ActiveDeals)
-	config.DB.Model(&models.Deal{}).Where("merchant_id = ? AND status = ?", userID, "pending").Count(&stats.PendingDeals)
-	config.DB.Model(&models.Deal{}).Where("merchant_id = ? AND status = ?", userID, "rejected").Count(&stats.RejectedDeals)
+	config.DB.Model(&models.Deal{}).Where("merchant_id = ?", merchantID).Count(&stats.TotalDeals)
+	config.DB.Model(&models.Deal{}).Where("merchant_id = ? AND status = ?", merchantID, "approved").Count(&stats.ActiveDeals)
+	config.DB.Model(&models.Deal{}).Where("merchant_id = ? AND status = ?", merchantID, "pending").Count(&stats.PendingDeals)
+	config.DB.Model(&models.Deal{}).Where("merchant_id = ? AND status = ?", merchantID, "rejected").Count(&stats.RejectedDeals)
 
 	// Calculate revenue and sales from approved deals
-	var deals []models.Deal
-	config.DB.Where("merchant_id = ? AND status = ?", userID, "approved").Find(&deals)
-	
-	for _, deal := range deals {
-		stats.TotalSales += int64(deal.SoldQuantity)
-		stats.TotalRevenue += float64(deal.SoldQuantity) * deal.DiscountPrice
+	var sales struct {
+		TotalSales   int64
+		TotalRevenue float64
 	}
+	config.DB.Model(&models.Deal{}).
+		Select("COALESCE(SUM(sold_quantity), 0) AS total_sales, COALESCE(SUM(sold_quantity * discount_price), 0) AS total_revenue").
+		Where("merchant_id = ? AND status = ?", merchantID, "approved").
+		Scan(&sales)
+	stats.TotalSales = sales.TotalSales
+	stats.TotalRevenue = sales.TotalRevenue
 
 	// Get recent deals
 	var recentDeals []models.Deal
-	config.DB.Preload("Category").Where("merchant_id = ?", userID).
+	config.DB.Preload("Category").Where("merchant_id = ?", merchantID).
 		Order("created_at DESC").Limit(5).Find(&recentDeals)
 
 	fmt.Printf("✅ Dashboard stats: Total: %d, Active: %d, Pending: %d\n", 
@@ -301,15 +396,18 @@ func GetDeal(c *gin.Context) {
 	fmt.Printf("🔄 GetDeal called for ID: %s\n", id)
 
 	var deal models.Deal
-	if err := config.DB.Preload("Category").Preload("Merchant").First(&deal, id).Error; err != nil {
+	if err := config.DB.Preload("Category").First(&deal, id).Error; err != nil {
 		fmt.Printf("❌ Deal not found: %v\n", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
 		return
 	}
 
 	fmt.Printf("✅ Deal found: %s (Status: %s)\n", deal.Title, deal.Status)
+	if deal.Status == "approved" {
+		services.Funnel.Record(models.FunnelView, deal.ID)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"deal": deal})
+	c.JSON(http.StatusOK, gin.H{"deal": toPublicDeals([]models.Deal{deal})[0]})
 }
 
 func SearchDeals(c *gin.Context) {
@@ -328,7 +426,7 @@ func SearchDeals(c *gin.Context) {
 
 	var deals []models.Deal
 	// Only search in approved and active deals
-	searchQuery := config.DB.Preload("Category").Preload("Merchant").
+	searchQuery := config.DB.Preload("Category").
 		Where("status = ? AND is_active = ?", "approved", true).
 		Where("title ILIKE ? OR description ILIKE ? OR short_description ILIKE ?", 
 			"%"+query+"%", "%"+query+"%", "%"+query+"%")
@@ -340,8 +438,9 @@ func SearchDeals(c *gin.Context) {
 	}
 
 	fmt.Printf("✅ Search found %d deals\n", len(deals))
+	recordImpressions(deals)
 
-	c.JSON(http.StatusOK, gin.H{"deals": deals})
+	c.JSON(http.StatusOK, gin.H{"deals": toPublicDeals(deals)})
 }
 
 func UpdateDeal(c *gin.Context) {
@@ -350,8 +449,13 @@ func UpdateDeal(c *gin.Context) {
 	
 	fmt.Printf("🔄 UpdateDeal called by user %d for deal %s\n", userID, dealID)
 
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
 	var deal models.Deal
-	if err := config.DB.Where("id = ? AND merchant_id = ?", dealID, userID).First(&deal).Error; err != nil {
+	if err := config.DB.Where("id = ? AND merchant_id = ?", dealID, member.MerchantID()).First(&deal).Error; err != nil {
 		fmt.Printf("❌ Deal not found or not owned by user: %v\n", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
 		return
@@ -371,8 +475,18 @@ func UpdateDeal(c *gin.Context) {
 		return
 	}
 
+	// Deals already in a deactivated category may stay there, but can't move into one
+	if req.CategoryID != deal.CategoryID {
+		var category models.Category
+		if err := config.DB.Where("is_active = ?", true).First(&category, req.CategoryID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category selected"})
+			return
+		}
+	}
+
 	// Convert []string to StringArray for database storage
 	imageArray := models.StringArray(req.Images)
+	before := deal
 
 	// Update deal fields
 	deal.Title = req.Title
@@ -389,6 +503,7 @@ func UpdateDeal(c *gin.Context) {
 	deal.Location = req.Location
 	deal.FinePrints = req.FinePrints
 	deal.Status = "pending" // Reset to pending after update - needs re-approval
+	findings := services.CheckDeal(&deal)
 
 	if err := config.DB.Save(&deal).Error; err != nil {
 		fmt.Printf("❌ Failed to update deal: %v\n", err)
@@ -397,10 +512,12 @@ func UpdateDeal(c *gin.Context) {
 	}
 
 	fmt.Printf("✅ Deal updated successfully - Status reset to PENDING\n")
+	middleware.RecordAudit(c, "deal.update", "deal", deal.ID, before, deal)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Deal updated successfully and is pending admin approval",
-		"deal":    deal,
+		"message":  "Deal updated successfully and is pending admin approval",
+		"deal":     deal,
+		"findings": findings,
 	})
 }
 
@@ -410,8 +527,13 @@ func DeleteDeal(c *gin.Context) {
 	
 	fmt.Printf("🔄 DeleteDeal called by user %d for deal %s\n", userID, dealID)
 
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
 	var deal models.Deal
-	if err := config.DB.Where("id = ? AND merchant_id = ?", dealID, userID).First(&deal).Error; err != nil {
+	if err := config.DB.Where("id = ? AND merchant_id = ?", dealID, member.MerchantID()).First(&deal).Error; err != nil {
 		fmt.Printf("❌ Deal not found or not owned by user: %v\n", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
 		return
@@ -424,58 +546,114 @@ func DeleteDeal(c *gin.Context) {
 	}
 
 	fmt.Printf("✅ Deal deleted successfully\n")
+	middleware.RecordAudit(c, "deal.delete", "deal", deal.ID, deal, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Deal deleted successfully"})
 }
 
 // Admin functions with notifications
+// ADMIN - Pending deals, oldest first. queue=mine|unclaimed|claimed narrows
+// the list to deals by claim, checks=flagged|clean|blocked|all by content
+// check results. Deals blocked by the checks are left out unless asked for.
 func GetPendingDeals(c *gin.Context) {
+	adminID := c.GetUint("user_id")
 	fmt.Println("🔄 Admin: GetPendingDeals called")
-	
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset := (page - 1) * limit
+
+	query := config.DB.Model(&models.Deal{}).Where("status = ?", "pending")
+	switch c.Query("queue") {
+	case "mine":
+		query = query.Where("claimed_by_id = ? AND claimed_at >= ?", adminID, models.DealClaimCutoff())
+	case "unclaimed":
+		query = query.Where("claimed_by_id IS NULL OR claimed_at < ?", models.DealClaimCutoff())
+	case "claimed":
+		query = query.Where("claimed_by_id IS NOT NULL AND claimed_at >= ?", models.DealClaimCutoff())
+	}
+	noFindings := "(check_findings IS NULL OR check_findings = '' OR check_findings = '[]')"
+	switch c.Query("checks") {
+	case "all":
+	case "blocked":
+		query = query.Where("check_blocked = ?", true)
+	case "flagged":
+		query = query.Where("check_blocked = ? AND NOT "+noFindings, false)
+	case "clean":
+		query = query.Where("check_blocked = ? AND "+noFindings, false)
+	default:
+		query = query.Where("check_blocked = ?", false)
+	}
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		query = query.Where("category_id = ?", categoryID)
+	}
+	if merchantID := c.Query("merchant_id"); merchantID != "" {
+		query = query.Where("merchant_id = ?", merchantID)
+	}
+
+	var total int64
+	query.Count(&total)
+
 	var deals []models.Deal
-	if err := config.DB.Preload("Category").Preload("Merchant").
-		Where("status = ?", "pending").
+	if err := query.Preload("Category").Preload("Merchant").
+		Preload("ClaimedBy", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name, email")
+		}).
 		Order("created_at ASC").
+		Offset(offset).Limit(limit).
 		Find(&deals).Error; err != nil {
 		fmt.Printf("❌ Failed to fetch pending deals: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending deals"})
 		return
 	}
 
+	var pending, unclaimed, mine, blocked int64
+	config.DB.Model(&models.Deal{}).Where("status = ?", "pending").Count(&pending)
+	config.DB.Model(&models.Deal{}).
+		Where("status = ? AND check_blocked = ? AND (claimed_by_id IS NULL OR claimed_at < ?)", "pending", false, models.DealClaimCutoff()).
+		Count(&unclaimed)
+	config.DB.Model(&models.Deal{}).Where("status = ? AND check_blocked = ?", "pending", true).Count(&blocked)
+	config.DB.Model(&models.Deal{}).
+		Where("status = ? AND claimed_by_id = ? AND claimed_at >= ?", "pending", adminID, models.DealClaimCutoff()).
+		Count(&mine)
+
 	fmt.Printf("✅ Found %d pending deals\n", len(deals))
 
-	c.JSON(http.StatusOK, gin.H{"deals": deals})
+	c.JSON(http.StatusOK, gin.H{
+		"deals": deals,
+		"counts": gin.H{
+			"pending":   pending,
+			"unclaimed": unclaimed,
+			"mine":      mine,
+			"blocked":   blocked,
+		},
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
 }
 
 func ApproveDeal(c *gin.Context) {
 	dealID := c.Param("id")
 	fmt.Printf("🔄 Admin: ApproveDeal called for deal %s\n", dealID)
 
-	var deal models.Deal
-	if err := config.DB.Preload("Merchant").First(&deal, dealID).Error; err != nil {
-		fmt.Printf("❌ Deal not found: %v\n", err)
+	var before models.Deal
+	if err := config.DB.First(&before, dealID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
 		return
 	}
 
-	deal.Status = "approved"
-	if err := config.DB.Save(&deal).Error; err != nil {
+	deal, err := moderateDeal(c.GetUint("user_id"), before.ID, "approved", "", false)
+	if err != nil {
 		fmt.Printf("❌ Failed to approve deal: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve deal"})
+		respondModerationError(c, deal, err)
 		return
 	}
 
-	// Create notification for merchant
-	notification := models.Notification{
-		UserID:  deal.MerchantID,
-		Title:   "Deal Approved! 🎉",
-		Message: "Your deal '" + deal.Title + "' has been approved and is now live!",
-		Type:    models.NotificationDealApproved,
-		Data:    `{"deal_id": ` + dealID + `}`,
-	}
-	config.DB.Create(&notification)
-
 	fmt.Printf("✅ Deal approved successfully - now visible to customers\n")
+	middleware.RecordAudit(c, "deal.approve", "deal", deal.ID, before, deal)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Deal approved successfully",
@@ -488,97 +666,35 @@ func RejectDeal(c *gin.Context) {
 	fmt.Printf("🔄 Admin: RejectDeal called for deal %s\n", dealID)
 
 	var req struct {
-		Reason string `json:"reason"`
+		Reason   string `json:"reason"`
+		ReasonID *uint  `json:"reason_id"` // canned reason, reason is appended
 	}
 	c.ShouldBindJSON(&req)
 
-	var deal models.Deal
-	if err := config.DB.Preload("Merchant").First(&deal, dealID).Error; err != nil {
-		fmt.Printf("❌ Deal not found: %v\n", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
+	reason, err := resolveRejectionReason(req.ReasonID, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	deal.Status = "rejected"
-	if err := config.DB.Save(&deal).Error; err != nil {
-		fmt.Printf("❌ Failed to reject deal: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject deal"})
+	var before models.Deal
+	if err := config.DB.First(&before, dealID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
 		return
 	}
 
-	// Create notification for merchant
-	message := "Your deal '" + deal.Title + "' has been rejected."
-	if req.Reason != "" {
-		message += " Reason: " + req.Reason
-	}
-
-	notification := models.Notification{
-		UserID:  deal.MerchantID,
-		Title:   "Deal Rejected",
-		Message: message,
-		Type:    models.NotificationDealRejected,
-		Data:    `{"deal_id": ` + dealID + `, "reason": "` + req.Reason + `"}`,
+	deal, err := moderateDeal(c.GetUint("user_id"), before.ID, "rejected", reason, false)
+	if err != nil {
+		fmt.Printf("❌ Failed to reject deal: %v\n", err)
+		respondModerationError(c, deal, err)
+		return
 	}
-	config.DB.Create(&notification)
 
 	fmt.Printf("✅ Deal rejected successfully\n")
+	middleware.RecordAudit(c, "deal.reject", "deal", deal.ID, before, gin.H{"deal": deal, "reason": reason})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Deal rejected successfully",
 		"deal":    deal,
 	})
 }
-
-func GetAllUsers(c *gin.Context) {
-	var users []models.User
-	query := config.DB.Select("id, first_name, last_name, email, phone, role, status, is_verified, created_at")
-
-	// Filter by role
-	if role := c.Query("role"); role != "" {
-		query = query.Where("role = ?", role)
-	}
-
-	// Pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	offset := (page - 1) * limit
-
-	query = query.Offset(offset).Limit(limit).Order("created_at DESC")
-
-	if err := query.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"users": users})
-}
-
-func UpdateUserStatus(c *gin.Context) {
-	userID := c.Param("id")
-
-	var req struct {
-		Status string `json:"status" binding:"required"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	user.Status = req.Status
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User status updated successfully",
-		"user":    user,
-	})
-}
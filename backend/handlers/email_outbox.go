@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// ADMIN - List outbound emails, newest first. Bodies are left out; fetch a
+// single email to see them.
+func GetOutboundEmails(c *gin.Context) {
+	query := config.DB.Model(&models.OutboundEmail{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("recipient LIKE ?", "%"+to+"%")
+	}
+	if template := c.Query("template"); template != "" {
+		query = query.Where("template = ?", template)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var emails []models.OutboundEmail
+	if err := query.Omit("html", "text", "attachments").
+		Order("created_at DESC").
+		Offset(offset).Limit(limit).
+		Find(&emails).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emails"})
+		return
+	}
+
+	// Queue health at a glance
+	var counts []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	config.DB.Model(&models.OutboundEmail{}).Select("status, COUNT(*) AS count").Group("status").Scan(&counts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"emails": emails,
+		"counts": counts,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ADMIN - One outbound email with its delivery log, and its bodies while it
+// is unsent. Bodies of OTP, reset and invitation emails are never shown.
+func GetOutboundEmail(c *gin.Context) {
+	var email models.OutboundEmail
+	if err := config.DB.Preload("DeliveryLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempt ASC")
+	}).First(&email, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
+		return
+	}
+
+	redacted := email.Redact()
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":       email,
+		"redacted":    redacted,
+		"attachments": email.AttachmentNames(),
+	})
+}
+
+// emailAuditSnapshot is what the audit log keeps of an email. The audit log
+// can't be purged, so bodies, headers and attachments never go in it.
+func emailAuditSnapshot(email models.OutboundEmail) gin.H {
+	return gin.H{
+		"id":       email.ID,
+		"to":       email.To,
+		"template": email.Template,
+		"status":   email.Status,
+		"attempts": email.Attempts,
+	}
+}
+
+// ADMIN - Queue a failed email for another round of delivery attempts
+func RetryOutboundEmail(c *gin.Context) {
+	var email models.OutboundEmail
+	if err := config.DB.Omit("html", "text", "attachments").First(&email, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
+		return
+	}
+
+	if email.Status != models.EmailStatusFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only failed emails can be retried"})
+		return
+	}
+
+	if services.Outbox == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email outbox is not running"})
+		return
+	}
+
+	before := email
+	if err := services.Outbox.Retry(&email); err != nil {
+		fmt.Printf("❌ Failed to retry email %d: %v\n", email.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry email"})
+		return
+	}
+
+	fmt.Printf("✅ Email %d to %s queued for retry\n", email.ID, email.To)
+	middleware.RecordAudit(c, "email.retry", "email", email.ID, emailAuditSnapshot(before), emailAuditSnapshot(email))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email queued for retry",
+		"email":   email,
+	})
+}
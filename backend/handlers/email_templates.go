@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/services"
+)
+
+// ADMIN - List email templates and locales
+func GetEmailTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"templates": services.EmailTemplateNames(),
+		"locales":   services.EmailLocales,
+	})
+}
+
+// ADMIN - Render an email template with sample data. format=html or
+// format=text returns the body directly for viewing in a browser.
+func PreviewEmailTemplate(c *gin.Context) {
+	name := c.Param("name")
+
+	data, ok := services.EmailTemplateSample(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return
+	}
+
+	rendered, err := services.RenderEmail(name, c.DefaultQuery("locale", services.DefaultEmailLocale), data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render email template: " + err.Error()})
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+	default:
+		c.JSON(http.StatusOK, gin.H{"template": name, "email": rendered})
+	}
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// exportBatchSize is how many rows an export loads from the database at once
+const exportBatchSize = 500
+
+// exportWriter streams an export to the response as CSV or, with
+// ?format=xlsx, as an Excel workbook
+type exportWriter struct {
+	csv  *csv.Writer
+	xlsx *services.XLSXWriter
+}
+
+// newExportWriter sends the download headers and the header row. name is
+// used for the file name and the sheet.
+func newExportWriter(c *gin.Context, name string, header []string) (*exportWriter, error) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		return nil, errors.New("format must be csv or xlsx")
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	w := &exportWriter{}
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Status(http.StatusOK)
+		xlsx, err := services.NewXLSXWriter(c.Writer, name)
+		if err != nil {
+			return nil, err
+		}
+		w.xlsx = xlsx
+		return w, xlsx.WriteHeader(header)
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w.csv = csv.NewWriter(c.Writer)
+	return w, w.csv.Write(header)
+}
+
+// startExport is newExportWriter that answers the request itself when the
+// export can't start
+func startExport(c *gin.Context, name string, header []string) (*exportWriter, bool) {
+	w, err := newExportWriter(c, name, header)
+	if err != nil {
+		if !c.Writer.Written() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return w, true
+}
+
+// Row writes one row of strings, numbers, bools, times or *time.Time
+func (w *exportWriter) Row(cells ...interface{}) error {
+	for i, cell := range cells {
+		if t, ok := cell.(*time.Time); ok {
+			if t == nil {
+				cells[i] = nil
+			} else {
+				cells[i] = *t
+			}
+		}
+	}
+
+	if w.xlsx != nil {
+		return w.xlsx.WriteRow(cells)
+	}
+
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+		case string:
+			record[i] = services.CSVCell(v)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			record[i] = v.UTC().Format(time.RFC3339)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return w.csv.Write(record)
+}
+
+// Flush sends the rows so far to the client, once per batch
+func (w *exportWriter) Flush() error {
+	if w.xlsx != nil {
+		return w.xlsx.Flush()
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Close finishes the export and logs err, as the status has already been
+// sent by the time a batch fails
+func (w *exportWriter) Close(name string, err error) {
+	if err == nil && w.xlsx != nil {
+		err = w.xlsx.Close()
+	} else if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		fmt.Printf("❌ %s export failed: %v\n", name, err)
+	}
+}
+
+// dateRangeFilter applies from and to (a date, to inclusive, or an RFC 3339
+// timestamp) to column
+func dateRangeFilter(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, error) {
+	if from := c.Query("from"); from != "" {
+		start, err := parseDateFilter(from, false)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(column+" >= ?", start)
+	}
+	if to := c.Query("to"); to != "" {
+		end, err := parseDateFilter(to, true)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(column+" < ?", end)
+	}
+	return query, nil
+}
+
+// transactionExportQuery filters transactions by status, deal_id, user_id,
+// payment_method and from/to
+func transactionExportQuery(c *gin.Context) (*gorm.DB, error) {
+	query := config.DB.Model(&models.Transaction{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if dealID := c.Query("deal_id"); dealID != "" {
+		query = query.Where("deal_id = ?", dealID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if method := c.Query("payment_method"); method != "" {
+		query = query.Where("payment_method = ?", method)
+	}
+	return dateRangeFilter(c, query, "created_at")
+}
+
+// merchantDealIDs selects every deal of the merchant, deleted ones included
+// so their past sales still show up
+func merchantDealIDs(merchantID interface{}) *gorm.DB {
+	return config.DB.Unscoped().Model(&models.Deal{}).Select("id").Where("merchant_id = ?", merchantID)
+}
+
+func exportTransactions(c *gin.Context, query *gorm.DB, name string) {
+	w, ok := startExport(c, name, []string{
+		"id", "created_at", "status", "customer_id", "customer_name", "customer_email", "phone_number",
+		"deal_id", "deal_title", "merchant_id", "quantity", "amount", "payment_method", "payment_reference",
+	})
+	if !ok {
+		return
+	}
+
+	var transactions []models.Transaction
+	err := query.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, first_name, last_name, email")
+		}).
+		Preload("Deal", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, title, merchant_id")
+		}).
+		FindInBatches(&transactions, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, t := range transactions {
+				w.Row(t.ID, t.CreatedAt, t.Status, t.UserID, t.User.FirstName+" "+t.User.LastName, t.User.Email, t.PhoneNumber,
+					t.DealID, t.Deal.Title, t.Deal.MerchantID, t.Quantity, t.Amount, t.PaymentMethod, t.PaymentReference)
+			}
+			return w.Flush()
+		}).Error
+	w.Close(name, err)
+}
+
+// ADMIN - Export transactions, filtered by status, deal_id, user_id,
+// merchant_id, payment_method and from/to
+func ExportTransactions(c *gin.Context) {
+	query, err := transactionExportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if merchantID := c.Query("merchant_id"); merchantID != "" {
+		query = query.Where("deal_id IN (?)", merchantDealIDs(merchantID))
+	}
+
+	fmt.Printf("🔄 ExportTransactions called by admin %d\n", c.GetUint("user_id"))
+	exportTransactions(c, query, "transactions")
+}
+
+// ADMIN - Export users with the same search and filters as the user list
+func ExportUsers(c *gin.Context) {
+	query, err := userListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔄 ExportUsers called by admin %d\n", c.GetUint("user_id"))
+
+	w, ok := startExport(c, "users", []string{
+		"id", "first_name", "last_name", "email", "phone", "role", "status", "is_verified", "created_at", "last_login_at",
+	})
+	if !ok {
+		return
+	}
+
+	var users []models.User
+	err = query.FindInBatches(&users, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, u := range users {
+			w.Row(u.ID, u.FirstName, u.LastName, u.Email, u.Phone, u.Role, u.Status, u.IsVerified, u.CreatedAt, u.LastLoginAt)
+		}
+		return w.Flush()
+	}).Error
+	w.Close("users", err)
+}
+
+// ADMIN - Export deals with the same status and merchant_id filters as the
+// admin deal list
+func ExportDeals(c *gin.Context) {
+	query := adminDealListQuery(c)
+
+	fmt.Printf("🔄 ExportDeals called by admin %d\n", c.GetUint("user_id"))
+
+	w, ok := startExport(c, "deals", []string{
+		"id", "title", "status", "is_active", "category", "merchant_id", "merchant_name",
+		"original_price", "discount_price", "discount_percent", "sold_quantity", "max_quantity",
+		"start_date", "end_date", "created_at",
+	})
+	if !ok {
+		return
+	}
+
+	var deals []models.Deal
+	err := query.
+		Preload("Category", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name")
+		}).
+		Preload("Merchant", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, first_name, last_name")
+		}).
+		FindInBatches(&deals, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, d := range deals {
+				w.Row(d.ID, d.Title, d.Status, d.IsActive, d.Category.Name, d.MerchantID, d.Merchant.FirstName+" "+d.Merchant.LastName,
+					d.OriginalPrice, d.DiscountPrice, d.DiscountPercent, d.SoldQuantity, d.MaxQuantity,
+					d.StartDate, d.EndDate, d.CreatedAt)
+			}
+			return w.Flush()
+		}).Error
+	w.Close("deals", err)
+}
+
+// ADMIN - Export the per-merchant figures of GetMerchantStatistics, computed
+// in one query and streamed row by row
+func ExportMerchantStatistics(c *gin.Context) {
+	fmt.Printf("🔄 ExportMerchantStatistics called by admin %d\n", c.GetUint("user_id"))
+
+	w, ok := startExport(c, "merchant-statistics", []string{
+		"merchant_id", "merchant_name", "merchant_email", "total_deals", "approved_deals", "pending_deals", "total_revenue",
+	})
+	if !ok {
+		return
+	}
+
+	rows, err := config.DB.Table("users AS u").
+		Select(`u.id, u.first_name, u.last_name, u.email, COUNT(d.id),
+			COALESCE(SUM(CASE WHEN d.status = 'approved' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.status = 'pending' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.status = 'approved' THEN d.sold_quantity * d.discount_price ELSE 0 END), 0)`).
+		Joins("LEFT JOIN deals AS d ON d.merchant_id = u.id AND d.deleted_at IS NULL").
+		Where("u.role = ? AND u.deleted_at IS NULL", "merchant").
+		Group("u.id, u.first_name, u.last_name, u.email").
+		Order("u.id").
+		Rows()
+	if err != nil {
+		w.Close("merchant-statistics", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id                       uint
+			firstName, lastName      string
+			email                    string
+			total, approved, pending int64
+			revenue                  float64
+		)
+		if err = rows.Scan(&id, &firstName, &lastName, &email, &total, &approved, &pending, &revenue); err != nil {
+			break
+		}
+		w.Row(id, firstName+" "+lastName, email, total, approved, pending, revenue)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	w.Close("merchant-statistics", err)
+}
+
+// MERCHANT - Export transactions for the merchant's deals, filtered by
+// status, deal_id and from/to
+func ExportMerchantTransactions(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	query, err := transactionExportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query = query.Where("deal_id IN (?)", merchantDealIDs(member.MerchantID()))
+
+	fmt.Printf("🔄 ExportMerchantTransactions called for merchant %d\n", member.MerchantID())
+	exportTransactions(c, query, "transactions")
+}
+
+// MERCHANT - Export redeemed vouchers, filtered by deal_id and from/to on
+// the redemption time
+func ExportMerchantRedemptions(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Model(&models.Voucher{}).
+		Where("merchant_id = ? AND status = ?", member.MerchantID(), models.VoucherStatusRedeemed)
+	if dealID := c.Query("deal_id"); dealID != "" {
+		query = query.Where("deal_id = ?", dealID)
+	}
+	query, err := dateRangeFilter(c, query, "redeemed_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("🔄 ExportMerchantRedemptions called for merchant %d\n", member.MerchantID())
+
+	w, ok := startExport(c, "redemptions", []string{
+		"code", "deal_id", "deal_title", "transaction_id", "redeemed_at", "redeemed_by_id", "redeemed_by",
+	})
+	if !ok {
+		return
+	}
+
+	// Staff names are looked up once per batch
+	var vouchers []models.Voucher
+	err = query.
+		Preload("Deal", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, title")
+		}).
+		FindInBatches(&vouchers, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			var staffIDs []uint
+			for _, v := range vouchers {
+				if v.RedeemedByID != nil {
+					staffIDs = append(staffIDs, *v.RedeemedByID)
+				}
+			}
+			staff := make(map[uint]string)
+			if len(staffIDs) > 0 {
+				var users []models.User
+				config.DB.Unscoped().Select("id, first_name, last_name").Where("id IN ?", staffIDs).Find(&users)
+				for _, u := range users {
+					staff[u.ID] = u.FirstName + " " + u.LastName
+				}
+			}
+
+			for _, v := range vouchers {
+				var redeemedByID interface{}
+				redeemedBy := ""
+				if v.RedeemedByID != nil {
+					redeemedByID = *v.RedeemedByID
+					redeemedBy = staff[*v.RedeemedByID]
+				}
+				w.Row(v.Code, v.DealID, v.Deal.Title, v.TransactionID, v.RedeemedAt, redeemedByID, redeemedBy)
+			}
+			return w.Flush()
+		}).Error
+	w.Close("redemptions", err)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" binding:"required"`
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+	Phone     string `json:"phone" binding:"required"`
+	Password  string `json:"password" binding:"required,min=8"`
+}
+
+// Admin - Invite a new administrator by email
+func CreateAdminInvitation(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existingUser models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+		return
+	}
+
+	var inviter models.User
+	if err := config.DB.First(&inviter, adminID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Only one open invitation per email - revoke any earlier pending ones
+	now := time.Now()
+	config.DB.Model(&models.AdminInvitation{}).
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", req.Email).
+		Update("revoked_at", now)
+
+	invitation := models.AdminInvitation{
+		Email:       req.Email,
+		InvitedByID: adminID,
+	}
+	if err := invitation.GenerateToken(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
+		return
+	}
+
+	if err := config.DB.Create(&invitation).Error; err != nil {
+		fmt.Printf("❌ Failed to create invitation: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	adminURL := os.Getenv("ADMIN_APP_URL")
+	if adminURL == "" {
+		adminURL = "http://localhost:3002"
+	}
+	inviteURL := fmt.Sprintf("%s/accept-invitation?token=%s", adminURL, invitation.Token)
+
+	emailService := services.NewEmailService()
+	inviterName := inviter.FirstName + " " + inviter.LastName
+	if err := emailService.SendAdminInvitationEmail(invitation.Email, inviterName, inviteURL, invitation.ExpiresAt); err != nil {
+		fmt.Printf("❌ Failed to send invitation email: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation email"})
+		return
+	}
+
+	fmt.Printf("📧 Admin invitation sent to %s by admin %d\n", invitation.Email, adminID)
+	middleware.RecordAudit(c, "admin_invitation.create", "admin_invitation", invitation.ID, nil, invitation)
+
+	invitation.InvitedBy = inviter
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation sent successfully",
+		"invitation": invitation,
+	})
+}
+
+// Admin - List invitations with their status
+func GetAdminInvitations(c *gin.Context) {
+	var invitations []models.AdminInvitation
+	if err := config.DB.Preload("InvitedBy").Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	var result []gin.H
+	for _, invitation := range invitations {
+		result = append(result, gin.H{
+			"id":          invitation.ID,
+			"email":       invitation.Email,
+			"invited_by":  invitation.InvitedBy.FirstName + " " + invitation.InvitedBy.LastName,
+			"status":      invitation.Status(),
+			"expires_at":  invitation.ExpiresAt,
+			"accepted_at": invitation.AcceptedAt,
+			"created_at":  invitation.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": result})
+}
+
+func RevokeAdminInvitation(c *gin.Context) {
+	var invitation models.AdminInvitation
+	if err := config.DB.First(&invitation, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	if !invitation.IsPending() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation is no longer pending"})
+		return
+	}
+
+	before := invitation
+	now := time.Now()
+	invitation.RevokedAt = &now
+	if err := config.DB.Save(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	middleware.RecordAudit(c, "admin_invitation.revoke", "admin_invitation", invitation.ID, before, invitation)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// PUBLIC - Check an invitation token before showing the set-password form
+func GetAdminInvitation(c *gin.Context) {
+	var invitation models.AdminInvitation
+	if err := config.DB.Where("token = ?", c.Param("token")).First(&invitation).Error; err != nil || !invitation.IsPending() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":      invitation.Email,
+		"expires_at": invitation.ExpiresAt,
+	})
+}
+
+// PUBLIC - Accept an invitation and create the admin account
+func AcceptAdminInvitation(c *gin.Context) {
+	fmt.Println("🔄 AcceptAdminInvitation handler called")
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var invitation models.AdminInvitation
+	if err := config.DB.Where("token = ?", req.Token).First(&invitation).Error; err != nil || !invitation.IsPending() {
+		fmt.Println("❌ Invalid or expired invitation")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return
+	}
+
+	admin, err := models.CreateAdmin(req.FirstName, req.LastName, invitation.Email, req.Phone, req.Password)
+	if err != nil {
+		fmt.Printf("❌ Failed to create admin from invitation: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	invitation.AcceptedAt = &now
+	config.DB.Save(&invitation)
+
+	token, err := generateJWT(c, admin.ID, admin.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	fmt.Printf("✅ Admin invitation accepted: %s\n", admin.Email)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Admin account created successfully! Welcome to Snapadeal Admin Portal!",
+		"user":    admin,
+		"token":   token,
+	})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// Get own uploaded images with storage usage against the quota
+func GetMyMediaAssets(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := config.DB.Model(&models.MediaAsset{}).Where("owner_id = ?", userID)
+
+	var total int64
+	query.Count(&total)
+
+	var assets []models.MediaAsset
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch uploads"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(assets))
+	for _, asset := range assets {
+		result := mediaAssetResponse(asset)
+		result["size"] = asset.Size
+		result["reference_count"] = asset.ReferenceCount
+		result["created_at"] = asset.CreatedAt
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assets": results,
+		"usage": gin.H{
+			"used":  models.MediaUsage(userID),
+			"limit": models.MediaQuota(c.GetString("role")), // 0 means unlimited
+		},
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// Delete an own uploaded image that no deal or profile uses, freeing quota
+func DeleteMyMediaAsset(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var asset models.MediaAsset
+	if err := config.DB.Where("id = ? AND owner_id = ?", c.Param("id"), userID).First(&asset).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	// Check live references rather than the count from the last cleanup run
+	references, err := services.ReferencedMediaKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check where the image is used"})
+		return
+	}
+	if references[asset.Key] > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This image is still used by a deal or profile"})
+		return
+	}
+
+	if err := services.DeleteMediaAsset(asset); err != nil {
+		fmt.Printf("❌ Failed to delete media asset %d: %v\n", asset.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload deleted"})
+}
+
+// ADMIN - List uploaded media. ?status=unreferenced shows what the cleanup
+// job will delete once the grace period passes.
+func GetMediaAssets(c *gin.Context) {
+	query := config.DB.Model(&models.MediaAsset{})
+
+	switch c.Query("status") {
+	case "referenced":
+		query = query.Where("reference_count > 0")
+	case "unreferenced":
+		query = query.Where("reference_count = 0")
+	}
+	if ownerID := c.Query("owner_id"); ownerID != "" {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var summary struct {
+		Total int64 `json:"total"`
+		Bytes int64 `json:"bytes"`
+	}
+	query.Session(&gorm.Session{}).Select("COUNT(*) AS total, COALESCE(SUM(size), 0) AS bytes").Scan(&summary)
+
+	var assets []models.MediaAsset
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assets":       assets,
+		"summary":      summary,
+		"grace_period": services.MediaOrphanGrace.String(),
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": summary.Total,
+		},
+	})
+}
+
+// ADMIN - Run the orphaned media cleanup now instead of waiting for the
+// next scheduled run
+func RunMediaCleanup(c *gin.Context) {
+	fmt.Printf("🔄 RunMediaCleanup called by admin %d\n", c.GetUint("user_id"))
+
+	result, err := services.CleanupMedia(services.MediaOrphanGrace)
+	if err != nil {
+		fmt.Printf("❌ Media cleanup failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Media cleanup failed"})
+		return
+	}
+
+	middleware.RecordAudit(c, "media.cleanup", "media", "", nil, result)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Media cleanup complete",
+		"result":  result,
+	})
+}
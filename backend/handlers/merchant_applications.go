@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+type MerchantApplicationRequest struct {
+	BusinessName       string `json:"business_name" binding:"required"`
+	RegistrationNumber string `json:"registration_number" binding:"required"`
+	TIN                string `json:"tin" binding:"required"`
+	PhysicalAddress    string `json:"physical_address" binding:"required"`
+	ContactPerson      string `json:"contact_person" binding:"required"`
+	ContactPhone       string `json:"contact_phone"`
+}
+
+type ReviewApplicationRequest struct {
+	Notes string `json:"notes"`
+}
+
+// MERCHANT - Get own onboarding application
+func GetMyMerchantApplication(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	var application models.MerchantApplication
+	if err := config.DB.Preload("Documents").Where("user_id = ?", member.MerchantID()).First(&application).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":  "No merchant application found",
+			"status": "not_submitted",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"application": application})
+}
+
+// MERCHANT - Create or update the onboarding application. Every submission
+// puts the application back in the review queue.
+func SubmitMerchantApplication(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fmt.Printf("🔄 SubmitMerchantApplication called by user %d\n", userID)
+
+	// Only the organisation owner can apply on behalf of the business
+	if _, ok := merchantMembership(c, models.MerchantRoleOwner); !ok {
+		return
+	}
+
+	var req MerchantApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fmt.Printf("❌ JSON binding error: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var application models.MerchantApplication
+	isNew := config.DB.Where("user_id = ?", userID).First(&application).Error != nil
+
+	if !isNew && !application.IsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your merchant application has already been approved"})
+		return
+	}
+	var before interface{}
+	if !isNew {
+		before = application
+	}
+
+	application.UserID = userID
+	application.BusinessName = req.BusinessName
+	application.RegistrationNumber = req.RegistrationNumber
+	application.TIN = req.TIN
+	application.PhysicalAddress = req.PhysicalAddress
+	application.ContactPerson = req.ContactPerson
+	application.ContactPhone = req.ContactPhone
+	application.Status = models.MerchantApplicationPending
+	application.SubmittedAt = time.Now()
+
+	if err := config.DB.Save(&application).Error; err != nil {
+		fmt.Printf("❌ Failed to save merchant application: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save merchant application"})
+		return
+	}
+
+	config.DB.Preload("Documents").First(&application, application.ID)
+
+	fmt.Printf("✅ Merchant application %d submitted - Status: PENDING\n", application.ID)
+	middleware.RecordAudit(c, "merchant_application.submit", "merchant_application", application.ID, before, application)
+
+	status := http.StatusOK
+	if isNew {
+		status = http.StatusCreated
+	}
+
+	c.JSON(status, gin.H{
+		"message":     "Merchant application submitted and is pending review",
+		"application": application,
+		"note":        "Upload your business documents so our team can verify your business",
+	})
+}
+
+// MERCHANT - Upload a KYC document for the application
+func UploadMerchantDocument(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if _, ok := merchantMembership(c, models.MerchantRoleOwner); !ok {
+		return
+	}
+
+	var application models.MerchantApplication
+	if err := config.DB.Where("user_id = ?", userID).First(&application).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Submit your merchant application before uploading documents"})
+		return
+	}
+
+	if !application.IsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your merchant application has already been approved"})
+		return
+	}
+
+	if err := c.Request.ParseMultipartForm(10 << 20); err != nil { // 10 MB max
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large"})
+		return
+	}
+
+	documentType := c.Request.FormValue("document_type")
+	if !models.MerchantDocumentTypes[documentType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document type"})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("document")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	// Identify the document by its content, not the name the client sent
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	file.Close()
+
+	allowedTypes := map[string]string{
+		"application/pdf": ".pdf",
+		"image/jpeg":      ".jpg",
+		"image/png":       ".png",
+	}
+
+	contentType := http.DetectContentType(sniff[:n])
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": fmt.Sprintf("The file content is %s; only PDF, JPG and PNG are allowed", contentType),
+			"code":  "unsupported_type",
+		})
+		return
+	}
+
+	storageName, err := services.RandomFileName(ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file name"})
+		return
+	}
+
+	// KYC documents go under the private prefix, which /uploads never serves
+	storagePath := services.KYCDocumentKey(application.ID, storageName)
+	file, err = header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The file could not be read"})
+		return
+	}
+	err = services.Store.Put(storagePath, file, header.Size, contentType)
+	file.Close()
+	if err != nil {
+		fmt.Printf("❌ Failed to store KYC document: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	document := models.MerchantDocument{
+		ApplicationID: application.ID,
+		DocumentType:  documentType,
+		FileName:      filepath.Base(header.Filename),
+		StoragePath:   storagePath,
+		ContentType:   contentType,
+		Size:          header.Size,
+	}
+
+	if err := config.DB.Create(&document).Error; err != nil {
+		services.Store.Delete(storagePath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save document"})
+		return
+	}
+	middleware.RecordAudit(c, "merchant_document.upload", "merchant_document", document.ID, nil, document)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Document uploaded successfully",
+		"document": document,
+	})
+}
+
+// MERCHANT - Remove a KYC document from a not-yet-approved application
+func DeleteMerchantDocument(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if _, ok := merchantMembership(c, models.MerchantRoleOwner); !ok {
+		return
+	}
+
+	var application models.MerchantApplication
+	if err := config.DB.Where("user_id = ?", userID).First(&application).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No merchant application found"})
+		return
+	}
+
+	if !application.IsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your merchant application has already been approved"})
+		return
+	}
+
+	var document models.MerchantDocument
+	if err := config.DB.Where("id = ? AND application_id = ?", c.Param("id"), application.ID).First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&document).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document"})
+		return
+	}
+	if err := services.Store.Delete(document.StoragePath); err != nil {
+		fmt.Printf("⚠️ Failed to delete KYC document %s: %v\n", document.StoragePath, err)
+	}
+	middleware.RecordAudit(c, "merchant_document.delete", "merchant_document", document.ID, document, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+}
+
+// Admin - List merchant applications
+func GetMerchantApplications(c *gin.Context) {
+	var applications []models.MerchantApplication
+	query := config.DB.Preload("User").Preload("Documents")
+
+	// Filter by status if provided
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// Pagination
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query = query.Offset(offset).Limit(limit).Order("submitted_at ASC")
+
+	if err := query.Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merchant applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applications": applications})
+}
+
+// Admin - Get a single merchant application
+func GetMerchantApplication(c *gin.Context) {
+	var application models.MerchantApplication
+	if err := config.DB.Preload("User").Preload("Documents").First(&application, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant application not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"application": application})
+}
+
+// Admin - Download a KYC document
+func DownloadMerchantDocument(c *gin.Context) {
+	var document models.MerchantDocument
+	if err := config.DB.Where("id = ? AND application_id = ?", c.Param("docId"), c.Param("id")).First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	reader, object, err := services.Store.Get(document.StoragePath)
+	if err != nil {
+		if err != services.ErrStorageNotFound {
+			fmt.Printf("❌ Failed to read KYC document %s: %v\n", document.StoragePath, err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, object.Size, document.ContentType, reader, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": document.FileName}),
+	})
+}
+
+func ApproveMerchantApplication(c *gin.Context) {
+	reviewMerchantApplication(c, models.MerchantApplicationApproved)
+}
+
+func RejectMerchantApplication(c *gin.Context) {
+	reviewMerchantApplication(c, models.MerchantApplicationRejected)
+}
+
+func RequestMerchantApplicationInfo(c *gin.Context) {
+	reviewMerchantApplication(c, models.MerchantApplicationMoreInfoRequired)
+}
+
+func reviewMerchantApplication(c *gin.Context, status string) {
+	adminID := c.GetUint("user_id")
+	applicationID := c.Param("id")
+	fmt.Printf("🔄 Admin: reviewing merchant application %s -> %s\n", applicationID, status)
+
+	var req ReviewApplicationRequest
+	c.ShouldBindJSON(&req)
+
+	if status != models.MerchantApplicationApproved && strings.TrimSpace(req.Notes) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Notes are required to reject or request more information"})
+		return
+	}
+
+	var application models.MerchantApplication
+	if err := config.DB.Preload("User").First(&application, applicationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant application not found"})
+		return
+	}
+
+	if application.Status != models.MerchantApplicationPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending applications can be reviewed"})
+		return
+	}
+
+	if status == models.MerchantApplicationApproved {
+		var documents int64
+		config.DB.Model(&models.MerchantDocument{}).Where("application_id = ?", application.ID).Count(&documents)
+		if documents == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The application has no documents; request more information instead"})
+			return
+		}
+	}
+
+	before := application
+	now := time.Now()
+	application.Status = status
+	application.ReviewNotes = req.Notes
+	application.ReviewedByID = &adminID
+	application.ReviewedAt = &now
+
+	if err := config.DB.Save(&application).Error; err != nil {
+		fmt.Printf("❌ Failed to update merchant application: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update merchant application"})
+		return
+	}
+
+	// Notify the merchant in-app and by email
+	if _, err := services.Notifications.Notify(services.MerchantApplicationReviewedEvent(application)); err != nil {
+		fmt.Printf("⚠️ Failed to notify merchant: %v\n", err)
+	}
+
+	fmt.Printf("✅ Merchant application %d is now %s\n", application.ID, status)
+	middleware.RecordAudit(c, "merchant_application.review", "merchant_application", application.ID, before, application)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Merchant application updated successfully",
+		"application": application,
+	})
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+type InviteStaffRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type AcceptStaffInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type RedeemVoucherRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// merchantMembership loads the caller's merchant organisation membership and,
+// when roles are given, checks the caller holds one of them. It writes the
+// error response itself, so handlers just return when ok is false.
+func merchantMembership(c *gin.Context, roles ...string) (*models.MerchantMember, bool) {
+	userID := c.GetUint("user_id")
+
+	member, err := models.GetMerchantMembership(userID)
+	if err != nil {
+		fmt.Printf("❌ No merchant organisation for user %d: %v\n", userID, err)
+		// Admins hold deals.manage_own through the admin role but act on
+		// merchants' deals through /admin, never as the merchant
+		if c.GetString("role") == models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Merchant routes act as a merchant organisation; admins manage deals through /admin"})
+			return nil, false
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of a merchant organisation"})
+		return nil, false
+	}
+
+	if len(roles) > 0 && !member.HasRole(roles...) {
+		fmt.Printf("❌ Merchant staff role %s not allowed (need %v)\n", member.Role, roles)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":    "Your staff role does not allow this action",
+			"role":     member.Role,
+			"required": roles,
+		})
+		return nil, false
+	}
+
+	return member, true
+}
+
+// MERCHANT - Organisation with its members
+func GetMyOrganisation(c *gin.Context) {
+	member, ok := merchantMembership(c)
+	if !ok {
+		return
+	}
+
+	var organisation models.MerchantOrganisation
+	if err := config.DB.Preload("Members").Preload("Members.User").First(&organisation, member.OrganisationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organisation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organisation": organisation,
+		"my_role":      member.Role,
+	})
+}
+
+// MERCHANT - Invite a staff member. Owners can invite managers and cashiers,
+// managers can only invite cashiers.
+func InviteMerchantStaff(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	var req InviteStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role != models.MerchantRoleManager && req.Role != models.MerchantRoleCashier {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'manager' or 'cashier'"})
+		return
+	}
+	if member.Role == models.MerchantRoleManager && req.Role != models.MerchantRoleCashier {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Managers can only invite cashiers"})
+		return
+	}
+
+	var existingUser models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		var count int64
+		config.DB.Model(&models.MerchantMember{}).Where("user_id = ?", existingUser.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "This user already belongs to a merchant organisation"})
+			return
+		}
+	}
+
+	// Only one open invitation per email per organisation
+	now := time.Now()
+	config.DB.Model(&models.MerchantStaffInvitation{}).
+		Where("organisation_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", member.OrganisationID, req.Email).
+		Update("revoked_at", now)
+
+	invitation := models.MerchantStaffInvitation{
+		OrganisationID: member.OrganisationID,
+		Email:          req.Email,
+		Role:           req.Role,
+		InvitedByID:    member.UserID,
+	}
+	if err := invitation.GenerateToken(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
+		return
+	}
+
+	if err := config.DB.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	merchantURL := os.Getenv("MERCHANT_APP_URL")
+	if merchantURL == "" {
+		merchantURL = "http://localhost:3001"
+	}
+	inviteURL := fmt.Sprintf("%s/join-team?token=%s", merchantURL, invitation.Token)
+
+	emailService := services.NewEmailService()
+	if err := emailService.SendMerchantStaffInvitationEmail(invitation.Email, member.Organisation.Name, invitation.Role, inviteURL); err != nil {
+		fmt.Printf("❌ Failed to send staff invitation email: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation email"})
+		return
+	}
+
+	fmt.Printf("📧 Staff invitation (%s) sent to %s for organisation %d\n", invitation.Role, invitation.Email, invitation.OrganisationID)
+	middleware.RecordAudit(c, "merchant_invitation.create", "merchant_invitation", invitation.ID, nil, invitation)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation sent successfully",
+		"invitation": invitation,
+	})
+}
+
+func GetMerchantStaffInvitations(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	var invitations []models.MerchantStaffInvitation
+	if err := config.DB.Where("organisation_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", member.OrganisationID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func RevokeMerchantStaffInvitation(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+
+	var invitation models.MerchantStaffInvitation
+	if err := config.DB.Where("id = ? AND organisation_id = ?", c.Param("id"), member.OrganisationID).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	before := invitation
+	now := time.Now()
+	invitation.RevokedAt = &now
+	if err := config.DB.Save(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	middleware.RecordAudit(c, "merchant_invitation.revoke", "merchant_invitation", invitation.ID, before, invitation)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// Any signed-in user - join the organisation that invited them
+func AcceptMerchantStaffInvitation(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req AcceptStaffInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var invitation models.MerchantStaffInvitation
+	if err := config.DB.Where("token = ?", req.Token).First(&invitation).Error; err != nil || !invitation.IsPending() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This invitation was sent to a different email address"})
+		return
+	}
+
+	if user.Role == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admin accounts cannot join a merchant organisation"})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.MerchantMember{}).Where("user_id = ?", userID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already belong to a merchant organisation"})
+		return
+	}
+
+	// Merchants from before organisations have no membership row yet, but
+	// their deals, vouchers and reports are keyed by their own user ID and
+	// would be left without an organisation
+	var deals int64
+	config.DB.Unscoped().Model(&models.Deal{}).Where("merchant_id = ?", userID).Count(&deals)
+	if deals > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You run your own merchant business, so you cannot join another one as staff"})
+		return
+	}
+
+	// Staff use the merchant app, so they need the merchant role
+	roleChanged := user.Role != models.RoleMerchant
+
+	member := models.MerchantMember{
+		OrganisationID:      invitation.OrganisationID,
+		UserID:              userID,
+		Role:                invitation.Role,
+		GrantedMerchantRole: roleChanged,
+	}
+	if err := config.DB.Create(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join organisation"})
+		return
+	}
+
+	now := time.Now()
+	invitation.AcceptedAt = &now
+	config.DB.Save(&invitation)
+
+	if roleChanged {
+		user.Role = models.RoleMerchant
+		config.DB.Save(&user)
+	}
+
+	response := gin.H{
+		"message": "You have joined the merchant team",
+		"member":  member,
+	}
+
+	// The role is baked into the JWT, so hand out a fresh one
+	if roleChanged {
+		token, err := generateJWT(c, user.ID, user.Role)
+		if err == nil {
+			response["token"] = token
+		}
+	}
+
+	fmt.Printf("✅ User %d joined organisation %d as %s\n", userID, member.OrganisationID, member.Role)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// MERCHANT - Change a member's role (owner only)
+func UpdateMerchantMember(c *gin.Context) {
+	owner, ok := merchantMembership(c, models.MerchantRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role != models.MerchantRoleManager && req.Role != models.MerchantRoleCashier {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'manager' or 'cashier'"})
+		return
+	}
+
+	var member models.MerchantMember
+	if err := config.DB.Where("id = ? AND organisation_id = ?", c.Param("id"), owner.OrganisationID).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if member.Role == models.MerchantRoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner's role cannot be changed"})
+		return
+	}
+
+	before := member
+	member.Role = req.Role
+	if err := config.DB.Save(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	middleware.RecordAudit(c, "merchant_member.update", "merchant_member", member.ID, before, member)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member updated successfully",
+		"member":  member,
+	})
+}
+
+// MERCHANT - Remove a member from the organisation (owner only)
+func RemoveMerchantMember(c *gin.Context) {
+	owner, ok := merchantMembership(c, models.MerchantRoleOwner)
+	if !ok {
+		return
+	}
+
+	var member models.MerchantMember
+	if err := config.DB.Where("id = ? AND organisation_id = ?", c.Param("id"), owner.OrganisationID).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if member.Role == models.MerchantRoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot be removed"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		// Staff who became merchants by joining go back to being customers,
+		// otherwise their next merchant request would make them an owner
+		if member.GrantedMerchantRole {
+			return tx.Model(&models.User{}).Where("id = ?", member.UserID).Update("role", models.RoleCustomer).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	middleware.RecordAudit(c, "merchant_member.remove", "merchant_member", member.ID, member, nil)
+
+	// Their tokens carry the merchant role, so log them out
+	if member.GrantedMerchantRole {
+		if _, err := models.RevokeUserSessions(member.UserID, "removed from merchant organisation"); err != nil {
+			fmt.Printf("⚠️ Failed to end sessions of user %d: %v\n", member.UserID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// MERCHANT - Look up a voucher before redeeming it (any staff role)
+func GetMerchantVoucher(c *gin.Context) {
+	member, ok := merchantMembership(c)
+	if !ok {
+		return
+	}
+
+	var voucher models.Voucher
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	if err := config.DB.Preload("Deal").Where("code = ? AND merchant_id = ?", code, member.MerchantID()).First(&voucher).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Voucher not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"voucher": voucher})
+}
+
+// MERCHANT - Redeem a customer's voucher (any staff role)
+func RedeemVoucher(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager, models.MerchantRoleCashier)
+	if !ok {
+		return
+	}
+
+	var req RedeemVoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	fmt.Printf("🔄 RedeemVoucher %s by user %d (%s)\n", code, member.UserID, member.Role)
+
+	var voucher models.Voucher
+	if err := config.DB.Preload("Deal").Where("code = ? AND merchant_id = ?", code, member.MerchantID()).First(&voucher).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Voucher not found"})
+		return
+	}
+
+	if voucher.Status == models.VoucherStatusRedeemed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       "Voucher has already been redeemed",
+			"redeemed_at": voucher.RedeemedAt,
+		})
+		return
+	}
+	if voucher.Status == models.VoucherStatusVoid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Voucher was cancelled because the purchase was refunded"})
+		return
+	}
+
+	// Conditional update so two cashiers can't redeem the same voucher
+	now := time.Now()
+	result := config.DB.Model(&models.Voucher{}).
+		Where("id = ? AND status = ?", voucher.ID, models.VoucherStatusActive).
+		Updates(map[string]interface{}{
+			"status":         models.VoucherStatusRedeemed,
+			"redeemed_at":    now,
+			"redeemed_by_id": member.UserID,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem voucher"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Voucher has already been redeemed"})
+		return
+	}
+
+	before := voucher
+	voucher.Status = models.VoucherStatusRedeemed
+	voucher.RedeemedAt = &now
+	voucher.RedeemedByID = &member.UserID
+	middleware.RecordAudit(c, "voucher.redeem", "voucher", voucher.ID, before, voucher)
+
+	fmt.Printf("✅ Voucher %s redeemed\n", code)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Voucher redeemed successfully",
+		"voucher": voucher,
+	})
+}
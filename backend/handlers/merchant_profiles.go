@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+)
+
+type MerchantProfileRequest struct {
+	DisplayName  string            `json:"display_name" binding:"required"`
+	LogoURL      string            `json:"logo_url"`
+	CoverURL     string            `json:"cover_url"`
+	Description  string            `json:"description"`
+	PublicPhone  string            `json:"public_phone"`
+	PublicEmail  string            `json:"public_email"`
+	Website      string            `json:"website"`
+	OpeningHours map[string]string `json:"opening_hours"`
+	Branches     []models.Branch   `json:"branches"`
+	SocialLinks  map[string]string `json:"social_links"`
+}
+
+// MERCHANT - Get own business profile
+func GetMyMerchantProfile(c *gin.Context) {
+	member, ok := merchantMembership(c)
+	if !ok {
+		return
+	}
+
+	var profile models.MerchantProfile
+	if err := config.DB.Where("user_id = ?", member.MerchantID()).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile": profile})
+}
+
+// MERCHANT - Create or update own business profile
+func UpdateMyMerchantProfile(c *gin.Context) {
+	member, ok := merchantMembership(c, models.MerchantRoleOwner, models.MerchantRoleManager)
+	if !ok {
+		return
+	}
+	userID := member.MerchantID()
+	fmt.Printf("🔄 UpdateMyMerchantProfile called by merchant %d\n", userID)
+
+	var req MerchantProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var profile models.MerchantProfile
+	isNew := config.DB.Where("user_id = ?", userID).First(&profile).Error != nil
+
+	var before interface{}
+	if !isNew {
+		before = profile
+	}
+
+	nameChanged := profile.DisplayName != req.DisplayName
+	profile.UserID = userID
+	profile.DisplayName = req.DisplayName
+	profile.LogoURL = req.LogoURL
+	profile.CoverURL = req.CoverURL
+	profile.Description = req.Description
+	profile.PublicPhone = req.PublicPhone
+	profile.PublicEmail = req.PublicEmail
+	profile.Website = req.Website
+	profile.OpeningHours = models.StringMap(req.OpeningHours)
+	profile.Branches = models.BranchList(req.Branches)
+	profile.SocialLinks = models.StringMap(req.SocialLinks)
+
+	if isNew || nameChanged {
+		profile.GenerateSlug()
+	}
+
+	if err := config.DB.Save(&profile).Error; err != nil {
+		fmt.Printf("❌ Failed to save merchant profile: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save merchant profile"})
+		return
+	}
+
+	fmt.Printf("✅ Merchant profile saved: %s\n", profile.Slug)
+	middleware.RecordAudit(c, "merchant_profile.update", "merchant_profile", profile.ID, before, profile)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Merchant profile saved successfully",
+		"profile": profile,
+	})
+}
+
+// PUBLIC - Merchant storefront with live deals, by slug or merchant ID
+func GetMerchantStorefront(c *gin.Context) {
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With")
+
+	identifier := c.Param("slug")
+
+	var profile models.MerchantProfile
+	query := config.DB.Where("slug = ?", identifier)
+	if id, err := strconv.ParseUint(identifier, 10, 64); err == nil {
+		query = config.DB.Where("slug = ? OR user_id = ?", identifier, id)
+	}
+
+	if err := query.First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+		return
+	}
+
+	var deals []models.Deal
+	if err := config.DB.Preload("Category").
+		Where("merchant_id = ? AND status = ? AND is_active = ? AND end_date > ?", profile.UserID, "approved", true, time.Now()).
+		Order("created_at DESC").
+		Find(&deals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deals"})
+		return
+	}
+	recordImpressions(deals)
+
+	c.JSON(http.StatusOK, gin.H{
+		"merchant": profile,
+		"deals":    toPublicDeals(deals),
+	})
+}
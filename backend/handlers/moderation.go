@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// maxBulkModeration caps the deals in one bulk approve or reject
+const maxBulkModeration = 100
+
+var (
+	errDealNotFound   = errors.New("Deal not found")
+	errDealNotPending = errors.New("Deal is no longer pending")
+	errDealClaimed    = errors.New("Another admin is reviewing this deal")
+	errDealBlocked    = errors.New("Deal fails automated content checks")
+)
+
+type BulkModerationItem struct {
+	DealID   uint   `json:"deal_id" binding:"required"`
+	Reason   string `json:"reason"`
+	ReasonID *uint  `json:"reason_id"`
+}
+
+type BulkModerationRequest struct {
+	DealIDs  []uint               `json:"deal_ids"`
+	Reason   string               `json:"reason"`    // shared by deal_ids
+	ReasonID *uint                `json:"reason_id"` // shared canned reason
+	Items    []BulkModerationItem `json:"items"`     // deals with their own reason
+}
+
+type ClaimDealsRequest struct {
+	Count int `json:"count"`
+}
+
+type AssignDealRequest struct {
+	AdminID uint `json:"admin_id" binding:"required"`
+}
+
+type RejectionReasonRequest struct {
+	Title     *string `json:"title"`
+	Message   *string `json:"message"`
+	SortOrder *int    `json:"sort_order"`
+	IsActive  *bool   `json:"is_active"`
+}
+
+// moderationOutcome reports what happened to one deal in a bulk action
+type moderationOutcome struct {
+	DealID  uint   `json:"deal_id"`
+	Outcome string `json:"outcome"` // approved, rejected, failed or skipped
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// moderateDeal sets a deal's status to approved or rejected unless another
+// admin holds a live claim on it, releases the claim and notifies the
+// merchant. With pendingOnly, deals already reviewed are left alone.
+func moderateDeal(adminID, dealID uint, status, reason string, pendingOnly bool) (models.Deal, error) {
+	var deal models.Deal
+	if err := config.DB.First(&deal, dealID).Error; err != nil {
+		return deal, errDealNotFound
+	}
+	if pendingOnly && deal.Status != "pending" {
+		return deal, errDealNotPending
+	}
+	if status == "approved" && deal.CheckBlocked {
+		return deal, errDealBlocked
+	}
+
+	// Conditional so a deal claimed a moment ago by someone else is skipped
+	query := models.ClaimableBy(config.DB.Model(&models.Deal{}).Where("id = ?", deal.ID), adminID)
+	if pendingOnly {
+		query = query.Where("status = ?", "pending")
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":           status,
+		"rejection_reason": reason,
+		"reviewed_by_id":   adminID,
+		"reviewed_at":      time.Now(),
+		"claimed_by_id":    nil,
+		"claimed_at":       nil,
+	})
+	if result.Error != nil {
+		return deal, result.Error
+	}
+
+	config.DB.Preload("Merchant").First(&deal, deal.ID)
+	if result.RowsAffected == 0 {
+		if pendingOnly && deal.Status != "pending" {
+			return deal, errDealNotPending
+		}
+		return deal, errDealClaimed
+	}
+
+	event := services.DealApprovedEvent(deal)
+	if status == "rejected" {
+		event = services.DealRejectedEvent(deal, reason)
+	}
+	if _, err := services.Notifications.Notify(event); err != nil {
+		fmt.Printf("⚠️ Failed to notify merchant: %v\n", err)
+	}
+
+	return deal, nil
+}
+
+// respondModerationError maps moderateDeal errors for the single-deal
+// endpoints
+func respondModerationError(c *gin.Context, deal models.Deal, err error) {
+	switch {
+	case errors.Is(err, errDealNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errDealNotPending):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errDealBlocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "findings": deal.CheckFindings})
+	case errors.Is(err, errDealClaimed):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":            err.Error(),
+			"claimed_by_id":    deal.ClaimedByID,
+			"claim_expires_at": deal.ClaimExpiresAt(),
+		})
+	default:
+		fmt.Printf("❌ Failed to moderate deal %d: %v\n", deal.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deal"})
+	}
+}
+
+// resolveRejectionReason combines a canned reason with the admin's own note
+func resolveRejectionReason(reasonID *uint, note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if reasonID == nil {
+		return note, nil
+	}
+
+	var canned models.RejectionReason
+	if err := config.DB.Where("is_active = ?", true).First(&canned, *reasonID).Error; err != nil {
+		return "", fmt.Errorf("Unknown rejection reason %d", *reasonID)
+	}
+	if note == "" {
+		return canned.Message, nil
+	}
+	return canned.Message + " " + note, nil
+}
+
+// ADMIN - Approve several pending deals at once
+func BulkApproveDeals(c *gin.Context) {
+	bulkModerateDeals(c, "approved")
+}
+
+// ADMIN - Reject several pending deals at once. Every deal needs a reason,
+// either its own in items or the shared reason / reason_id.
+func BulkRejectDeals(c *gin.Context) {
+	bulkModerateDeals(c, "rejected")
+}
+
+func bulkModerateDeals(c *gin.Context, status string) {
+	adminID := c.GetUint("user_id")
+
+	var req BulkModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items := req.Items
+	for _, dealID := range req.DealIDs {
+		items = append(items, BulkModerationItem{DealID: dealID, Reason: req.Reason, ReasonID: req.ReasonID})
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No deals given"})
+		return
+	}
+	if len(items) > maxBulkModeration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d deals can be moderated at once", maxBulkModeration)})
+		return
+	}
+
+	fmt.Printf("🔄 Admin %d: bulk %s of %d deals\n", adminID, status, len(items))
+
+	results := make([]moderationOutcome, 0, len(items))
+	summary := map[string]int{status: 0, "failed": 0, "skipped": 0}
+	seen := make(map[uint]bool)
+
+	for _, item := range items {
+		outcome := moderationOutcome{DealID: item.DealID}
+
+		if seen[item.DealID] {
+			outcome.Outcome = "skipped"
+			outcome.Error = "Listed more than once"
+			results = append(results, outcome)
+			summary[outcome.Outcome]++
+			continue
+		}
+		seen[item.DealID] = true
+
+		reason := ""
+		if status == "rejected" {
+			var err error
+			if reason, err = resolveRejectionReason(item.ReasonID, item.Reason); err != nil {
+				outcome.Outcome = "failed"
+				outcome.Error = err.Error()
+			} else if reason == "" {
+				outcome.Outcome = "failed"
+				outcome.Error = "A rejection reason is required"
+			}
+		}
+
+		if outcome.Outcome == "" {
+			if _, err := moderateDeal(adminID, item.DealID, status, reason, true); err != nil {
+				outcome.Outcome = "failed"
+				outcome.Error = err.Error()
+				if !errors.Is(err, errDealNotFound) && !errors.Is(err, errDealNotPending) &&
+					!errors.Is(err, errDealClaimed) && !errors.Is(err, errDealBlocked) {
+					fmt.Printf("❌ Failed to moderate deal %d: %v\n", item.DealID, err)
+					outcome.Error = "Failed to update deal"
+				}
+			} else {
+				outcome.Outcome = status
+				outcome.Reason = reason
+			}
+		}
+
+		results = append(results, outcome)
+		summary[outcome.Outcome]++
+	}
+
+	fmt.Printf("✅ Bulk %s: %d done, %d failed\n", status, summary[status], summary["failed"])
+	action := "deal.bulk_approve"
+	if status == "rejected" {
+		action = "deal.bulk_reject"
+	}
+	middleware.RecordAudit(c, action, "deal", "", nil, results)
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": summary,
+	})
+}
+
+// ADMIN - Claim the oldest unclaimed pending deals for review
+func ClaimNextDeals(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req ClaimDealsRequest
+	c.ShouldBindJSON(&req)
+	if req.Count <= 0 {
+		req.Count = 10
+	}
+	if req.Count > 50 {
+		req.Count = 50
+	}
+
+	// Deals blocked by content checks wait for the merchant, not a reviewer
+	unclaimed := "status = ? AND check_blocked = ? AND (claimed_by_id IS NULL OR claimed_at < ?)"
+
+	var candidates []uint
+	config.DB.Model(&models.Deal{}).
+		Where(unclaimed, "pending", false, models.DealClaimCutoff()).
+		Order("created_at ASC").
+		Limit(req.Count).
+		Pluck("id", &candidates)
+
+	// Claim one at a time so deals another admin grabs meanwhile are skipped
+	now := time.Now()
+	var claimed []uint
+	for _, id := range candidates {
+		result := config.DB.Model(&models.Deal{}).
+			Where("id = ?", id).
+			Where(unclaimed, "pending", false, models.DealClaimCutoff()).
+			Updates(map[string]interface{}{"claimed_by_id": adminID, "claimed_at": now})
+		if result.Error == nil && result.RowsAffected == 1 {
+			claimed = append(claimed, id)
+		}
+	}
+
+	deals := []models.Deal{}
+	if len(claimed) > 0 {
+		config.DB.Preload("Category").Preload("Merchant").
+			Where("id IN ?", claimed).
+			Order("created_at ASC").
+			Find(&deals)
+	}
+
+	fmt.Printf("✅ Admin %d claimed %d deals\n", adminID, len(deals))
+	middleware.RecordAudit(c, "deal.claim", "deal", "", nil, claimed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"deals":            deals,
+		"claimed":          len(deals),
+		"claim_expires_at": now.Add(models.DealClaimDuration),
+	})
+}
+
+// ADMIN - Claim one pending deal, or refresh your own claim on it
+func ClaimDeal(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var deal models.Deal
+	if err := config.DB.First(&deal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
+		return
+	}
+	if deal.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending deals can be claimed"})
+		return
+	}
+
+	now := time.Now()
+	result := models.ClaimableBy(config.DB.Model(&models.Deal{}).Where("id = ? AND status = ?", deal.ID, "pending"), adminID).
+		Updates(map[string]interface{}{"claimed_by_id": adminID, "claimed_at": now})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim deal"})
+		return
+	}
+
+	config.DB.Preload("ClaimedBy").First(&deal, deal.ID)
+	if result.RowsAffected == 0 {
+		respondModerationError(c, deal, errDealClaimed)
+		return
+	}
+
+	middleware.RecordAudit(c, "deal.claim", "deal", deal.ID, nil, gin.H{"claimed_by_id": adminID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Deal claimed",
+		"deal":             deal,
+		"claim_expires_at": deal.ClaimExpiresAt(),
+	})
+}
+
+// ADMIN - Give up your claim on a deal so someone else can review it
+func ReleaseDealClaim(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	result := config.DB.Model(&models.Deal{}).
+		Where("id = ? AND claimed_by_id = ?", c.Param("id"), adminID).
+		Updates(map[string]interface{}{"claimed_by_id": nil, "claimed_at": nil})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release deal"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You haven't claimed this deal"})
+		return
+	}
+
+	middleware.RecordAudit(c, "deal.release", "deal", c.Param("id"), gin.H{"claimed_by_id": adminID}, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deal released"})
+}
+
+// ADMIN - Hand a pending deal to a specific admin, replacing any claim
+func AssignDeal(c *gin.Context) {
+	var req AssignDealRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var deal models.Deal
+	if err := config.DB.First(&deal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deal not found"})
+		return
+	}
+	if deal.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending deals can be assigned"})
+		return
+	}
+
+	canApprove, _ := models.UserHasPermissions(req.AdminID, models.PermissionDealsApprove)
+	canReject, _ := models.UserHasPermissions(req.AdminID, models.PermissionDealsReject)
+	if !canApprove && !canReject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "That user can't moderate deals"})
+		return
+	}
+
+	before := gin.H{"claimed_by_id": deal.ClaimedByID}
+	if err := config.DB.Model(&models.Deal{}).Where("id = ?", deal.ID).
+		Updates(map[string]interface{}{"claimed_by_id": req.AdminID, "claimed_at": time.Now()}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign deal"})
+		return
+	}
+
+	config.DB.Preload("ClaimedBy").First(&deal, deal.ID)
+	middleware.RecordAudit(c, "deal.assign", "deal", deal.ID, before, gin.H{"claimed_by_id": req.AdminID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Deal assigned",
+		"deal":             deal,
+		"claim_expires_at": deal.ClaimExpiresAt(),
+	})
+}
+
+// ADMIN - Canned rejection reasons, active ones only unless ?all=true
+func GetRejectionReasons(c *gin.Context) {
+	query := config.DB.Order("sort_order ASC, title ASC")
+	if c.Query("all") != "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var reasons []models.RejectionReason
+	if err := query.Find(&reasons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rejection reasons"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reasons": reasons})
+}
+
+func applyRejectionReasonRequest(reason *models.RejectionReason, req RejectionReasonRequest) string {
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return "Title is required"
+		}
+		var count int64
+		config.DB.Model(&models.RejectionReason{}).Where("title = ? AND id <> ?", title, reason.ID).Count(&count)
+		if count > 0 {
+			return "A rejection reason with this title already exists"
+		}
+		reason.Title = title
+	}
+	if req.Message != nil {
+		message := strings.TrimSpace(*req.Message)
+		if message == "" {
+			return "Message is required"
+		}
+		reason.Message = message
+	}
+	if req.SortOrder != nil {
+		reason.SortOrder = *req.SortOrder
+	}
+	if req.IsActive != nil {
+		reason.IsActive = *req.IsActive
+	}
+	return ""
+}
+
+// ADMIN - Add a canned rejection reason
+func CreateRejectionReason(c *gin.Context) {
+	var req RejectionReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title == nil || req.Message == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title and message are required"})
+		return
+	}
+
+	reason := models.RejectionReason{IsActive: true}
+	if message := applyRejectionReasonRequest(&reason, req); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	if err := config.DB.Create(&reason).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rejection reason"})
+		return
+	}
+
+	middleware.RecordAudit(c, "rejection_reason.create", "rejection_reason", reason.ID, nil, reason)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Rejection reason created",
+		"reason":  reason,
+	})
+}
+
+// ADMIN - Edit or deactivate a canned rejection reason
+func UpdateRejectionReason(c *gin.Context) {
+	var reason models.RejectionReason
+	if err := config.DB.First(&reason, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rejection reason not found"})
+		return
+	}
+
+	var req RejectionReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := reason
+	if message := applyRejectionReasonRequest(&reason, req); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	if err := config.DB.Save(&reason).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rejection reason"})
+		return
+	}
+
+	middleware.RecordAudit(c, "rejection_reason.update", "rejection_reason", reason.ID, before, reason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Rejection reason updated",
+		"reason":  reason,
+	})
+}
+
+// ADMIN - Delete a canned rejection reason. Rejected deals keep the text
+// they were sent.
+func DeleteRejectionReason(c *gin.Context) {
+	var reason models.RejectionReason
+	if err := config.DB.First(&reason, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rejection reason not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&reason).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rejection reason"})
+		return
+	}
+
+	middleware.RecordAudit(c, "rejection_reason.delete", "rejection_reason", reason.ID, reason, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rejection reason deleted"})
+}
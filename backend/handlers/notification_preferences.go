@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+type NotificationPreferenceRequest struct {
+	Type    string `json:"type" binding:"required"`
+	Channel string `json:"channel" binding:"required"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	prefs, err := models.LoadNotificationPreferences(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": prefs.Settings(),
+		"channels":    models.NotificationChannels,
+	})
+}
+
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fmt.Printf("🔄 UpdateNotificationPreferences called by user %d\n", userID)
+
+	var req struct {
+		Preferences []NotificationPreferenceRequest `json:"preferences" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Validate everything before saving anything
+	allowedTypes := models.NotificationTypesForRole(user.Role)
+	for _, pref := range req.Preferences {
+		if !models.IsNotificationChannel(pref.Channel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification channel: " + pref.Channel})
+			return
+		}
+		if !stringInSlice(allowedTypes, pref.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type: " + pref.Type})
+			return
+		}
+		if !*pref.Enabled && models.IsMandatoryNotification(pref.Type, pref.Channel) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   models.ErrMandatoryNotification.Error(),
+				"type":    pref.Type,
+				"channel": pref.Channel,
+			})
+			return
+		}
+	}
+
+	for _, pref := range req.Preferences {
+		if err := models.SetNotificationPreference(userID, pref.Type, pref.Channel, *pref.Enabled); err != nil {
+			fmt.Printf("❌ Failed to save notification preference: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save notification preferences"})
+			return
+		}
+	}
+
+	prefs, _ := models.LoadNotificationPreferences(user)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Notification preferences updated successfully",
+		"preferences": prefs.Settings(),
+	})
+}
+
+// PUBLIC - The page the unsubscribe link in emails opens. It only asks for
+// confirmation: mail scanners and prefetchers follow links, so a GET must not
+// change anything.
+func ConfirmUnsubscribeNotifications(c *gin.Context) {
+	token := c.Query("token")
+
+	_, notificationType, channel, err := services.ParseUnsubscribeToken(token)
+	if err != nil {
+		renderUnsubscribePage(c, http.StatusBadRequest, "This unsubscribe link is invalid.")
+		return
+	}
+
+	subject := strings.ReplaceAll(notificationType, "_", " ") + " " + strings.ReplaceAll(channel, "_", " ") + " notifications"
+	if report, ok := models.ParseReportUnsubscribeType(notificationType); ok {
+		subject = "the " + report + " report"
+		if definition, ok := models.GetReportDefinition(report); ok {
+			subject = "the " + definition.Name + " report"
+		}
+	}
+
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head><title>Snapadeal</title></head>
+<body style="font-family: Arial, sans-serif; text-align: center; padding: 60px; color: #333;">
+    <h2>Snapadeal Uganda</h2>
+    <p>Unsubscribe from %s?</p>
+    <form method="POST" action="?token=%s">
+        <button type="submit" style="padding: 10px 24px; font-size: 16px;">Unsubscribe</button>
+    </form>
+</body>
+</html>`, html.EscapeString(subject), url.QueryEscape(token))
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// PUBLIC - Unsubscribe: the confirmation form posts here, and so does the
+// RFC 8058 List-Unsubscribe-Post one-click request from mail clients.
+func UnsubscribeNotifications(c *gin.Context) {
+	token := c.Query("token")
+
+	userID, notificationType, channel, err := services.ParseUnsubscribeToken(token)
+	if err != nil {
+		renderUnsubscribePage(c, http.StatusBadRequest, "This unsubscribe link is invalid.")
+		return
+	}
+
+	if report, ok := models.ParseReportUnsubscribeType(notificationType); ok {
+		if err := models.DisableReportSubscription(userID, report); err != nil {
+			fmt.Printf("❌ Failed to unsubscribe user %d from report %s: %v\n", userID, report, err)
+			renderUnsubscribePage(c, http.StatusInternalServerError, "Something went wrong, please try again later.")
+			return
+		}
+		fmt.Printf("✅ User %d unsubscribed from the %s report\n", userID, report)
+		renderUnsubscribePage(c, http.StatusOK, "You have been unsubscribed from this report. You can turn it back on from your profile.")
+		return
+	}
+
+	if err := models.SetNotificationPreference(userID, notificationType, channel, false); err != nil {
+		if err == models.ErrMandatoryNotification {
+			renderUnsubscribePage(c, http.StatusBadRequest, "These notifications are required for your account and cannot be turned off.")
+			return
+		}
+		fmt.Printf("❌ Failed to unsubscribe user %d: %v\n", userID, err)
+		renderUnsubscribePage(c, http.StatusInternalServerError, "Something went wrong, please try again later.")
+		return
+	}
+
+	fmt.Printf("✅ User %d unsubscribed from %s %s notifications\n", userID, notificationType, channel)
+
+	renderUnsubscribePage(c, http.StatusOK, "You have been unsubscribed. You can turn these notifications back on from your notification settings.")
+}
+
+func renderUnsubscribePage(c *gin.Context, status int, message string) {
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head><title>Snapadeal</title></head>
+<body style="font-family: Arial, sans-serif; text-align: center; padding: 60px; color: #333;">
+    <h2>Snapadeal Uganda</h2>
+    <p>%s</p>
+</body>
+</html>`, html.EscapeString(message))
+
+	c.Data(status, "text/html; charset=utf-8", []byte(page))
+}
+
+func stringInSlice(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
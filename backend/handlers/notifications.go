@@ -1,14 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"snapadeal/config"
 	"snapadeal/models"
+	"snapadeal/services"
 )
 
+// How often an idle stream sends a keep-alive comment
+const streamHeartbeatInterval = 25 * time.Second
+
+// Maximum notifications replayed to a reconnecting stream
+const streamReplayLimit = 100
+
 func GetNotifications(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	
@@ -53,6 +63,8 @@ func MarkNotificationAsRead(c *gin.Context) {
 		return
 	}
 
+	services.PublishUnreadCount(userID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
 }
 
@@ -64,6 +76,8 @@ func MarkAllNotificationsAsRead(c *gin.Context) {
 		return
 	}
 
+	services.PublishUnreadCount(userID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
 }
 
@@ -82,60 +96,95 @@ func DeleteNotification(c *gin.Context) {
 		return
 	}
 
+	services.PublishUnreadCount(userID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted successfully"})
 }
 
-// Admin-specific notification functions
-func BroadcastNotification(c *gin.Context) {
-	var req struct {
-		Title    string   `json:"title" binding:"required"`
-		Message  string   `json:"message" binding:"required"`
-		UserRole string   `json:"user_role"` // "all", "merchant", "customer"
-		UserIDs  []uint   `json:"user_ids"`  // specific user IDs
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	var users []models.User
-	query := config.DB.Select("id")
+// StreamNotifications pushes new notifications to the client as Server-Sent
+// Events. Reconnecting clients send Last-Event-ID (or ?last_event_id) and get
+// any notifications they missed replayed from the database first.
+func StreamNotifications(c *gin.Context) {
+	userID := c.GetUint("user_id")
 
-	// Filter users based on request
-	if len(req.UserIDs) > 0 {
-		query = query.Where("id IN ?", req.UserIDs)
-	} else if req.UserRole != "" && req.UserRole != "all" {
-		query = query.Where("role = ?", req.UserRole)
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
 	}
 
-	if err := query.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-		return
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering
+
+	// Subscribe before replaying so nothing created in between is lost
+	events, unsubscribe := services.Hub.Subscribe(userID)
+	defer unsubscribe()
+
+	fmt.Printf("📡 Notification stream opened for user %d (last event: %q)\n", userID, lastEventID)
+
+	// Retry after 3s if the connection drops
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+
+	lastSentID := uint64(0)
+	if lastID, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+		var missed []models.Notification
+		config.DB.Where("user_id = ? AND id > ?", userID, lastID).
+			Order("id ASC").Limit(streamReplayLimit).
+			Find(&missed)
+		for _, notification := range missed {
+			writeStreamEvent(c, services.StreamEvent{
+				ID:    strconv.FormatUint(uint64(notification.ID), 10),
+				Event: "notification",
+				Data:  notification,
+			})
+			lastSentID = uint64(notification.ID)
+		}
 	}
 
-	// Create notifications for all selected users
-	var notifications []models.Notification
-	for _, user := range users {
-		notifications = append(notifications, models.Notification{
-			UserID:  user.ID,
-			Title:   req.Title,
-			Message: req.Message,
-			Type:    models.NotificationSystemUpdate,
-		})
+	var unreadCount int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&unreadCount)
+	writeStreamEvent(c, services.StreamEvent{Event: "unread_count", Data: gin.H{"unread_count": unreadCount}})
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			fmt.Printf("📡 Notification stream closed for user %d\n", userID)
+			return
+		case event, ok := <-events:
+			if !ok {
+				fmt.Printf("📡 Notification stream closed for user %d - sessions ended\n", userID)
+				return
+			}
+			// Skip anything already sent during replay
+			if id, err := strconv.ParseUint(event.ID, 10, 64); err == nil && id <= lastSentID {
+				continue
+			}
+			writeStreamEvent(c, event)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		}
 	}
+}
 
-	if err := config.DB.Create(&notifications).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send notifications"})
+func writeStreamEvent(c *gin.Context, event services.StreamEvent) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Notifications sent successfully",
-		"count":   len(notifications),
-	})
+	if event.ID != "" {
+		fmt.Fprintf(c.Writer, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Event, data)
+	c.Writer.Flush()
 }
 
+// Admin-specific notification functions
 func GetNotificationStats(c *gin.Context) {
 	var stats struct {
 		TotalNotifications   int64 `json:"total_notifications"`
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// ReportSubscriptionView is a report the user can receive and their
+// subscription to it, if any
+type ReportSubscriptionView struct {
+	models.ReportDefinition
+	Subscribed bool       `json:"subscribed"`
+	Enabled    bool       `json:"enabled"`
+	Schedule   string     `json:"schedule"`
+	NextRunAt  *time.Time `json:"next_run_at"`
+	LastRunAt  *time.Time `json:"last_run_at"`
+}
+
+func reportSubscriptionViews(userID uint) ([]ReportSubscriptionView, error) {
+	var subscriptions []models.ReportSubscription
+	if err := config.DB.Where("user_id = ?", userID).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	byReport := make(map[string]models.ReportSubscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		byReport[subscription.Report] = subscription
+	}
+
+	views := []ReportSubscriptionView{}
+	for _, definition := range models.ReportDefinitions {
+		if _, err := services.ReportScope(userID, definition.Key); err != nil {
+			continue
+		}
+		view := ReportSubscriptionView{ReportDefinition: definition, Schedule: definition.DefaultSchedule}
+		if subscription, ok := byReport[definition.Key]; ok {
+			view.Subscribed = true
+			view.Enabled = subscription.Enabled
+			view.Schedule = subscription.Schedule
+			view.NextRunAt = subscription.NextRunAt
+			view.LastRunAt = subscription.LastRunAt
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// Reports the user can subscribe to, with their schedules
+func GetMyReports(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	views, err := reportSubscriptionViews(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": views})
+}
+
+// Subscribe to a report, change its schedule or switch it off. Schedules
+// are cron expressions in server time, e.g. "0 7 * * 1" for Mondays at 07:00.
+func UpdateMyReport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	report := c.Param("report")
+	fmt.Printf("🔄 UpdateMyReport called by user %d for %s\n", userID, report)
+
+	var req struct {
+		Enabled  *bool  `json:"enabled" binding:"required"`
+		Schedule string `json:"schedule"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definition, ok := models.GetReportDefinition(report)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if _, err := services.ReportScope(userID, report); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var subscription models.ReportSubscription
+	if err := config.DB.Where("user_id = ? AND report = ?", userID, report).First(&subscription).Error; err != nil {
+		subscription = models.ReportSubscription{UserID: userID, Report: report, Schedule: definition.DefaultSchedule}
+	}
+	if req.Schedule != "" {
+		subscription.Schedule = req.Schedule
+	}
+
+	next, err := services.NextReportRun(subscription.Schedule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription.Enabled = *req.Enabled
+	subscription.NextRunAt = nil
+	if subscription.Enabled {
+		subscription.NextRunAt = next
+	}
+	if err := config.DB.Save(&subscription).Error; err != nil {
+		fmt.Printf("❌ Failed to save report subscription: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save report subscription"})
+		return
+	}
+
+	views, _ := reportSubscriptionViews(userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Report subscription updated successfully",
+		"reports": views,
+	})
+}
+
+// Send a report now, covering the period since the last scheduled one
+func SendMyReport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	report := c.Param("report")
+	fmt.Printf("🔄 SendMyReport called by user %d for %s\n", userID, report)
+
+	var subscription models.ReportSubscription
+	if err := config.DB.Where("user_id = ? AND report = ?", userID, report).First(&subscription).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscribe to this report first"})
+		return
+	}
+	if _, err := services.ReportScope(userID, report); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var lastRun models.ReportRun
+	err := config.DB.Where(&models.ReportRun{SubscriptionID: subscription.ID, Trigger: models.ReportTriggerManual}).
+		Order("created_at DESC").First(&lastRun).Error
+	if err == nil {
+		if wait := models.ReportSendCooldown - time.Since(lastRun.CreatedAt); wait > 0 {
+			retryAfter := int(wait.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "This report was sent recently, please try again later",
+				"retry_after": retryAfter,
+			})
+			return
+		}
+	}
+
+	run := services.Reports.Run(subscription, models.ReportTriggerManual)
+	if run.Status != models.ReportRunSent {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send report", "run": run})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Report sent",
+		"run":     run,
+	})
+}
+
+// The user's report run history, newest first
+func GetMyReportRuns(c *gin.Context) {
+	query := config.DB.Model(&models.ReportRun{}).Where("user_id = ?", c.GetUint("user_id"))
+	respondReportRuns(c, query, false)
+}
+
+// ADMIN - Report run history for every recipient, filtered by report,
+// status and user_id
+func GetReportRuns(c *gin.Context) {
+	query := config.DB.Model(&models.ReportRun{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	respondReportRuns(c, query, true)
+}
+
+func respondReportRuns(c *gin.Context, query *gorm.DB, withUser bool) {
+	if report := c.Query("report"); report != "" {
+		query = query.Where("report = ?", report)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	if withUser {
+		query = query.Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, first_name, last_name, email, role")
+		})
+	}
+
+	var runs []models.ReportRun
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs": runs,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+)
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	RoleID uint `json:"role_id" binding:"required"`
+}
+
+// Admin - List all permissions
+func GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := config.DB.Order("key ASC").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+// Admin - List all roles with their permissions
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("name ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name is required"})
+		return
+	}
+
+	var existing models.Role
+	if err := config.DB.Unscoped().Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role with this name already exists"})
+		return
+	}
+
+	permissions, err := findPermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+
+	if err := config.DB.Create(&role).Error; err != nil {
+		fmt.Printf("❌ Failed to create role: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	fmt.Printf("✅ Role created: %s\n", role.Name)
+	middleware.RecordAudit(c, "role.create", "role", role.ID, nil, role)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Role created successfully",
+		"role":    role,
+	})
+}
+
+func UpdateRole(c *gin.Context) {
+	roleID := c.Param("id")
+
+	var role models.Role
+	if err := config.DB.First(&role, roleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if role.Name == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role always holds every permission and cannot be edited"})
+		return
+	}
+
+	var before models.Role
+	config.DB.Preload("Permissions").First(&before, role.ID)
+
+	// System roles keep their name since User.Role refers to them
+	if req.Name != "" && req.Name != role.Name {
+		if role.IsSystem {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "System roles cannot be renamed"})
+			return
+		}
+		role.Name = req.Name
+	}
+	if req.Description != "" {
+		role.Description = req.Description
+	}
+
+	if err := config.DB.Save(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if req.Permissions != nil {
+		permissions, err := findPermissions(req.Permissions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := config.DB.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+			return
+		}
+	}
+
+	config.DB.Preload("Permissions").First(&role, role.ID)
+
+	fmt.Printf("✅ Role updated: %s\n", role.Name)
+	middleware.RecordAudit(c, "role.update", "role", role.ID, before, role)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+		"role":    role,
+	})
+}
+
+func DeleteRole(c *gin.Context) {
+	roleID := c.Param("id")
+
+	var role models.Role
+	if err := config.DB.First(&role, roleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "System roles cannot be deleted"})
+		return
+	}
+
+	if err := config.DB.Exec("DELETE FROM user_roles WHERE role_id = ?", role.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role assignments"})
+		return
+	}
+
+	if err := config.DB.Delete(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	middleware.RecordAudit(c, "role.delete", "role", role.ID, role, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// Admin - Get a user's roles and effective permissions
+func GetUserRoles(c *gin.Context) {
+	var user models.User
+	if err := config.DB.Preload("Roles").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	permissions, err := models.GetUserPermissions(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":        user.ID,
+		"role":           user.Role,
+		"assigned_roles": user.Roles,
+		"permissions":    permissions,
+	})
+}
+
+func AssignUserRole(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.First(&role, req.RoleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Association("Roles").Append(&role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+
+	fmt.Printf("✅ Role %s assigned to user %d\n", role.Name, user.ID)
+	middleware.RecordAudit(c, "user.role_assign", "user", user.ID, nil, gin.H{"role_id": role.ID, "role": role.Name})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
+}
+
+func RemoveUserRole(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("roleId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Association("Roles").Delete(&role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		return
+	}
+	middleware.RecordAudit(c, "user.role_remove", "user", user.ID, gin.H{"role_id": role.ID, "role": role.Name}, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed successfully"})
+}
+
+// Current user's effective permissions, used by the frontends to hide actions
+func GetMyPermissions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	permissions, err := models.GetUserPermissions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func findPermissions(keys []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(keys) == 0 {
+		return permissions, nil
+	}
+
+	if err := config.DB.Where("key IN ?", keys).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	if len(permissions) != len(keys) {
+		found := make(map[string]bool)
+		for _, p := range permissions {
+			found[p.Key] = true
+		}
+		for _, key := range keys {
+			if !found[key] {
+				return nil, fmt.Errorf("Unknown permission: %s", key)
+			}
+		}
+	}
+
+	return permissions, nil
+}
@@ -10,8 +10,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"snapadeal/config"
+	"snapadeal/middleware"
 	"snapadeal/models"
+	"snapadeal/services"
 )
 
 type CreateTransactionRequest struct {
@@ -128,6 +131,7 @@ func CreateTransaction(c *gin.Context) {
 	}
 
 	fmt.Printf("✅ Transaction created with ID: %d\n", transaction.ID)
+	services.Funnel.Record(models.FunnelCheckoutStart, transaction.DealID)
 
 	// Check Flutterwave configuration
 	flwPubKey := os.Getenv("FLUTTERWAVE_PUBLIC_KEY")
@@ -143,13 +147,20 @@ func CreateTransaction(c *gin.Context) {
 		paymentURL, err = createFlutterwavePayment(transaction, user, deal)
 		if err != nil {
 			fmt.Printf("❌ Flutterwave payment creation failed: %v\n", err)
-			fmt.Printf("🔄 Falling back to simulation mode\n")
-			paymentURL = fmt.Sprintf("http://localhost:3000/payment/verify?transaction_id=%d&simulate=true", transaction.ID)
 		} else {
 			fmt.Printf("✅ Flutterwave payment URL created: %s\n", paymentURL)
 		}
 	} else {
-		fmt.Printf("⚠️  Flutterwave keys not configured - using simulation\n")
+		fmt.Printf("⚠️  Flutterwave keys not configured\n")
+	}
+
+	if paymentURL == "" {
+		if !paymentSimulationEnabled() {
+			failTransaction(transaction.ID)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are unavailable right now, please try again later"})
+			return
+		}
+		fmt.Printf("🔄 Falling back to simulation mode\n")
 		paymentURL = fmt.Sprintf("http://localhost:3000/payment/verify?transaction_id=%d&simulate=true", transaction.ID)
 	}
 
@@ -248,7 +259,7 @@ func GetUserTransactions(c *gin.Context) {
 	fmt.Printf("🔄 GetUserTransactions called for user %d\n", userID)
 
 	var transactions []models.Transaction
-	query := config.DB.Preload("Deal").Preload("Deal.Category").Where("user_id = ?", userID)
+	query := config.DB.Preload("Deal").Preload("Deal.Category").Preload("Vouchers").Where("user_id = ?", userID)
 
 	// Pagination
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -287,61 +298,40 @@ func VerifyPayment(c *gin.Context) {
 		return
 	}
 
-	// If this is a simulation (for development)
+	// Simulated payments are for development without Flutterwave only
 	if c.Query("simulate") == "true" {
+		if !paymentSimulationEnabled() {
+			fmt.Printf("❌ Payment simulation requested for transaction %s but it is disabled\n", transactionID)
+			c.Redirect(http.StatusFound, fmt.Sprintf("http://localhost:3000/payment/success?transaction_id=%d&status=%s&error=simulation_disabled", transaction.ID, transaction.Status))
+			return
+		}
 		fmt.Printf("🔄 Processing simulation payment for transaction %s\n", transactionID)
 		simulatePaymentCompletion(c, transaction)
 		return
 	}
 
-	// Verify with Flutterwave if we have a tx_ref
-	if txRef != "" {
+	// Only a payment Flutterwave confirms for this transaction completes it.
+	// The status in the query string is whatever the browser was sent back
+	// with, so it is never trusted on its own.
+	if txRef == "" || txRef != transaction.PaymentReference {
+		fmt.Printf("❌ tx_ref %q does not match transaction %d - not verifying\n", txRef, transaction.ID)
+	} else {
 		fmt.Printf("🔄 Verifying payment with Flutterwave for tx_ref: %s\n", txRef)
-		verified, err := verifyFlutterwavePayment(txRef)
+		verified, err := verifyFlutterwavePayment(txRef, transaction.Amount)
 		if err != nil {
+			// Leave it pending, a later callback or retry can still verify it
 			fmt.Printf("❌ Payment verification failed: %v\n", err)
-			status = "failed"
 		} else if verified {
-			status = "successful"
 			fmt.Printf("✅ Flutterwave payment verified successfully\n")
+			completeTransaction(transaction, txRef)
 		} else {
-			status = "failed"
 			fmt.Printf("❌ Flutterwave payment verification failed\n")
+			failTransaction(transaction.ID)
 		}
 	}
 
-	// Update transaction status
-	if status == "successful" || status == "completed" {
-		transaction.Status = "completed"
-		if txRef != "" {
-			transaction.PaymentReference = txRef
-		}
-
-		// Update deal sold quantity
-		var deal models.Deal
-		if err := config.DB.First(&deal, transaction.DealID).Error; err == nil {
-			deal.SoldQuantity += transaction.Quantity
-			config.DB.Save(&deal)
-
-			// Create notification for merchant
-			notification := models.Notification{
-				UserID:  deal.MerchantID,
-				Title:   "New Sale! 💰",
-				Message: fmt.Sprintf("Your deal '%s' was purchased by a customer!", deal.Title),
-				Type:    models.NotificationDealPurchased,
-				Data:    fmt.Sprintf(`{"deal_id": %d, "transaction_id": %d, "quantity": %d}`, deal.ID, transaction.ID, transaction.Quantity),
-			}
-			config.DB.Create(&notification)
-		}
-	} else {
-		transaction.Status = "failed"
-	}
-
-	if err := config.DB.Save(&transaction).Error; err != nil {
-		fmt.Printf("❌ Failed to update transaction: %v\n", err)
-	}
-
-	fmt.Printf("✅ Transaction %d status updated to: %s\n", transaction.ID, transaction.Status)
+	config.DB.Select("status").First(&transaction, transaction.ID)
+	fmt.Printf("✅ Transaction %d status is: %s\n", transaction.ID, transaction.Status)
 
 	// Redirect to success page with proper parameters
 	redirectURL := fmt.Sprintf("http://localhost:3000/payment/success?transaction_id=%d&status=%s", transaction.ID, transaction.Status)
@@ -353,7 +343,67 @@ func VerifyPayment(c *gin.Context) {
 	c.Redirect(http.StatusFound, redirectURL)
 }
 
-func verifyFlutterwavePayment(txRef string) (bool, error) {
+// paymentSimulationEnabled reports whether purchases may be completed
+// without a payment provider. Set PAYMENT_SIMULATION=true in development only.
+func paymentSimulationEnabled() bool {
+	return os.Getenv("PAYMENT_SIMULATION") == "true"
+}
+
+// completeTransaction moves a pending transaction to completed and, only
+// for the call that actually moved it, counts the sale, issues vouchers and
+// sends the receipt. Reloaded redirects, concurrent callbacks and refunded
+// transactions find it no longer pending and change nothing.
+func completeTransaction(transaction models.Transaction, paymentReference string) bool {
+	completed := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Transaction{}).
+			Where("id = ? AND status = ?", transaction.ID, models.TransactionStatusPending).
+			Updates(map[string]interface{}{"status": models.TransactionStatusCompleted, "payment_reference": paymentReference})
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		completed = true
+		return tx.Model(&models.Deal{}).Where("id = ?", transaction.DealID).
+			Update("sold_quantity", gorm.Expr("sold_quantity + ?", transaction.Quantity)).Error
+	})
+	if err != nil {
+		fmt.Printf("❌ Failed to complete transaction %d: %v\n", transaction.ID, err)
+		return false
+	}
+	if !completed {
+		fmt.Printf("⚠️ Transaction %d is no longer pending - nothing to complete\n", transaction.ID)
+		return false
+	}
+
+	transaction.Status = models.TransactionStatusCompleted
+	transaction.PaymentReference = paymentReference
+
+	var deal models.Deal
+	if err := config.DB.First(&deal, transaction.DealID).Error; err == nil {
+		if _, err := services.Notifications.Notify(services.DealPurchasedEvent(deal, transaction)); err != nil {
+			fmt.Printf("⚠️ Failed to notify merchant: %v\n", err)
+		}
+	}
+
+	services.Funnel.Record(models.FunnelPurchase, transaction.DealID)
+	issueTransactionVouchers(transaction)
+	sendPurchaseReceipt(transaction.ID)
+	return true
+}
+
+// failTransaction marks a pending transaction failed. Completed and refunded
+// transactions are left alone.
+func failTransaction(transactionID uint) {
+	if err := config.DB.Model(&models.Transaction{}).
+		Where("id = ? AND status = ?", transactionID, models.TransactionStatusPending).
+		Update("status", models.TransactionStatusFailed).Error; err != nil {
+		fmt.Printf("❌ Failed to update transaction %d: %v\n", transactionID, err)
+	}
+}
+
+// verifyFlutterwavePayment reports whether Flutterwave holds a successful
+// payment for the reference that covers the amount in Uganda shillings
+func verifyFlutterwavePayment(txRef string, amount float64) (bool, error) {
 	secretKey := os.Getenv("FLUTTERWAVE_SECRET_KEY")
 	if secretKey == "" {
 		return false, fmt.Errorf("Flutterwave secret key not configured")
@@ -379,7 +429,10 @@ func verifyFlutterwavePayment(txRef string) (bool, error) {
 	var result struct {
 		Status string `json:"status"`
 		Data   struct {
-			Status string `json:"status"`
+			Status   string  `json:"status"`
+			TxRef    string  `json:"tx_ref"`
+			Amount   float64 `json:"amount"`
+			Currency string  `json:"currency"`
 		} `json:"data"`
 	}
 
@@ -387,37 +440,190 @@ func verifyFlutterwavePayment(txRef string) (bool, error) {
 		return false, err
 	}
 
-	return result.Status == "success" && result.Data.Status == "successful", nil
+	return result.Status == "success" && result.Data.Status == "successful" &&
+		result.Data.TxRef == txRef && result.Data.Currency == "UGX" && result.Data.Amount >= amount, nil
 }
 
 func simulatePaymentCompletion(c *gin.Context, transaction models.Transaction) {
 	// Simulate successful payment for development
-	transaction.Status = "completed"
-	transaction.PaymentReference = fmt.Sprintf("SIM_%d_%d", transaction.ID, time.Now().Unix())
+	if completeTransaction(transaction, fmt.Sprintf("SIM_%d_%d", transaction.ID, time.Now().Unix())) {
+		fmt.Printf("✅ Payment simulation completed for transaction %d\n", transaction.ID)
+	}
+
+	config.DB.Select("status").First(&transaction, transaction.ID)
+	redirectURL := fmt.Sprintf("http://localhost:3000/payment/success?transaction_id=%d&status=%s", transaction.ID, transaction.Status)
+	c.Redirect(http.StatusFound, redirectURL)
+}
 
-	// Update deal sold quantity
+func issueTransactionVouchers(transaction models.Transaction) {
 	var deal models.Deal
-	if err := config.DB.First(&deal, transaction.DealID).Error; err == nil {
-		deal.SoldQuantity += transaction.Quantity
-		config.DB.Save(&deal)
-
-		// Create notification for merchant
-		notification := models.Notification{
-			UserID:  deal.MerchantID,
-			Title:   "New Sale! 💰",
-			Message: fmt.Sprintf("Your deal '%s' was purchased by a customer!", deal.Title),
-			Type:    models.NotificationDealPurchased,
-			Data:    fmt.Sprintf(`{"deal_id": %d, "transaction_id": %d, "quantity": %d}`, deal.ID, transaction.ID, transaction.Quantity),
+	if err := config.DB.Select("id, merchant_id").First(&deal, transaction.DealID).Error; err != nil {
+		fmt.Printf("❌ Cannot issue vouchers, deal not found: %v\n", err)
+		return
+	}
+
+	vouchers, err := models.IssueVouchers(transaction, deal.MerchantID)
+	if err != nil {
+		fmt.Printf("❌ Failed to issue vouchers for transaction %d: %v\n", transaction.ID, err)
+		return
+	}
+
+	fmt.Printf("🎟️ %d voucher(s) issued for transaction %d\n", len(vouchers), transaction.ID)
+}
+
+// sendPurchaseReceipt emails the customer their receipt and vouchers
+func sendPurchaseReceipt(transactionID uint) {
+	var transaction models.Transaction
+	if err := config.DB.Preload("Deal").Preload("User").Preload("Vouchers").First(&transaction, transactionID).Error; err != nil {
+		fmt.Printf("❌ Cannot send receipt, transaction not found: %v\n", err)
+		return
+	}
+
+	if _, err := services.Notifications.Notify(services.PurchaseReceiptEvent(transaction)); err != nil {
+		fmt.Printf("⚠️ Failed to send receipt for transaction %d: %v\n", transaction.ID, err)
+	}
+}
+
+// receiptTransaction loads a completed transaction the caller may see a
+// receipt for: their own, or any with the transactions.view permission
+func receiptTransaction(c *gin.Context) (models.Transaction, bool) {
+	userID := c.GetUint("user_id")
+
+	var transaction models.Transaction
+	if err := config.DB.Preload("Deal").Preload("User").Preload("Vouchers").First(&transaction, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return transaction, false
+	}
+
+	if transaction.UserID != userID {
+		if allowed, _ := models.UserHasPermissions(userID, models.PermissionTransactionsView); !allowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return transaction, false
 		}
-		config.DB.Create(&notification)
 	}
 
-	config.DB.Save(&transaction)
+	if transaction.Status != models.TransactionStatusCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receipts are only available for completed transactions"})
+		return transaction, false
+	}
 
-	fmt.Printf("✅ Payment simulation completed for transaction %d\n", transaction.ID)
-	
-	redirectURL := fmt.Sprintf("http://localhost:3000/payment/success?transaction_id=%d&status=completed", transaction.ID)
-	c.Redirect(http.StatusFound, redirectURL)
+	return transaction, true
+}
+
+// Download the receipt PDF for a completed transaction
+func GetTransactionReceipt(c *gin.Context) {
+	transaction, ok := receiptTransaction(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, services.ReceiptFilename(transaction)))
+	c.Data(http.StatusOK, "application/pdf", services.BuildReceiptPDF(transaction))
+}
+
+// Email the receipt again
+func ResendTransactionReceipt(c *gin.Context) {
+	transaction, ok := receiptTransaction(c)
+	if !ok {
+		return
+	}
+	fmt.Printf("🔄 ResendTransactionReceipt called for transaction %d\n", transaction.ID)
+
+	event := services.PurchaseReceiptEvent(transaction)
+	event.Channels = []string{services.ChannelEmail}
+	if _, err := services.Notifications.Notify(event); err != nil {
+		fmt.Printf("❌ Failed to resend receipt: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend receipt"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Receipt sent to " + transaction.User.Email,
+	})
+}
+
+// ADMIN - Record a refund once the money has been returned through the
+// payment provider. The vouchers are voided and the units go back on sale,
+// so a purchase with a redeemed voucher can't be refunded.
+func RefundTransaction(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transaction models.Transaction
+	if err := config.DB.Preload("Deal").Preload("Vouchers").First(&transaction, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if transaction.Status != models.TransactionStatusCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only completed transactions can be refunded"})
+		return
+	}
+	for _, voucher := range transaction.Vouchers {
+		if voucher.Status == models.VoucherStatusRedeemed {
+			c.JSON(http.StatusConflict, gin.H{"error": "A voucher from this purchase has already been redeemed"})
+			return
+		}
+	}
+
+	fmt.Printf("🔄 Refunding transaction %d (%s)\n", transaction.ID, req.Reason)
+
+	before := transaction
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional updates so a voucher redeemed meanwhile stops the refund
+		result := tx.Model(&models.Voucher{}).
+			Where("transaction_id = ? AND status = ?", transaction.ID, models.VoucherStatusActive).
+			Update("status", models.VoucherStatusVoid)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(transaction.Vouchers)) {
+			return models.ErrVoucherRedeemed
+		}
+
+		result = tx.Model(&models.Transaction{}).
+			Where("id = ? AND status = ?", transaction.ID, models.TransactionStatusCompleted).
+			Update("status", models.TransactionStatusRefunded)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("transaction %d is no longer completed", transaction.ID)
+		}
+
+		return tx.Model(&models.Deal{}).Where("id = ?", transaction.DealID).
+			Update("sold_quantity", gorm.Expr("CASE WHEN sold_quantity > ? THEN sold_quantity - ? ELSE 0 END", transaction.Quantity, transaction.Quantity)).Error
+	})
+	if err == models.ErrVoucherRedeemed {
+		c.JSON(http.StatusConflict, gin.H{"error": "A voucher from this purchase has already been redeemed"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to refund transaction %d: %v\n", transaction.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund transaction"})
+		return
+	}
+
+	transaction.Status = models.TransactionStatusRefunded
+	for i := range transaction.Vouchers {
+		transaction.Vouchers[i].Status = models.VoucherStatusVoid
+	}
+	middleware.RecordAudit(c, "transaction.refund", "transaction", transaction.ID, before, gin.H{"transaction": transaction, "reason": req.Reason})
+
+	if _, err := services.Notifications.Notify(services.TransactionRefundedEvent(transaction)); err != nil {
+		fmt.Printf("⚠️ Failed to notify user of refund: %v\n", err)
+	}
+
+	fmt.Printf("✅ Transaction %d refunded\n", transaction.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Transaction refunded",
+		"transaction": transaction,
+	})
 }
 
 // Keep the old simulation endpoint for backward compatibility
@@ -425,6 +631,11 @@ func SimulatePayment(c *gin.Context) {
 	transactionID := c.Param("id")
 	fmt.Printf("🔄 SimulatePayment called for transaction %s\n", transactionID)
 
+	if !paymentSimulationEnabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Payment simulation is disabled"})
+		return
+	}
+
 	var transaction models.Transaction
 	if err := config.DB.Preload("Deal").First(&transaction, transactionID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
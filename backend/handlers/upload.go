@@ -1,87 +1,77 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"snapadeal/models"
+	"snapadeal/services"
 )
 
+// maxImagesPerUpload caps UploadMultipleImages
+const maxImagesPerUpload = 10
+
 func UploadImage(c *gin.Context) {
+	// Cap the request body so oversized uploads are refused while reading
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxImageUploadSize+(1<<20))
+
 	// Parse multipart form
 	err := c.Request.ParseMultipartForm(10 << 20) // 10 MB max
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large"})
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large", "code": "file_too_large"})
 		return
 	}
 
-	file, header, err := c.Request.FormFile("image")
+	_, header, err := c.Request.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
 		return
 	}
-	defer file.Close()
-
-	// Validate file type
-	allowedTypes := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
-	}
 
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if !allowedTypes[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only JPG, PNG, GIF, and WebP are allowed"})
+	processed, err := processImageUpload(header)
+	if err != nil {
+		respondImageUploadError(c, header.Filename, err)
 		return
 	}
 
-	// Create uploads directory if it doesn't exist
-	uploadDir := "uploads/images"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+	userID := c.GetUint("user_id")
+	if err := services.CheckMediaQuota(userID, c.GetString("role"), []*services.ProcessedImage{processed}); err != nil {
+		respondQuotaError(c, err)
 		return
 	}
 
-	// Generate unique filename
-	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("%d_%s", timestamp, header.Filename)
-	filepath := filepath.Join(uploadDir, filename)
-
-	// Create the file
-	dst, err := os.Create(filepath)
+	asset, existing, err := services.StoreImage(userID, processed)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
-		return
-	}
-	defer dst.Close()
-
-	// Copy file content
-	if _, err := io.Copy(dst, file); err != nil {
+		fmt.Printf("❌ Failed to save image: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
 
-	// Return the file URL
-	fileURL := fmt.Sprintf("/uploads/images/%s", filename)
-	c.JSON(http.StatusOK, gin.H{
-		"message": "File uploaded successfully",
-		"url":     fileURL,
-		"filename": filename,
-	})
+	saved := mediaAssetResponse(*asset)
+	saved["message"] = "File uploaded successfully"
+	saved["width"] = asset.Width
+	saved["height"] = asset.Height
+	saved["duplicate"] = existing
+	c.JSON(http.StatusOK, saved)
 }
 
 func UploadMultipleImages(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImagesPerUpload*services.MaxImageUploadSize+(1<<20))
+
 	// Parse multipart form
 	err := c.Request.ParseMultipartForm(50 << 20) // 50 MB max for multiple files
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Files too large"})
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Files too large", "code": "file_too_large"})
 		return
 	}
 
@@ -93,70 +83,210 @@ func UploadMultipleImages(c *gin.Context) {
 		return
 	}
 
-	if len(files) > 10 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum 10 images allowed"})
+	if len(files) > maxImagesPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Maximum %d images allowed", maxImagesPerUpload)})
 		return
 	}
 
-	// Create uploads directory if it doesn't exist
-	uploadDir := "uploads/images"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
-		return
+	// Validate every image before saving any, so a bad file doesn't leave
+	// the others half uploaded
+	processedImages := make([]*services.ProcessedImage, 0, len(files))
+	for _, fileHeader := range files {
+		processed, err := processImageUpload(fileHeader)
+		if err != nil {
+			respondImageUploadError(c, fileHeader.Filename, err)
+			return
+		}
+		processedImages = append(processedImages, processed)
 	}
 
-	var uploadedFiles []map[string]string
-	allowedTypes := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
+	userID := c.GetUint("user_id")
+	if err := services.CheckMediaQuota(userID, c.GetString("role"), processedImages); err != nil {
+		respondQuotaError(c, err)
+		return
 	}
 
-	for _, fileHeader := range files {
-		// Validate file type
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-		if !allowedTypes[ext] {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid file type for %s. Only JPG, PNG, GIF, and WebP are allowed", fileHeader.Filename)})
+	var uploadedFiles []gin.H
+	var created []models.MediaAsset
+	for _, processed := range processedImages {
+		asset, existing, err := services.StoreImage(userID, processed)
+		if err != nil {
+			fmt.Printf("❌ Failed to save image: %v\n", err)
+			for _, asset := range created {
+				services.DeleteMediaAsset(asset)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 			return
 		}
+		if !existing {
+			created = append(created, *asset)
+		}
 
-		// Open uploaded file
-		file, err := fileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		saved := mediaAssetResponse(*asset)
+		saved["duplicate"] = existing
+		uploadedFiles = append(uploadedFiles, saved)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Files uploaded successfully",
+		"files":   uploadedFiles,
+	})
+}
+
+func processImageUpload(header *multipart.FileHeader) (*services.ProcessedImage, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return services.ProcessImageUpload(file)
+}
+
+// mediaAssetResponse describes an uploaded image with its variant URLs.
+// Variants are generated in the background; until one exists the uploads
+// handler serves the original in its place.
+func mediaAssetResponse(asset models.MediaAsset) gin.H {
+	dir, filename := path.Split(asset.Key)
+	variants := make(map[string]string, len(services.ImageVariants))
+	for _, variant := range services.ImageVariants {
+		variants[variant.Name] = "/uploads/" + dir + services.VariantFileName(filename, variant.Name)
+	}
+
+	return gin.H{
+		"id":       asset.ID,
+		"url":      asset.URL(),
+		"filename": filename,
+		"variants": variants,
+	}
+}
+
+// respondQuotaError reports an upload refused for exceeding the quota
+func respondQuotaError(c *gin.Context, err error) {
+	var quotaErr *services.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check upload quota"})
+		return
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": fmt.Sprintf("Upload quota exceeded: %.1f MB of %.0f MB used", float64(quotaErr.Used)/(1<<20), float64(quotaErr.Limit)/(1<<20)),
+		"code":  "quota_exceeded",
+		"used":  quotaErr.Used,
+		"limit": quotaErr.Limit,
+	})
+}
+
+// respondImageUploadError reports why an image was rejected, naming the file
+// the client sent so multi-file uploads can point at the culprit
+func respondImageUploadError(c *gin.Context, filename string, err error) {
+	var uploadErr *services.ImageUploadError
+	if !errors.As(err, &uploadErr) {
+		fmt.Printf("❌ Failed to process image %s: %v\n", filename, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process image"})
+		return
+	}
+
+	c.JSON(uploadErr.Status, gin.H{
+		"error": uploadErr.Message,
+		"code":  uploadErr.Code,
+		"file":  filepath.Base(filename),
+	})
+}
+
+// ServeUpload serves stored media under /uploads. Images accept ?w=<pixels>
+// and get the smallest variant at least that wide; a variant that hasn't
+// been generated yet falls back to the original and is queued.
+func ServeUpload(c *gin.Context) {
+	// Set CORS headers for images
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+	c.Header("Cache-Control", "public, max-age=86400") // Cache for 1 day
+
+	// Security: keys that would escape the storage root are rejected
+	key, err := services.CleanStorageKey(c.Param("filepath"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file path"})
+		return
+	}
+	if services.IsPrivateStorageKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	isImage := path.Dir(key) == services.ImageStorageDir
+
+	if _, err := services.Store.Stat(key); err != nil {
+		if err != services.ErrStorageNotFound {
+			fmt.Printf("❌ Failed to read %s from storage: %v\n", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
 			return
 		}
-		defer file.Close()
-
-		// Generate unique filename
-		timestamp := time.Now().UnixNano()
-		filename := fmt.Sprintf("%d_%s", timestamp, fileHeader.Filename)
-		filepath := filepath.Join(uploadDir, filename)
+		if isImage {
+			for _, original := range services.VariantOriginals(path.Base(key)) {
+				originalKey := services.ImageStorageDir + "/" + original
+				if _, err := services.Store.Stat(originalKey); err == nil {
+					serveImageFallback(c, originalKey)
+					return
+				}
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
 
-		// Create the file
-		dst, err := os.Create(filepath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
+	if width, err := strconv.Atoi(c.Query("w")); err == nil && width > 0 && isImage && services.VariantOriginals(path.Base(key)) == nil {
+		variant := services.VariantForWidth(width)
+		variantKey := services.ImageStorageDir + "/" + services.VariantFileName(path.Base(key), variant.Name)
+		if _, err := services.Store.Stat(variantKey); err != nil {
+			serveImageFallback(c, key)
 			return
 		}
-		defer dst.Close()
+		key = variantKey
+	}
 
-		// Copy file content
-		if _, err := io.Copy(dst, file); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	serveStoredFile(c, key)
+}
+
+// serveImageFallback serves the original while its variants are generated,
+// with a short cache lifetime so clients pick up the variant soon
+func serveImageFallback(c *gin.Context, key string) {
+	services.Variants.Enqueue(key)
+	c.Header("Cache-Control", "public, max-age=60")
+	serveStoredFile(c, key)
+}
+
+// serveStoredFile streams the object through the API, or with
+// STORAGE_SERVE_REDIRECT=true redirects to a signed URL so object storage
+// serves the bytes directly. Local files have no URL but this one, so they
+// are always streamed.
+func serveStoredFile(c *gin.Context, key string) {
+	_, local := services.Store.(*services.LocalStorage)
+	if os.Getenv("STORAGE_SERVE_REDIRECT") == "true" && !local {
+		signedURL, err := services.Store.SignedURL(key, time.Hour)
+		if err == nil {
+			c.Redirect(http.StatusFound, signedURL)
 			return
 		}
+		fmt.Printf("⚠️ Failed to sign URL for %s, streaming instead: %v\n", key, err)
+	}
 
-		uploadedFiles = append(uploadedFiles, map[string]string{
-			"url":      fmt.Sprintf("/uploads/images/%s", filename),
-			"filename": filename,
-		})
+	reader, object, err := services.Store.Get(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
 	}
+	defer reader.Close()
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Files uploaded successfully",
-		"files":   uploadedFiles,
-	})
+	// Seekable objects (local files) support range and conditional requests
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, path.Base(key), object.LastModified, seeker)
+		return
+	}
+
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	c.DataFromReader(http.StatusOK, object.Size, contentType, reader, nil)
 }
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"snapadeal/config"
+	"snapadeal/middleware"
+	"snapadeal/models"
+	"snapadeal/services"
+)
+
+// userListQuery applies the filters shared by the user list and export:
+// q (name, email or phone), role, status, is_verified and from/to on the
+// registration date
+func userListQuery(c *gin.Context) (*gorm.DB, error) {
+	query := config.DB.Model(&models.User{})
+
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		phone := "%" + strings.NewReplacer(" ", "", "-", "").Replace(search) + "%"
+		query = query.Where("(LOWER(first_name || ' ' || last_name) LIKE ? OR LOWER(email) LIKE ? OR phone LIKE ?)", pattern, pattern, phone)
+	}
+
+	// Filter by role
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if verified := c.Query("is_verified"); verified != "" {
+		isVerified, err := strconv.ParseBool(verified)
+		if err != nil {
+			return nil, fmt.Errorf("is_verified must be true or false")
+		}
+		query = query.Where("is_verified = ?", isVerified)
+	}
+
+	return dateRangeFilter(c, query, "created_at")
+}
+
+// ADMIN - List users, newest first, with search and filters
+func GetAllUsers(c *gin.Context) {
+	query, err := userListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Pagination
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var users []models.User
+	if err := query.Select("id, first_name, last_name, email, phone, role, status, status_reason, is_verified, last_login_at, created_at").
+		Offset(offset).Limit(limit).Order("created_at DESC").
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ADMIN - A user with their recent transactions, deals and sessions
+func GetUserDetail(c *gin.Context) {
+	var user models.User
+	if err := config.DB.Preload("Roles").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var summary struct {
+		Transactions          int64   `json:"transactions"`
+		CompletedTransactions int64   `json:"completed_transactions"`
+		TotalSpent            float64 `json:"total_spent"`
+		Deals                 int64   `json:"deals"`
+		ActiveSessions        int64   `json:"active_sessions"`
+	}
+	config.DB.Model(&models.Transaction{}).Where("user_id = ?", user.ID).Count(&summary.Transactions)
+
+	var spent struct {
+		Count int64
+		Total float64
+	}
+	config.DB.Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total").
+		Where("user_id = ? AND status = ?", user.ID, models.TransactionStatusCompleted).
+		Scan(&spent)
+	summary.CompletedTransactions = spent.Count
+	summary.TotalSpent = spent.Total
+
+	var transactions []models.Transaction
+	config.DB.Where("user_id = ?", user.ID).
+		Preload("Deal", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, title, merchant_id, discount_price")
+		}).
+		Order("created_at DESC").Limit(20).
+		Find(&transactions)
+
+	deals := []models.Deal{}
+	if user.Role == models.RoleMerchant {
+		config.DB.Model(&models.Deal{}).Where("merchant_id = ?", user.ID).Count(&summary.Deals)
+		config.DB.Select("id, title, status, is_active, sold_quantity, max_quantity, discount_price, start_date, end_date, created_at").
+			Where("merchant_id = ?", user.ID).
+			Order("created_at DESC").Limit(20).
+			Find(&deals)
+	}
+
+	var sessions []models.UserSession
+	config.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(20).Find(&sessions)
+	for _, session := range sessions {
+		if session.IsActive() {
+			summary.ActiveSessions++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":             user,
+		"summary":          summary,
+		"transactions":     transactions,
+		"deals":            deals,
+		"sessions":         sessions,
+		"allowed_statuses": models.UserStatusTransitions(user.Status),
+	})
+}
+
+// ADMIN - Change a user's status. Only the transitions in
+// models.UserStatusTransitions are allowed and a reason is required.
+// Suspending ends the user's sessions and tells them why.
+func UpdateUserStatus(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.ID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own status"})
+		return
+	}
+	if !models.CanChangeUserStatus(user.Status, req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":            fmt.Sprintf("Cannot change status from %s to %s", user.Status, req.Status),
+			"allowed_statuses": models.UserStatusTransitions(user.Status),
+		})
+		return
+	}
+
+	fmt.Printf("🔄 Admin %d changing user %d status: %s -> %s\n", adminID, user.ID, user.Status, req.Status)
+
+	before := user
+	now := time.Now()
+	user.Status = req.Status
+	user.StatusReason = req.Reason
+	user.StatusChangedAt = &now
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"status":            user.Status,
+		"status_reason":     user.StatusReason,
+		"status_changed_at": user.StatusChangedAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+		return
+	}
+	middleware.RecordAudit(c, "user.status_update", "user", user.ID, before, user)
+
+	var sessionsEnded int64
+	if user.Status != models.UserStatusActive {
+		var err error
+		if sessionsEnded, err = models.RevokeUserSessions(user.ID, "account "+user.Status); err != nil {
+			fmt.Printf("⚠️ Failed to end sessions of user %d: %v\n", user.ID, err)
+		}
+	}
+
+	if user.Status == models.UserStatusSuspended {
+		if _, err := services.Notifications.Notify(services.AccountSuspendedEvent(user, req.Reason)); err != nil {
+			fmt.Printf("⚠️ Failed to notify user %d of suspension: %v\n", user.ID, err)
+		}
+	} else if before.Status == models.UserStatusSuspended && user.Status == models.UserStatusActive {
+		if _, err := services.Notifications.Notify(services.AccountReinstatedEvent(user)); err != nil {
+			fmt.Printf("⚠️ Failed to notify user %d of reinstatement: %v\n", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "User status updated successfully",
+		"user":           user,
+		"sessions_ended": sessionsEnded,
+	})
+}
+
+// ADMIN - Move a user between the customer and merchant roles. Admin
+// accounts are managed through invitations and roles instead. The role is
+// in the user's tokens, so their sessions are ended. A merchant is only made
+// a customer once they have no live deals, and leaves their organisation.
+func ChangeUserRole(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req struct {
+		Role   string `json:"role" binding:"required,oneof=customer merchant"`
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.ID == adminID || user.Role == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admin accounts are managed through invitations and roles"})
+		return
+	}
+	if user.Role == req.Role {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User already has this role"})
+		return
+	}
+
+	// A merchant's live deals would stay on sale with nobody to run them
+	var membership *models.MerchantMember
+	if user.Role == models.RoleMerchant {
+		var liveDeals int64
+		config.DB.Model(&models.Deal{}).
+			Where("merchant_id = ? AND status = ? AND is_active = ? AND end_date > ?", user.ID, "approved", true, time.Now()).
+			Count(&liveDeals)
+		if liveDeals > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "This merchant still has live deals. Deactivate or end them before changing the role",
+				"live_deals": liveDeals,
+			})
+			return
+		}
+
+		var member models.MerchantMember
+		if err := config.DB.Where("user_id = ?", user.ID).First(&member).Error; err == nil {
+			if member.Role == models.MerchantRoleOwner {
+				var staff int64
+				config.DB.Model(&models.MerchantMember{}).
+					Where("organisation_id = ? AND user_id <> ?", member.OrganisationID, user.ID).
+					Count(&staff)
+				if staff > 0 {
+					c.JSON(http.StatusConflict, gin.H{
+						"error": "This merchant owns an organisation with other staff. Remove the staff before changing the role",
+						"staff": staff,
+					})
+					return
+				}
+			}
+			membership = &member
+		}
+	}
+
+	fmt.Printf("🔄 Admin %d changing user %d role: %s -> %s (%s)\n", adminID, user.ID, user.Role, req.Role, req.Reason)
+
+	before := user
+	user.Role = req.Role
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("role", user.Role).Error; err != nil {
+			return err
+		}
+		if membership == nil {
+			return nil
+		}
+
+		// Staff leave their organisation; a sole owner's organisation goes
+		// with them, along with its pending invitations
+		if err := tx.Delete(membership).Error; err != nil {
+			return err
+		}
+		if membership.Role != models.MerchantRoleOwner {
+			return nil
+		}
+		if err := tx.Model(&models.MerchantStaffInvitation{}).
+			Where("organisation_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", membership.OrganisationID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.MerchantOrganisation{}, membership.OrganisationID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change user role"})
+		return
+	}
+	if membership != nil {
+		fmt.Printf("🧹 Removed user %d from merchant organisation %d\n", user.ID, membership.OrganisationID)
+	}
+	middleware.RecordAudit(c, "user.role_change", "user", user.ID, before, gin.H{"user": user, "reason": req.Reason})
+
+	sessionsEnded, err := models.RevokeUserSessions(user.ID, "role changed")
+	if err != nil {
+		fmt.Printf("⚠️ Failed to end sessions of user %d: %v\n", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "User role changed successfully",
+		"user":           user,
+		"sessions_ended": sessionsEnded,
+	})
+}
+
+// ADMIN - Make a user choose a new password: the current one stops working,
+// their sessions end and they are emailed a reset link
+func ForceUserPasswordReset(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.ID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use forgot password to reset your own password"})
+		return
+	}
+
+	fmt.Printf("🔄 Admin %d forcing password reset for user %d (%s)\n", adminID, user.ID, req.Reason)
+
+	if err := user.ScramblePassword(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if err := user.GenerateResetToken(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"password":            user.Password,
+		"reset_token":         user.ResetToken,
+		"reset_token_expires": user.ResetTokenExpires,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	middleware.RecordAudit(c, "user.force_password_reset", "user", user.ID, nil, gin.H{"reason": req.Reason})
+
+	sessionsEnded, err := models.RevokeUserSessions(user.ID, "password reset by admin")
+	if err != nil {
+		fmt.Printf("⚠️ Failed to end sessions of user %d: %v\n", user.ID, err)
+	}
+
+	// The password is already unusable, so a failed email is reported but the
+	// reset stands; the user can still use forgot password
+	emailSent := true
+	if err := sendPasswordResetLink(user); err != nil {
+		fmt.Printf("❌ Failed to send reset email to user %d: %v\n", user.ID, err)
+		emailSent = false
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Password reset forced",
+		"email_sent":     emailSent,
+		"sessions_ended": sessionsEnded,
+	})
+}
+
+// ADMIN - Log a user out of every device
+func RevokeUserSessions(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	sessionsEnded, err := models.RevokeUserSessions(user.ID, "ended by admin")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end sessions"})
+		return
+	}
+	middleware.RecordAudit(c, "user.sessions_revoke", "user", user.ID, nil, gin.H{"sessions_ended": sessionsEnded})
+
+	fmt.Printf("✅ Ended %d sessions of user %d\n", sessionsEnded, user.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Sessions ended",
+		"sessions_ended": sessionsEnded,
+	})
+}
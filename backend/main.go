@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,6 +18,7 @@ import (
 	"snapadeal/handlers"
 	"snapadeal/middleware"
 	"snapadeal/models"
+	"snapadeal/services"
 )
 
 func main() {
@@ -28,13 +32,71 @@ func main() {
 
 	// Auto migrate models
 	log.Println("Running database migrations...")
-	config.DB.AutoMigrate(&models.User{}, &models.Deal{}, &models.Transaction{}, &models.Category{}, &models.Notification{})
+	config.DB.AutoMigrate(&models.User{}, &models.Deal{}, &models.Transaction{}, &models.Category{}, &models.Notification{},
+		&models.Permission{}, &models.Role{}, &models.AdminInvitation{},
+		&models.MerchantApplication{}, &models.MerchantDocument{}, &models.MerchantProfile{},
+		&models.MerchantOrganisation{}, &models.MerchantMember{}, &models.MerchantStaffInvitation{}, &models.Voucher{},
+		&models.NotificationPreference{}, &models.OutboundEmail{}, &models.EmailDeliveryAttempt{},
+		&models.Broadcast{}, &models.BroadcastRecipient{}, &models.MediaAsset{}, &models.AuditLog{}, &models.RejectionReason{},
+		&models.DealCheckRule{}, &models.BlockedTerm{}, &models.DealDailyStat{},
+		&models.ReportSubscription{}, &models.ReportRun{}, &models.UserSession{})
+
+	// Seed default roles and permissions
+	models.SeedRolesAndPermissions()
+
+	// One-time CLI command to create the first admin: go run main.go create-admin.
+	// It runs before any background worker starts, so it never touches the
+	// outbox, schedulers or media of a server running next to it.
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		runCreateAdmin(os.Args[2:])
+		return
+	}
 
-	// Create default admin user
-	models.CreateDefaultAdmin()
+	// Bootstrap the first admin from environment secrets, if configured
+	models.BootstrapAdmin()
 
 	// Seed database with categories
 	models.SeedDatabase()
+	models.EnsureCategorySlugs()
+	models.SeedRejectionReasons()
+	models.SeedDealCheckRules()
+	models.EnsureMerchantApplications()
+
+	// Send email from the database outbox in the background
+	services.Outbox = services.NewEmailOutbox(services.NewEmailService())
+	services.Outbox.Start()
+
+	// Deliver email and SMS notifications in the background
+	services.Notifications = services.NewNotificationService(services.NewEmailService(), services.NewSMSProvider())
+	services.Notifications.Start(4)
+
+	// Revoking a user's sessions also closes their notification streams
+	models.SessionsRevokedHook = services.Hub.Disconnect
+
+	// Uploaded media lives on local disk or S3-compatible object storage
+	store, err := services.NewStorage()
+	if err != nil {
+		log.Fatal("Failed to configure storage: ", err)
+	}
+	services.Store = store
+	services.MoveLocalKYCDocuments()
+
+	// Resize uploaded images into thumbnail, card and full variants
+	services.Variants.Start(2)
+
+	// Delete uploads nothing has referenced for the grace period
+	services.StartMediaCleanup(time.Hour)
+
+	// Write deal funnel counters in batches
+	services.Funnel.Start(10 * time.Second)
+
+	// Send admin broadcasts when they fall due
+	services.Broadcasts = services.NewBroadcastScheduler(services.NewEmailService())
+	services.Broadcasts.Start()
+
+	// Email scheduled reports to their subscribers
+	services.Reports = services.NewReportScheduler(services.NewEmailService())
+	services.Reports.Start()
 
 	// Initialize Gin router
 	r := gin.Default()
@@ -43,35 +105,7 @@ func main() {
 	r.RedirectTrailingSlash = false
 
 	// IMPROVED: Serve static files (uploaded images) with proper CORS headers and security
-	r.GET("/uploads/*filepath", func(c *gin.Context) {
-		// Set CORS headers for images
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Header("Cache-Control", "public, max-age=86400") // Cache for 1 day
-
-		// Get the file path
-		requestedPath := c.Param("filepath")
-		
-		// Security: Prevent directory traversal attacks
-		if strings.Contains(requestedPath, "..") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file path"})
-			return
-		}
-
-		// Clean the path
-		cleanPath := filepath.Clean(requestedPath)
-		fullPath := filepath.Join("./uploads", cleanPath)
-
-		// Check if file exists
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-
-		// Serve the file
-		c.File(fullPath)
-	})
+	r.GET("/uploads/*filepath", handlers.ServeUpload)
 
 	// Enhanced CORS Configuration for all apps
 	r.Use(cors.New(cors.Config{
@@ -143,6 +177,8 @@ func main() {
 			auth.POST("/login", handlers.Login)
 			auth.POST("/forgot-password", handlers.ForgotPassword)
 			auth.POST("/reset-password", handlers.ResetPassword)
+			auth.GET("/invitations/:token", handlers.GetAdminInvitation)
+			auth.POST("/accept-invitation", handlers.AcceptAdminInvitation)
 		}
 
 		// User routes (protected)
@@ -151,6 +187,16 @@ func main() {
 		{
 			users.GET("/profile", handlers.GetProfile)
 			users.PUT("/profile", handlers.UpdateProfile)
+			users.GET("/permissions", handlers.GetMyPermissions)
+			users.GET("/notification-preferences", handlers.GetNotificationPreferences)
+			users.PUT("/notification-preferences", handlers.UpdateNotificationPreferences)
+
+			// Scheduled report subscriptions
+			users.GET("/reports", handlers.GetMyReports)
+			users.GET("/reports/runs", handlers.GetMyReportRuns)
+			users.PUT("/reports/:report", handlers.UpdateMyReport)
+			users.POST("/reports/:report/send", handlers.SendMyReport)
+			users.POST("/merchant-invitations/accept", handlers.AcceptMerchantStaffInvitation)
 		}
 
 		// Upload routes (protected)
@@ -159,6 +205,8 @@ func main() {
 		{
 			upload.POST("/image", handlers.UploadImage)
 			upload.POST("/images", handlers.UploadMultipleImages)
+			upload.GET("/assets", handlers.GetMyMediaAssets)
+			upload.DELETE("/assets/:id", handlers.DeleteMyMediaAsset)
 		}
 
 		// PUBLIC Deal routes - ONLY APPROVED DEALS (for customers)
@@ -170,25 +218,62 @@ func main() {
 			deals.GET("/search", handlers.SearchDeals)  // Search deals
 		}
 
-		// MERCHANT Deal routes (protected) - Shows merchant's own deals
+		// PUBLIC Merchant storefronts
+		merchants := api.Group("/merchants")
+		{
+			merchants.GET("/:slug", handlers.GetMerchantStorefront) // Profile + live deals
+		}
+
+		// MERCHANT Deal routes (protected) - Shows merchant's own deals.
+		// They act as the caller's merchant organisation, so admins without
+		// one get 403 here and use the /admin routes instead.
 		merchantDeals := api.Group("/merchant")
-		merchantDeals.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("merchant"))
+		merchantDeals.Use(middleware.AuthMiddleware(), middleware.AuditMiddleware(), middleware.PermissionMiddleware(models.PermissionDealsManageOwn))
 		{
 			merchantDeals.GET("/deals", handlers.GetMerchantDeals)           // Merchant's own deals (all statuses)
 			merchantDeals.GET("/dashboard/stats", handlers.GetMerchantDashboardStats) // Dashboard stats
+			merchantDeals.GET("/analytics", handlers.GetMerchantAnalytics)            // Time-series analytics
+			merchantDeals.GET("/analytics/funnel", handlers.GetMerchantFunnel)        // Views to purchases
+
+			// CSV / XLSX exports (?format=xlsx)
+			merchantDeals.GET("/exports/transactions", handlers.ExportMerchantTransactions)
+			merchantDeals.GET("/exports/redemptions", handlers.ExportMerchantRedemptions)
 			merchantDeals.POST("/deals", handlers.CreateDeal)               // Create deal
 			merchantDeals.PUT("/deals/:id", handlers.UpdateDeal)            // Update deal
 			merchantDeals.DELETE("/deals/:id", handlers.DeleteDeal)         // Delete deal
+
+			// Organisation staff (owner, manager, cashier)
+			merchantDeals.GET("/organisation", handlers.GetMyOrganisation)
+			merchantDeals.GET("/organisation/invitations", handlers.GetMerchantStaffInvitations)
+			merchantDeals.POST("/organisation/invitations", handlers.InviteMerchantStaff)
+			merchantDeals.DELETE("/organisation/invitations/:id", handlers.RevokeMerchantStaffInvitation)
+			merchantDeals.PUT("/organisation/members/:id", handlers.UpdateMerchantMember)
+			merchantDeals.DELETE("/organisation/members/:id", handlers.RemoveMerchantMember)
+
+			// Voucher redemption at the counter
+			merchantDeals.GET("/vouchers/:code", handlers.GetMerchantVoucher)
+			merchantDeals.POST("/vouchers/redeem", handlers.RedeemVoucher)
+
+			// Business profile shown on the public storefront
+			merchantDeals.GET("/profile", handlers.GetMyMerchantProfile)
+			merchantDeals.PUT("/profile", handlers.UpdateMyMerchantProfile)
+
+			// Onboarding / KYC application
+			merchantDeals.GET("/application", handlers.GetMyMerchantApplication)
+			merchantDeals.POST("/application", handlers.SubmitMerchantApplication)
+			merchantDeals.PUT("/application", handlers.SubmitMerchantApplication)
+			merchantDeals.POST("/application/documents", handlers.UploadMerchantDocument)
+			merchantDeals.DELETE("/application/documents/:id", handlers.DeleteMerchantDocument)
 		}
 
 		// Legacy protected deal routes (for backward compatibility)
 		protectedDeals := api.Group("/deals")
-		protectedDeals.Use(middleware.AuthMiddleware())
+		protectedDeals.Use(middleware.AuthMiddleware(), middleware.AuditMiddleware())
 		{
-			protectedDeals.POST("", middleware.RoleMiddleware("merchant"), handlers.CreateDeal)
-			protectedDeals.POST("/", middleware.RoleMiddleware("merchant"), handlers.CreateDeal)
-			protectedDeals.PUT("/:id", middleware.RoleMiddleware("merchant"), handlers.UpdateDeal)
-			protectedDeals.DELETE("/:id", middleware.RoleMiddleware("merchant"), handlers.DeleteDeal)
+			protectedDeals.POST("", middleware.PermissionMiddleware(models.PermissionDealsManageOwn), handlers.CreateDeal)
+			protectedDeals.POST("/", middleware.PermissionMiddleware(models.PermissionDealsManageOwn), handlers.CreateDeal)
+			protectedDeals.PUT("/:id", middleware.PermissionMiddleware(models.PermissionDealsManageOwn), handlers.UpdateDeal)
+			protectedDeals.DELETE("/:id", middleware.PermissionMiddleware(models.PermissionDealsManageOwn), handlers.DeleteDeal)
 		}
 
 		// Transaction routes (protected) - ENHANCED WITH FLUTTERWAVE
@@ -199,6 +284,8 @@ func main() {
 			transactions.POST("/", handlers.CreateTransaction)    // Create transaction - with trailing slash
 			transactions.GET("", handlers.GetUserTransactions)    // Get user transactions
 			transactions.GET("/", handlers.GetUserTransactions)   // Get user transactions - with trailing slash
+			transactions.GET("/:id/receipt", handlers.GetTransactionReceipt)            // Receipt PDF
+			transactions.POST("/:id/receipt/resend", handlers.ResendTransactionReceipt) // Email receipt again
 		}
 
 		// Payment routes - FLUTTERWAVE INTEGRATION
@@ -209,6 +296,13 @@ func main() {
 		}
 
 		// Notification routes (protected)
+		// One-click unsubscribe from notification emails (public, signed token)
+		api.GET("/notifications/unsubscribe", handlers.ConfirmUnsubscribeNotifications)
+		api.POST("/notifications/unsubscribe", handlers.UnsubscribeNotifications)
+
+		// Server-Sent Events; the only route accepting ?access_token=
+		api.GET("/notifications/stream", middleware.StreamAuthMiddleware(), handlers.StreamNotifications)
+
 		notifications := api.Group("/notifications")
 		notifications.Use(middleware.AuthMiddleware())
 		{
@@ -220,26 +314,115 @@ func main() {
 
 		// Admin routes - ENHANCED WITH NEW ENDPOINTS
 		admin := api.Group("/admin")
-		admin.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"))
+		admin.Use(middleware.AuthMiddleware(), middleware.AuditMiddleware())
 		{
 			// Dashboard and statistics
-			admin.GET("/dashboard/stats", handlers.GetAdminDashboardStats)
-			admin.GET("/deals/statistics", handlers.GetDealStatistics)
-			admin.GET("/merchants/statistics", handlers.GetMerchantStatistics)
+			admin.GET("/dashboard/stats", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetAdminDashboardStats)
+			admin.GET("/analytics", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetAdminAnalytics)
+			admin.GET("/analytics/funnel", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetAdminFunnel)
+			admin.GET("/deals/statistics", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetDealStatistics)
+			admin.GET("/merchants/statistics", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetMerchantStatistics)
 
 			// Deal management - ALL DEALS (all statuses, all merchants)
-			admin.GET("/deals", handlers.GetAllDealsAdmin)              // All deals for admin
-			admin.GET("/deals/pending", handlers.GetPendingDeals)       // Pending deals only
-			admin.PUT("/deals/:id/approve", handlers.ApproveDeal)       // Approve deal
-			admin.PUT("/deals/:id/reject", handlers.RejectDeal)         // Reject deal
+			admin.GET("/deals", middleware.PermissionMiddleware(models.PermissionDealsView), handlers.GetAllDealsAdmin)        // All deals for admin
+			admin.GET("/deals/pending", middleware.PermissionMiddleware(models.PermissionDealsView), handlers.GetPendingDeals) // Pending deals only
+			admin.PUT("/deals/:id/approve", middleware.PermissionMiddleware(models.PermissionDealsApprove), handlers.ApproveDeal) // Approve deal
+			admin.PUT("/deals/:id/reject", middleware.PermissionMiddleware(models.PermissionDealsReject), handlers.RejectDeal)    // Reject deal
+
+			// Moderation queue - bulk actions and claims
+			admin.POST("/deals/bulk-approve", middleware.PermissionMiddleware(models.PermissionDealsApprove), handlers.BulkApproveDeals)
+			admin.POST("/deals/bulk-reject", middleware.PermissionMiddleware(models.PermissionDealsReject), handlers.BulkRejectDeals)
+			admin.POST("/deals/claim", middleware.PermissionMiddleware(models.PermissionDealsApprove), handlers.ClaimNextDeals)
+			admin.POST("/deals/:id/claim", middleware.PermissionMiddleware(models.PermissionDealsApprove), handlers.ClaimDeal)
+			admin.DELETE("/deals/:id/claim", middleware.PermissionMiddleware(models.PermissionDealsApprove), handlers.ReleaseDealClaim)
+			admin.PUT("/deals/:id/assign", middleware.PermissionMiddleware(models.PermissionDealsApprove), handlers.AssignDeal)
+			admin.GET("/rejection-reasons", middleware.PermissionMiddleware(models.PermissionDealsReject), handlers.GetRejectionReasons)
+			admin.POST("/rejection-reasons", middleware.PermissionMiddleware(models.PermissionDealsReject), handlers.CreateRejectionReason)
+			admin.PUT("/rejection-reasons/:id", middleware.PermissionMiddleware(models.PermissionDealsReject), handlers.UpdateRejectionReason)
+			admin.DELETE("/rejection-reasons/:id", middleware.PermissionMiddleware(models.PermissionDealsReject), handlers.DeleteRejectionReason)
+
+			// Automated deal checks
+			admin.GET("/deal-checks", middleware.PermissionMiddleware(models.PermissionDealChecksManage), handlers.GetDealCheckRules)
+			admin.POST("/deal-checks/run", middleware.PermissionMiddleware(models.PermissionDealChecksManage), handlers.RunDealChecks)
+			admin.PUT("/deal-checks/:key", middleware.PermissionMiddleware(models.PermissionDealChecksManage), handlers.UpdateDealCheckRule)
+			admin.POST("/blocked-terms", middleware.PermissionMiddleware(models.PermissionDealChecksManage), handlers.CreateBlockedTerm)
+			admin.DELETE("/blocked-terms/:id", middleware.PermissionMiddleware(models.PermissionDealChecksManage), handlers.DeleteBlockedTerm)
 
 			// User management
-			admin.GET("/users", handlers.GetAllUsers)
-			admin.PUT("/users/:id/status", handlers.UpdateUserStatus)
+			admin.GET("/users", middleware.PermissionMiddleware(models.PermissionUsersView), handlers.GetAllUsers)
+			admin.GET("/users/:id", middleware.PermissionMiddleware(models.PermissionUsersView), handlers.GetUserDetail)
+			admin.PUT("/users/:id/status", middleware.PermissionMiddleware(models.PermissionUsersSuspend), handlers.UpdateUserStatus)
+			admin.PUT("/users/:id/role", middleware.PermissionMiddleware(models.PermissionUsersManage), handlers.ChangeUserRole)
+			admin.POST("/users/:id/force-password-reset", middleware.PermissionMiddleware(models.PermissionUsersManage), handlers.ForceUserPasswordReset)
+			admin.DELETE("/users/:id/sessions", middleware.PermissionMiddleware(models.PermissionUsersManage), handlers.RevokeUserSessions)
+
+			// Roles and permissions
+			admin.GET("/permissions", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.GetPermissions)
+			admin.GET("/roles", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.GetRoles)
+			admin.POST("/roles", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.CreateRole)
+			admin.PUT("/roles/:id", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.UpdateRole)
+			admin.DELETE("/roles/:id", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.DeleteRole)
+			admin.GET("/users/:id/roles", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.GetUserRoles)
+			admin.POST("/users/:id/roles", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.AssignUserRole)
+			admin.DELETE("/users/:id/roles/:roleId", middleware.PermissionMiddleware(models.PermissionRolesManage), handlers.RemoveUserRole)
+
+			// Merchant onboarding review
+			admin.GET("/merchant-applications", middleware.PermissionMiddleware(models.PermissionMerchantsReview), handlers.GetMerchantApplications)
+			admin.GET("/merchant-applications/:id", middleware.PermissionMiddleware(models.PermissionMerchantsReview), handlers.GetMerchantApplication)
+			admin.GET("/merchant-applications/:id/documents/:docId", middleware.PermissionMiddleware(models.PermissionMerchantsReview), handlers.DownloadMerchantDocument)
+			admin.PUT("/merchant-applications/:id/approve", middleware.PermissionMiddleware(models.PermissionMerchantsReview), handlers.ApproveMerchantApplication)
+			admin.PUT("/merchant-applications/:id/reject", middleware.PermissionMiddleware(models.PermissionMerchantsReview), handlers.RejectMerchantApplication)
+			admin.PUT("/merchant-applications/:id/request-info", middleware.PermissionMiddleware(models.PermissionMerchantsReview), handlers.RequestMerchantApplicationInfo)
+
+			// Admin invitations
+			admin.GET("/invitations", middleware.PermissionMiddleware(models.PermissionAdminsInvite), handlers.GetAdminInvitations)
+			admin.POST("/invitations", middleware.PermissionMiddleware(models.PermissionAdminsInvite), handlers.CreateAdminInvitation)
+			admin.DELETE("/invitations/:id", middleware.PermissionMiddleware(models.PermissionAdminsInvite), handlers.RevokeAdminInvitation)
 
 			// Admin notifications
-			admin.POST("/notifications/broadcast", handlers.BroadcastNotification)
-			admin.GET("/notifications/stats", handlers.GetNotificationStats)
+			admin.POST("/notifications/broadcast", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.BroadcastNotification)
+			admin.GET("/notifications/stats", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetNotificationStats)
+			admin.GET("/broadcasts", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.GetBroadcasts)
+			admin.POST("/broadcasts/audience", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.PreviewBroadcastAudience)
+			admin.GET("/broadcasts/:id", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.GetBroadcast)
+			admin.POST("/broadcasts/:id/cancel", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.CancelBroadcast)
+
+			// Email outbox
+			admin.GET("/emails", middleware.PermissionMiddleware(models.PermissionEmailsManage), handlers.GetOutboundEmails)
+			admin.GET("/emails/:id", middleware.PermissionMiddleware(models.PermissionEmailsManage), handlers.GetOutboundEmail)
+			admin.POST("/emails/:id/retry", middleware.PermissionMiddleware(models.PermissionEmailsManage), handlers.RetryOutboundEmail)
+
+			// Uploaded media
+			admin.GET("/media", middleware.PermissionMiddleware(models.PermissionMediaManage), handlers.GetMediaAssets)
+			admin.POST("/media/cleanup", middleware.PermissionMiddleware(models.PermissionMediaManage), handlers.RunMediaCleanup)
+
+			// Category management
+			admin.GET("/categories", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.GetAdminCategories)
+			admin.POST("/categories", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.CreateCategory)
+			admin.PUT("/categories/order", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.ReorderCategories)
+			admin.PUT("/categories/:id", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.UpdateCategory)
+			admin.POST("/categories/:id/icon", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.UploadCategoryIcon)
+			admin.POST("/categories/:id/deactivate", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.DeactivateCategory)
+			admin.POST("/categories/:id/activate", middleware.PermissionMiddleware(models.PermissionCategoriesManage), handlers.ActivateCategory)
+
+			// Refunds
+			admin.POST("/transactions/:id/refund", middleware.PermissionMiddleware(models.PermissionRefundsIssue), handlers.RefundTransaction)
+
+			// Audit log of privileged actions
+			admin.GET("/audit-logs", middleware.PermissionMiddleware(models.PermissionAuditView), handlers.GetAuditLogs)
+			admin.GET("/audit-logs/export", middleware.PermissionMiddleware(models.PermissionAuditView), handlers.ExportAuditLogs)
+			admin.GET("/audit-logs/:id", middleware.PermissionMiddleware(models.PermissionAuditView), handlers.GetAuditLog)
+
+			// CSV / XLSX exports (?format=xlsx), with the same filters as the lists
+			admin.GET("/exports/transactions", middleware.PermissionMiddleware(models.PermissionTransactionsView), handlers.ExportTransactions)
+			admin.GET("/exports/users", middleware.PermissionMiddleware(models.PermissionUsersView), handlers.ExportUsers)
+			admin.GET("/exports/deals", middleware.PermissionMiddleware(models.PermissionDealsView), handlers.ExportDeals)
+			admin.GET("/report-runs", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.GetReportRuns)
+			admin.GET("/exports/merchant-statistics", middleware.PermissionMiddleware(models.PermissionStatsView), handlers.ExportMerchantStatistics)
+
+			// Email templates
+			admin.GET("/email-templates", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.GetEmailTemplates)
+			admin.GET("/email-templates/:name/preview", middleware.PermissionMiddleware(models.PermissionNotificationsSend), handlers.PreviewEmailTemplate)
 		}
 
 		// Category routes (PUBLIC)
@@ -257,7 +440,7 @@ func main() {
 
 	log.Printf("🚀 Snapadeal API Server starting on port %s", port)
 	log.Printf("📊 Database: SQLite (snapadeal.db)")
-	log.Printf("🔐 Default Admin: admin@snapadeal.com / admin123")
+	log.Printf("🔐 Admins: invitation only (POST /api/v1/admin/invitations)")
 	log.Printf("🌐 CORS: CONFIGURED FOR ALL APPS")
 	log.Printf("   - Customer App: http://localhost:3000")
 	log.Printf("   - Merchant App: http://localhost:3001")
@@ -267,7 +450,7 @@ func main() {
 	log.Printf("   - OTP verification for registration")
 	log.Printf("   - Password recovery via email")
 	log.Printf("   - Email/Phone login support")
-	log.Printf("🔔 Notifications system enabled")
+	log.Printf("🔔 Notifications system enabled (live stream: GET /api/v1/notifications/stream)")
 	log.Printf("📁 Static files served from /uploads with CORS and security")
 	log.Printf("📂 Categories endpoint: PUBLIC (no auth required)")
 	log.Printf("🛠️  Enhanced Authentication Endpoints:")
@@ -281,8 +464,9 @@ func main() {
 	log.Printf("   1. Merchant creates deal → Status: PENDING")
 	log.Printf("   2. Admin approves deal → Status: APPROVED (visible to customers)")
 	log.Printf("   3. Public API only shows APPROVED deals")
+	log.Printf("   (Merchants must pass KYC review via /api/v1/merchant/application first)")
 	log.Printf("🏪 Merchant endpoints: /api/v1/merchant/*")
-	log.Printf("👑 Admin endpoints: /api/v1/admin/*")
+	log.Printf("👑 Admin endpoints: /api/v1/admin/* (permission-based access)")
 	log.Printf("💳 Transaction endpoints:")
 	log.Printf("   - POST /api/v1/transactions (create transaction with Flutterwave)")
 	log.Printf("   - GET /api/v1/transactions (user transactions)")
@@ -318,3 +502,33 @@ func main() {
 
 	log.Fatal(http.ListenAndServe(":"+port, r))
 }
+
+// runCreateAdmin creates an admin account from the command line. The password
+// is read from stdin so it never ends up in shell history.
+func runCreateAdmin(args []string) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := fs.String("email", "", "admin email address")
+	phone := fs.String("phone", "", "admin phone number")
+	firstName := fs.String("first-name", "Super", "admin first name")
+	lastName := fs.String("last-name", "Admin", "admin last name")
+	fs.Parse(args)
+
+	if *email == "" || *phone == "" {
+		log.Fatal("Usage: go run main.go create-admin -email <email> -phone <phone> [-first-name <name>] [-last-name <name>]")
+	}
+
+	fmt.Print("Password: ")
+	reader := bufio.NewReader(os.Stdin)
+	password, err := reader.ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatal("Failed to read password:", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	admin, err := models.CreateAdmin(*firstName, *lastName, *email, *phone, password)
+	if err != nil {
+		log.Fatal("Failed to create admin: ", err)
+	}
+
+	log.Printf("✅ Admin created: %s (ID: %d)", admin.Email, admin.ID)
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+const auditChangeKey = "audit_change"
+
+type auditChange struct {
+	action     string
+	entityType string
+	entityID   string
+	before     interface{}
+	after      interface{}
+}
+
+// RecordAudit describes the change a handler made so AuditMiddleware can log
+// it. before and after are snapshotted as JSON when the request finishes, so
+// pass a copy for before rather than a pointer that is modified later.
+func RecordAudit(c *gin.Context, action, entityType string, entityID interface{}, before, after interface{}) {
+	c.Set(auditChangeKey, auditChange{
+		action:     action,
+		entityType: entityType,
+		entityID:   fmt.Sprint(entityID),
+		before:     before,
+		after:      after,
+	})
+}
+
+// AuditMiddleware writes an audit log entry for every mutating request that
+// gets past authentication, including denied and failed ones. Handlers add
+// the action name and before/after snapshots with RecordAudit; without it
+// the entry falls back to the route and :id parameter.
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		entry := models.AuditLog{
+			ActorID:    c.GetUint("user_id"),
+			ActorRole:  c.GetString("role"),
+			Action:     c.Request.Method + " " + c.FullPath(),
+			EntityID:   c.Param("id"),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			StatusCode: c.Writer.Status(),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+		}
+
+		if value, ok := c.Get(auditChangeKey); ok {
+			change := value.(auditChange)
+			entry.Action = change.action
+			entry.EntityType = change.entityType
+			entry.EntityID = change.entityID
+
+			var err error
+			if entry.Before, err = models.NewAuditSnapshot(change.before); err != nil {
+				fmt.Printf("⚠️ Failed to snapshot %s before state: %v\n", change.action, err)
+			}
+			if entry.After, err = models.NewAuditSnapshot(change.after); err != nil {
+				fmt.Printf("⚠️ Failed to snapshot %s after state: %v\n", change.action, err)
+			}
+		}
+
+		if err := config.DB.Create(&entry).Error; err != nil {
+			fmt.Printf("❌ Failed to write audit log for %s: %v\n", entry.Action, err)
+		}
+	}
+}
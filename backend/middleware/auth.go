@@ -7,11 +7,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"snapadeal/models"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
@@ -45,22 +47,57 @@ func AuthMiddleware() gin.HandlerFunc {
 		userID := uint(claims["user_id"].(float64))
 		role := claims["role"].(string)
 
+		// Tokens are tied to a session so they can be revoked early
+		tokenID, _ := claims["sid"].(string)
+		session, err := models.ActiveSession(tokenID, c.ClientIP())
+		if err != nil || session.UserID != userID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": models.ErrSessionRevoked.Error()})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", userID)
+		c.Set("session_id", session.ID)
 		c.Set("role", role)
 		c.Next()
 	}
 }
 
-func RoleMiddleware(requiredRole string) gin.HandlerFunc {
+// StreamAuthMiddleware is AuthMiddleware that also takes the token from the
+// access_token query parameter, because EventSource can't send headers. Use it
+// only on stream routes so tokens stay out of URLs everywhere else.
+func StreamAuthMiddleware() gin.HandlerFunc {
+	auth := AuthMiddleware()
 	return func(c *gin.Context) {
-		role := c.GetString("role")
-		
-		if role != requiredRole && role != "admin" {
+		if c.GetHeader("Authorization") == "" && c.Query("access_token") != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+c.Query("access_token"))
+		}
+		auth(c)
+	}
+}
+
+// PermissionMiddleware allows the request through only if the authenticated
+// user holds every listed permission, via their role or assigned roles.
+func PermissionMiddleware(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("user_id")
+
+		allowed, err := models.UserHasPermissions(userID, permissions...)
+		if err != nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 			c.Abort()
 			return
 		}
-		
+
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":    "Insufficient permissions",
+				"required": permissions,
+			})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
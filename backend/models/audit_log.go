@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogImmutable is returned when something tries to change or remove
+// an audit log entry
+var ErrAuditLogImmutable = errors.New("audit log entries are append-only")
+
+// AuditSnapshot is the JSON state of an entity before or after a change
+type AuditSnapshot json.RawMessage
+
+// NewAuditSnapshot marshals v, returning nil for a nil value
+func NewAuditSnapshot(v interface{}) (AuditSnapshot, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return AuditSnapshot(data), nil
+}
+
+func (s *AuditSnapshot) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+	case []byte:
+		*s = append(AuditSnapshot(nil), v...)
+	case string:
+		*s = AuditSnapshot(v)
+	default:
+		return errors.New("cannot scan audit snapshot")
+	}
+	return nil
+}
+
+func (s AuditSnapshot) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return string(s), nil
+}
+
+func (s AuditSnapshot) MarshalJSON() ([]byte, error) {
+	if len(s) == 0 {
+		return []byte("null"), nil
+	}
+	return s, nil
+}
+
+// AuditLog records one privileged mutation: who did it, to what, the state
+// before and after, and where the request came from. Rows are written by
+// middleware.AuditMiddleware and never updated or deleted.
+type AuditLog struct {
+	ID         uint          `json:"id" gorm:"primaryKey"`
+	ActorID    uint          `json:"actor_id" gorm:"index"`
+	Actor      *User         `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	ActorRole  string        `json:"actor_role"`
+	Action     string        `json:"action" gorm:"not null;index"` // e.g. "deal.approve"
+	EntityType string        `json:"entity_type" gorm:"index"`
+	EntityID   string        `json:"entity_id" gorm:"index"`
+	Before     AuditSnapshot `json:"before" gorm:"type:text"`
+	After      AuditSnapshot `json:"after" gorm:"type:text"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	StatusCode int           `json:"status_code" gorm:"index"`
+	IPAddress  string        `json:"ip_address"`
+	UserAgent  string        `json:"user_agent"`
+	CreatedAt  time.Time     `json:"created_at" gorm:"index"`
+}
+
+// Succeeded reports whether the audited request completed without an error
+func (a AuditLog) Succeeded() bool {
+	return a.StatusCode < 400
+}
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
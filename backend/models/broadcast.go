@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Broadcast status constants
+const (
+	BroadcastStatusScheduled = "scheduled"
+	BroadcastStatusSending   = "sending"
+	BroadcastStatusSent      = "sent"
+	BroadcastStatusCancelled = "cancelled"
+	BroadcastStatusFailed    = "failed"
+)
+
+// BroadcastSegment selects the audience of a broadcast. Every filter that is
+// set must match; an empty segment means all users.
+type BroadcastSegment struct {
+	Role                string `json:"role,omitempty"`                  // customer, merchant, admin
+	UserIDs             []uint `json:"user_ids,omitempty"`              // explicit recipients
+	PurchasedCategoryID *uint  `json:"purchased_category_id,omitempty"` // bought a deal in this category
+	HasPendingDeals     bool   `json:"has_pending_deals,omitempty"`     // merchants with deals awaiting approval
+	InactiveDays        int    `json:"inactive_days,omitempty"`         // no login for this many days
+}
+
+func (s *BroadcastSegment) Scan(value interface{}) error {
+	if value == nil {
+		*s = BroadcastSegment{}
+		return nil
+	}
+	return scanJSON(value, s)
+}
+
+func (s BroadcastSegment) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Users narrows a users query to the segment. Suspended accounts are never
+// included.
+func (s BroadcastSegment) Users(db *gorm.DB) *gorm.DB {
+	query := db.Model(&User{}).Where("status <> ?", "suspended")
+
+	if s.Role != "" && s.Role != "all" {
+		query = query.Where("role = ?", s.Role)
+	}
+	if len(s.UserIDs) > 0 {
+		query = query.Where("id IN ?", s.UserIDs)
+	}
+	if s.PurchasedCategoryID != nil {
+		query = query.Where("id IN (?)", db.Model(&Transaction{}).
+			Select("transactions.user_id").
+			Joins("JOIN deals ON deals.id = transactions.deal_id").
+			Where("deals.category_id = ? AND transactions.status = ?", *s.PurchasedCategoryID, TransactionStatusCompleted))
+	}
+	if s.HasPendingDeals {
+		query = query.Where("id IN (?)", db.Model(&Deal{}).
+			Select("merchant_id").
+			Where("status = ?", "pending"))
+	}
+	if s.InactiveDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -s.InactiveDays)
+		query = query.Where("(last_login_at IS NULL OR last_login_at < ?)", cutoff)
+	}
+
+	return query
+}
+
+// Broadcast is an admin announcement to a segment of users, sent now or at
+// ScheduledAt. Its in-app notifications carry its ID for per-broadcast stats.
+type Broadcast struct {
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	Title          string           `json:"title" gorm:"not null"`
+	Message        string           `json:"message" gorm:"type:text;not null"`
+	Segment        BroadcastSegment `json:"segment" gorm:"type:text"`
+	Channels       StringArray      `json:"channels" gorm:"type:text"` // in_app, email
+	Status         string           `json:"status" gorm:"default:'scheduled';index"`
+	ScheduledAt    time.Time        `json:"scheduled_at" gorm:"index"`
+	SentAt         *time.Time       `json:"sent_at"`
+	CreatedByID    uint             `json:"created_by_id"`
+	CreatedBy      User             `json:"created_by" gorm:"foreignKey:CreatedByID"`
+	RecipientCount int              `json:"recipient_count"` // in-app notifications created
+	EmailCount     int              `json:"email_count"`     // emails queued
+	Error          string           `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// BroadcastRecipient records that a broadcast was delivered to a user and on
+// which channels. A resumed broadcast skips users who already have one.
+type BroadcastRecipient struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	BroadcastID uint      `json:"broadcast_id" gorm:"not null;uniqueIndex:idx_broadcast_recipient"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_broadcast_recipient"`
+	InApp       bool      `json:"in_app"`
+	Email       bool      `json:"email"` // email queued
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// HasChannel reports whether the broadcast is sent on the channel
+func (b Broadcast) HasChannel(channel string) bool {
+	return containsString(b.Channels, channel)
+}
+
+// BroadcastStats are the delivery and read figures of one broadcast
+type BroadcastStats struct {
+	Recipients int64   `json:"recipients"`
+	Read       int64   `json:"read"`
+	ReadRate   float64 `json:"read_rate"` // percent of in-app recipients
+	Emails     int     `json:"emails"`
+}
+
+// Stats counts the broadcast's in-app notifications, including ones users
+// have since deleted
+func (b Broadcast) Stats(db *gorm.DB) BroadcastStats {
+	var stats BroadcastStats
+
+	var emails int64
+	db.Model(&BroadcastRecipient{}).Where("broadcast_id = ? AND email = ?", b.ID, true).Count(&emails)
+	stats.Emails = int(emails)
+
+	db.Unscoped().Model(&Notification{}).Where("broadcast_id = ?", b.ID).Count(&stats.Recipients)
+	db.Unscoped().Model(&Notification{}).Where("broadcast_id = ? AND is_read = ?", b.ID, true).Count(&stats.Read)
+
+	if stats.Recipients > 0 {
+		stats.ReadRate = float64(stats.Read) / float64(stats.Recipients) * 100
+	}
+	return stats
+}
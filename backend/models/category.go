@@ -1,18 +1,178 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"snapadeal/config"
 )
 
 type Category struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	Name        string    `json:"name" gorm:"unique;not null"`
+	Slug        string    `json:"slug" gorm:"uniqueIndex"`
 	Description string    `json:"description"`
-	IconURL     string    `json:"icon_url"`
+	IconURL     string    `json:"icon_url"` // emoji or an uploaded image URL
+	ParentID    *uint     `json:"parent_id" gorm:"index"`
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
+
+// CategoryNode is a category in the public tree with its live deal counts
+type CategoryNode struct {
+	Category
+	DealCount      int64           `json:"deal_count"`       // live deals in this category
+	TotalDealCount int64           `json:"total_deal_count"` // including subcategories
+	Children       []*CategoryNode `json:"children"`
+}
+
+// ErrCategoryCycle is returned when a category would become its own ancestor
+var ErrCategoryCycle = errors.New("a category cannot be moved under itself or one of its subcategories")
+
+func (c *Category) BeforeCreate(tx *gorm.DB) error {
+	if c.Slug == "" {
+		c.GenerateSlug()
+	}
+	return nil
+}
+
+// GenerateSlug derives a unique URL slug from the name
+func (c *Category) GenerateSlug() {
+	base := strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(c.Name), "-"), "-")
+	if base == "" {
+		base = "category"
+	}
+
+	slug := base
+	for i := 2; ; i++ {
+		var count int64
+		config.DB.Model(&Category{}).Unscoped().
+			Where("slug = ? AND id <> ?", slug, c.ID).
+			Count(&count)
+		if count == 0 {
+			break
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	c.Slug = slug
+}
+
+// CheckCategoryParent verifies parentID exists and isn't categoryID or one of
+// its descendants. categoryID is 0 for a new category.
+func CheckCategoryParent(categoryID, parentID uint) error {
+	for id := parentID; id != 0; {
+		if id == categoryID {
+			return ErrCategoryCycle
+		}
+		var parent Category
+		if err := config.DB.Select("id, parent_id").First(&parent, id).Error; err != nil {
+			return err
+		}
+		if parent.ParentID == nil {
+			return nil
+		}
+		id = *parent.ParentID
+	}
+	return nil
+}
+
+// CategoryDescendantIDs returns the category and every category below it
+func CategoryDescendantIDs(categoryID uint) []uint {
+	var categories []Category
+	config.DB.Select("id, parent_id").Find(&categories)
+
+	children := make(map[uint][]uint)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []uint{categoryID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// LiveDealCounts counts the approved, active deals in each category, the
+// same deals the public listing shows
+func LiveDealCounts() map[uint]int64 {
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	config.DB.Model(&Deal{}).
+		Select("category_id, COUNT(*) AS count").
+		Where("status = ? AND is_active = ?", "approved", true).
+		Group("category_id").
+		Scan(&rows)
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts
+}
+
+// BuildCategoryTree nests categories under their parents, ordered by
+// sort_order then name, and fills in deal counts. Categories whose parent
+// isn't in the list (e.g. an inactive parent) are left out.
+func BuildCategoryTree(categories []Category, counts map[uint]int64) []*CategoryNode {
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{
+			Category:  category,
+			DealCount: counts[category.ID],
+			Children:  []*CategoryNode{},
+		}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	var finish func(nodes []*CategoryNode) int64
+	finish = func(nodes []*CategoryNode) int64 {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			if nodes[i].SortOrder != nodes[j].SortOrder {
+				return nodes[i].SortOrder < nodes[j].SortOrder
+			}
+			return nodes[i].Name < nodes[j].Name
+		})
+		var total int64
+		for _, node := range nodes {
+			node.TotalDealCount = node.DealCount + finish(node.Children)
+			total += node.TotalDealCount
+		}
+		return total
+	}
+	finish(roots)
+	return roots
+}
+
+// EnsureCategorySlugs gives categories created before slugs existed one
+func EnsureCategorySlugs() {
+	var categories []Category
+	config.DB.Where("slug IS NULL OR slug = ''").Find(&categories)
+	for _, category := range categories {
+		category.GenerateSlug()
+		if err := config.DB.Model(&category).UpdateColumn("slug", category.Slug).Error; err != nil {
+			log.Printf("Failed to set slug for category %d: %v", category.ID, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"math"
 	"time"
 
 	"gorm.io/gorm"
@@ -59,15 +60,29 @@ type Deal struct {
 	Location        string      `json:"location"`
 	FinePrints      string      `json:"fine_prints" gorm:"type:text"` // Changed from Terms to FinePrints
 	IsActive        bool        `json:"is_active" gorm:"default:true"`
+	ClaimedByID     *uint       `json:"claimed_by_id" gorm:"index"` // admin reviewing the deal, see DealClaimDuration
+	ClaimedBy       *User       `json:"claimed_by,omitempty" gorm:"foreignKey:ClaimedByID"`
+	ClaimedAt       *time.Time  `json:"claimed_at"`
+	ReviewedByID    *uint       `json:"reviewed_by_id"`
+	ReviewedAt      *time.Time  `json:"reviewed_at"`
+	RejectionReason string      `json:"rejection_reason" gorm:"type:text"`
+	CheckFindings   DealFindings `json:"check_findings" gorm:"type:text"` // from the automated content checks
+	CheckBlocked    bool        `json:"check_blocked" gorm:"index;default:false"`
+	CheckedAt       *time.Time  `json:"checked_at"`
 	CreatedAt       time.Time   `json:"created_at"`
 	UpdatedAt       time.Time   `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
-func (d *Deal) BeforeCreate(tx *gorm.DB) error {
-	if d.DiscountPercent == 0 && d.OriginalPrice > 0 {
-		d.DiscountPercent = int(((d.OriginalPrice - d.DiscountPrice) / d.OriginalPrice) * 100)
+// CalculateDiscountPercent derives DiscountPercent from the two prices
+func (d *Deal) CalculateDiscountPercent() {
+	if d.OriginalPrice > 0 {
+		d.DiscountPercent = int(math.Round((d.OriginalPrice - d.DiscountPrice) / d.OriginalPrice * 100))
 	}
+}
+
+func (d *Deal) BeforeCreate(tx *gorm.DB) error {
+	d.CalculateDiscountPercent()
 	
 	// Validate maximum 10 images
 	if len(d.Images) > 10 {
@@ -78,6 +93,10 @@ func (d *Deal) BeforeCreate(tx *gorm.DB) error {
 }
 
 func (d *Deal) BeforeUpdate(tx *gorm.DB) error {
+	// Keep the percentage in step with edited prices. Column updates on an
+	// empty model leave it alone.
+	d.CalculateDiscountPercent()
+
 	// Validate maximum 10 images on update
 	if len(d.Images) > 10 {
 		return errors.New("maximum 10 images allowed per deal")
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"snapadeal/config"
+)
+
+// Finding severities. Warnings are shown to reviewers, blocks keep a deal
+// out of the review queue and stop it being approved until it's fixed.
+const (
+	CheckSeverityOff     = "off"
+	CheckSeverityWarning = "warning"
+	CheckSeverityBlock   = "block"
+)
+
+// Rule keys understood by the deal checker
+const (
+	CheckDiscountMismatch = "discount_mismatch" // % claimed in the text vs the prices
+	CheckDiscountTooSteep = "discount_too_steep"
+	CheckEndDateTooFar    = "end_date_too_far"
+	CheckEndDatePassed    = "end_date_passed"
+	CheckMissingImages    = "missing_images"
+	CheckShortDescription = "short_description"
+	CheckBlockedTerms     = "blocked_terms"
+)
+
+// DealCheckRule configures one automated content check. What Threshold
+// means depends on the rule.
+type DealCheckRule struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Key         string    `json:"key" gorm:"unique;not null"`
+	Description string    `json:"description"`
+	Severity    string    `json:"severity" gorm:"not null;default:'warning'"` // off, warning, block
+	Threshold   float64   `json:"threshold"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BlockedTerm is a word or phrase that may not appear in deal text
+type BlockedTerm struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Term        string    `json:"term" gorm:"unique;not null"` // stored lower case
+	Severity    string    `json:"severity" gorm:"not null;default:'block'"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DealFinding is one problem the checker found with a deal
+type DealFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+// DealFindings is stored as JSON on the deal, like StringArray
+type DealFindings []DealFinding
+
+func (f *DealFindings) Scan(value interface{}) error {
+	if value == nil {
+		*f = DealFindings{}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return errors.New("cannot scan into DealFindings")
+	}
+}
+
+func (f DealFindings) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(f)
+}
+
+// Blocked reports whether any finding is a hard block
+func (f DealFindings) Blocked() bool {
+	for _, finding := range f {
+		if finding.Severity == CheckSeverityBlock {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidCheckSeverity reports whether severity is off, warning or block
+func ValidCheckSeverity(severity string) bool {
+	return severity == CheckSeverityOff || severity == CheckSeverityWarning || severity == CheckSeverityBlock
+}
+
+var defaultDealCheckRules = []DealCheckRule{
+	{Key: CheckDiscountMismatch, Severity: CheckSeverityWarning, Threshold: 5,
+		Description: "A percentage in the title or description differs from the real discount by more than threshold points"},
+	{Key: CheckDiscountTooSteep, Severity: CheckSeverityWarning, Threshold: 90,
+		Description: "The discount is threshold percent or more"},
+	{Key: CheckEndDateTooFar, Severity: CheckSeverityBlock, Threshold: 365,
+		Description: "The deal ends more than threshold days from now"},
+	{Key: CheckEndDatePassed, Severity: CheckSeverityBlock,
+		Description: "The end date is already in the past"},
+	{Key: CheckMissingImages, Severity: CheckSeverityBlock,
+		Description: "The deal has no images"},
+	{Key: CheckShortDescription, Severity: CheckSeverityWarning, Threshold: 40,
+		Description: "The description is shorter than threshold characters"},
+	{Key: CheckBlockedTerms, Severity: CheckSeverityBlock,
+		Description: "The deal text contains a blocked term. Each term carries its own severity; off disables the check"},
+}
+
+// SeedDealCheckRules adds any default rule that doesn't exist yet, so new
+// rules appear on upgrade without touching ones admins have tuned
+func SeedDealCheckRules() {
+	for _, rule := range defaultDealCheckRules {
+		var count int64
+		config.DB.Model(&DealCheckRule{}).Where("key = ?", rule.Key).Count(&count)
+		if count > 0 {
+			continue
+		}
+		if err := config.DB.Create(&rule).Error; err != nil {
+			log.Printf("Failed to seed deal check rule %s: %v", rule.Key, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// DealDailyStat counts how far customers got with a deal on one day, from
+// seeing it in a listing to paying for it. Rows are written in batches by
+// services.Funnel, so the latest counts can lag by a few seconds.
+type DealDailyStat struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	DealID         uint      `json:"deal_id" gorm:"not null;uniqueIndex:idx_deal_daily_stats_deal_day"`
+	Day            string    `json:"day" gorm:"size:10;not null;uniqueIndex:idx_deal_daily_stats_deal_day;index"` // YYYY-MM-DD, server time
+	Impressions    int64     `json:"impressions" gorm:"not null;default:0"`                                       // shown in a listing or search
+	Views          int64     `json:"views" gorm:"not null;default:0"`                                             // detail page opened
+	CheckoutStarts int64     `json:"checkout_starts" gorm:"not null;default:0"`                                   // transaction created
+	Purchases      int64     `json:"purchases" gorm:"not null;default:0"`                                         // transaction completed
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Funnel stages, named after their DealDailyStat columns
+const (
+	FunnelImpression    = "impressions"
+	FunnelView          = "views"
+	FunnelCheckoutStart = "checkout_starts"
+	FunnelPurchase      = "purchases"
+)
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Outbound email status constants
+const (
+	EmailStatusQueued  = "queued"
+	EmailStatusSending = "sending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+// OutboundEmailAttachment is a file stored with a queued email
+type OutboundEmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// OutboundEmailAttachments is stored as a JSON array; Data is base64 encoded
+type OutboundEmailAttachments []OutboundEmailAttachment
+
+func (a *OutboundEmailAttachments) Scan(value interface{}) error {
+	if value == nil {
+		*a = OutboundEmailAttachments{}
+		return nil
+	}
+	return scanJSON(value, a)
+}
+
+func (a OutboundEmailAttachments) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(a)
+}
+
+// OutboundEmail is one message in the email outbox. Handlers enqueue rows and
+// the outbox worker delivers them, retrying with backoff until MaxAttempts.
+// Bodies and attachments are cleared once sent, and sent rows are purged
+// after the retention period.
+type OutboundEmail struct {
+	ID            uint                     `json:"id" gorm:"primaryKey"`
+	To            string                   `json:"to" gorm:"column:recipient;not null;index"`
+	Subject       string                   `json:"subject" gorm:"not null"`
+	Template      string                   `json:"template" gorm:"index"` // template name, empty for raw HTML
+	HTML          string                   `json:"html,omitempty" gorm:"type:text"`
+	Text          string                   `json:"text,omitempty" gorm:"type:text"`
+	Headers       StringArray              `json:"headers,omitempty" gorm:"type:text"`
+	Attachments   OutboundEmailAttachments `json:"-" gorm:"type:text"`
+	Status        string                   `json:"status" gorm:"default:'queued';index"`
+	Attempts      int                      `json:"attempts" gorm:"default:0"`
+	MaxAttempts   int                      `json:"max_attempts" gorm:"default:5"`
+	LastError     string                   `json:"last_error" gorm:"type:text"`
+	NextAttemptAt time.Time                `json:"next_attempt_at" gorm:"index"`
+	SentAt        *time.Time               `json:"sent_at"`
+	DeliveryLog   []EmailDeliveryAttempt   `json:"delivery_log,omitempty" gorm:"foreignKey:EmailID"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+}
+
+// EmailDeliveryAttempt records one attempt to deliver an outbound email
+type EmailDeliveryAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EmailID   uint      `json:"email_id" gorm:"not null;index"`
+	Attempt   int       `json:"attempt"`
+	Status    string    `json:"status"` // sent, failed
+	Error     string    `json:"error" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// sensitiveEmailTemplates carry one-time codes or links that take over an
+// account, so their bodies are never shown to admins
+var sensitiveEmailTemplates = []string{"otp", "password_reset", "admin_invitation", "merchant_staff_invitation"}
+
+// IsSensitiveEmailTemplate reports whether emails from the template hold secrets
+func IsSensitiveEmailTemplate(template string) bool {
+	return containsString(sensitiveEmailTemplates, template)
+}
+
+// Redact blanks the bodies of emails from sensitive templates and reports
+// whether it did
+func (e *OutboundEmail) Redact() bool {
+	if !IsSensitiveEmailTemplate(e.Template) {
+		return false
+	}
+	e.HTML = ""
+	e.Text = ""
+	return true
+}
+
+// AttachmentNames lists the attachment file names without their contents
+func (e OutboundEmail) AttachmentNames() []string {
+	names := make([]string, 0, len(e.Attachments))
+	for _, attachment := range e.Attachments {
+		names = append(names, attachment.Filename)
+	}
+	return names
+}
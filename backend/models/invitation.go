@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+type AdminInvitation struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Email       string         `json:"email" gorm:"not null;index"`
+	Token       string         `json:"-" gorm:"size:64;uniqueIndex"`
+	InvitedByID uint           `json:"invited_by_id"`
+	InvitedBy   User           `json:"invited_by" gorm:"foreignKey:InvitedByID"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	AcceptedAt  *time.Time     `json:"accepted_at"`
+	RevokedAt   *time.Time     `json:"revoked_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Invitations are valid for 72 hours
+const AdminInvitationTTL = 72 * time.Hour
+
+// Generate invitation token
+func (i *AdminInvitation) GenerateToken() error {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+
+	i.Token = fmt.Sprintf("%x", bytes)
+	i.ExpiresAt = time.Now().Add(AdminInvitationTTL)
+
+	return nil
+}
+
+// IsPending reports whether the invitation can still be accepted
+func (i *AdminInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+// Status of the invitation as shown to admins
+func (i *AdminInvitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return "accepted"
+	case i.RevokedAt != nil:
+		return "revoked"
+	case time.Now().After(i.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+// Admin accounts need a stronger password than regular users
+const MinAdminPasswordLength = 8
+
+// CreateAdmin creates an active, verified admin account. It is used by the
+// create-admin CLI command and the ADMIN_BOOTSTRAP_* environment bootstrap.
+func CreateAdmin(firstName, lastName, email, phone, password string) (*User, error) {
+	if email == "" || phone == "" {
+		return nil, errors.New("email and phone are required")
+	}
+	if len(password) < MinAdminPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", MinAdminPasswordLength)
+	}
+
+	var existing User
+	if err := config.DB.Where("email = ? OR phone = ?", email, phone).First(&existing).Error; err == nil {
+		return nil, errors.New("a user with this email or phone already exists")
+	}
+
+	now := time.Now()
+	admin := User{
+		FirstName:       firstName,
+		LastName:        lastName,
+		Email:           email,
+		Phone:           phone,
+		Password:        password,
+		Role:            RoleAdmin,
+		Status:          "active",
+		IsVerified:      true,
+		EmailVerifiedAt: &now,
+	}
+	if err := admin.HashPassword(); err != nil {
+		return nil, err
+	}
+	if err := config.DB.Create(&admin).Error; err != nil {
+		return nil, err
+	}
+
+	return &admin, nil
+}
+
+// BootstrapAdmin creates the first admin from ADMIN_BOOTSTRAP_EMAIL,
+// ADMIN_BOOTSTRAP_PHONE and ADMIN_BOOTSTRAP_PASSWORD, but only while no admin
+// exists yet. Once an admin is present the variables are ignored and should be
+// removed from the environment.
+func BootstrapAdmin() {
+	var count int64
+	config.DB.Model(&User{}).Where("role = ?", RoleAdmin).Count(&count)
+
+	if count > 0 {
+		if os.Getenv("ADMIN_BOOTSTRAP_PASSWORD") != "" {
+			log.Println("⚠️ ADMIN_BOOTSTRAP_PASSWORD is set but an admin already exists - remove it from the environment")
+		}
+		disableLegacyDefaultAdmin()
+		return
+	}
+
+	email := os.Getenv("ADMIN_BOOTSTRAP_EMAIL")
+	phone := os.Getenv("ADMIN_BOOTSTRAP_PHONE")
+	password := os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+	if email == "" || phone == "" || password == "" {
+		log.Println("⚠️ No admin account exists. Create one with `go run main.go create-admin` or set ADMIN_BOOTSTRAP_EMAIL/ADMIN_BOOTSTRAP_PHONE/ADMIN_BOOTSTRAP_PASSWORD")
+		return
+	}
+
+	admin, err := CreateAdmin("Super", "Admin", email, phone, password)
+	if err != nil {
+		log.Printf("❌ Failed to bootstrap admin: %v", err)
+		return
+	}
+
+	log.Printf("✅ Bootstrapped initial admin: %s", admin.Email)
+}
+
+// disableLegacyDefaultAdmin locks the old hard-coded admin@snapadeal.com /
+// admin123 account from before invitations existed: its password is replaced
+// with a random one and its sessions end. The owner of the mailbox can still
+// take it back with forgot password.
+func disableLegacyDefaultAdmin() {
+	var admin User
+	if err := config.DB.Where("email = ? AND role = ?", "admin@snapadeal.com", RoleAdmin).First(&admin).Error; err != nil {
+		return
+	}
+	if !admin.CheckPassword("admin123") {
+		return
+	}
+
+	if err := admin.ScramblePassword(); err != nil {
+		log.Printf("❌ Failed to disable the default admin password: %v", err)
+		return
+	}
+	if err := config.DB.Model(&admin).Update("password", admin.Password).Error; err != nil {
+		log.Printf("❌ Failed to disable the default admin password: %v", err)
+		return
+	}
+	if _, err := RevokeUserSessions(admin.ID, "default password disabled"); err != nil {
+		log.Printf("⚠️ Failed to end default admin sessions: %v", err)
+	}
+
+	log.Println("🔒 admin@snapadeal.com still used the old default password, which has been disabled - use forgot password to set a new one")
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"snapadeal/config"
+)
+
+// MediaAsset tracks one uploaded file: who uploaded it, its content hash for
+// deduplication, and how many deals, profiles or categories point at it.
+// Assets nobody references are deleted by the media cleanup job once
+// UnreferencedSince is older than the grace period.
+type MediaAsset struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	OwnerID           *uint      `json:"owner_id" gorm:"index"`     // nil for files uploaded before tracking
+	Key               string     `json:"key" gorm:"not null;index"` // storage key; shared by duplicates
+	ContentType       string     `json:"content_type"`
+	Size              int64      `json:"size"`
+	Hash              string     `json:"hash" gorm:"index"` // SHA-256 of the stored bytes
+	Width             int        `json:"width"`
+	Height            int        `json:"height"`
+	ReferenceCount    int        `json:"reference_count"`
+	UnreferencedSince *time.Time `json:"unreferenced_since" gorm:"index"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// URL is the path the asset is served under
+func (a MediaAsset) URL() string {
+	return "/uploads/" + a.Key
+}
+
+// mediaQuotas caps the bytes each role may keep uploaded. Roles without an
+// entry (admins) are unlimited.
+var mediaQuotas = map[string]int64{
+	"customer": 10 << 20,
+	"merchant": 500 << 20,
+}
+
+// MediaQuota returns the upload quota in bytes for a role, 0 meaning unlimited
+func MediaQuota(role string) int64 {
+	return mediaQuotas[role]
+}
+
+// MediaUsage returns the bytes a user's uploads take up
+func MediaUsage(userID uint) int64 {
+	var usage int64
+	config.DB.Model(&MediaAsset{}).
+		Where("owner_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&usage)
+	return usage
+}
+
+// MediaKeyFromURL extracts the storage key from an uploads URL, absolute or
+// relative, e.g. "http://host/uploads/images/a.jpg?w=320" -> "images/a.jpg"
+func MediaKeyFromURL(raw string) (string, bool) {
+	index := strings.Index(raw, "/uploads/")
+	if index < 0 {
+		return "", false
+	}
+
+	key := raw[index+len("/uploads/"):]
+	if cut := strings.IndexAny(key, "?#"); cut >= 0 {
+		key = key[:cut]
+	}
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		key = unescaped
+	}
+	return key, key != ""
+}
+
+// MediaReferenceURLs returns every uploads URL stored on deals, merchant
+// profiles and categories, including soft-deleted rows that may be restored
+func MediaReferenceURLs() ([]string, error) {
+	var urls []string
+
+	var deals []Deal
+	if err := config.DB.Unscoped().Select("image_url", "images").Find(&deals).Error; err != nil {
+		return nil, err
+	}
+	for _, deal := range deals {
+		urls = append(urls, deal.ImageURL)
+		urls = append(urls, deal.Images...)
+	}
+
+	var profiles []MerchantProfile
+	if err := config.DB.Unscoped().Select("logo_url", "cover_url").Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	for _, profile := range profiles {
+		urls = append(urls, profile.LogoURL, profile.CoverURL)
+	}
+
+	var categories []Category
+	if err := config.DB.Unscoped().Select("icon_url").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		urls = append(urls, category.IconURL)
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+type MerchantApplication struct {
+	ID                 uint               `json:"id" gorm:"primaryKey"`
+	UserID             uint               `json:"user_id" gorm:"uniqueIndex;not null"`
+	User               User               `json:"user" gorm:"foreignKey:UserID"`
+	BusinessName       string             `json:"business_name" gorm:"not null"`
+	RegistrationNumber string             `json:"registration_number" gorm:"not null"`
+	TIN                string             `json:"tin" gorm:"column:tin;not null"`
+	PhysicalAddress    string             `json:"physical_address" gorm:"type:text;not null"`
+	ContactPerson      string             `json:"contact_person" gorm:"not null"`
+	ContactPhone       string             `json:"contact_phone"`
+	Status             string             `json:"status" gorm:"default:'pending'"` // pending, approved, rejected, more_info_required
+	ReviewNotes        string             `json:"review_notes" gorm:"type:text"`
+	ReviewedByID       *uint              `json:"reviewed_by_id"`
+	ReviewedAt         *time.Time         `json:"reviewed_at"`
+	SubmittedAt        time.Time          `json:"submitted_at"`
+	Documents          []MerchantDocument `json:"documents" gorm:"foreignKey:ApplicationID"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt     `json:"-" gorm:"index"`
+}
+
+// MerchantDocument is a KYC file attached to an application. StoragePath is
+// its storage key under the private prefix, which /uploads never serves, so
+// it is only served to reviewers.
+type MerchantDocument struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	ApplicationID uint      `json:"application_id" gorm:"index;not null"`
+	DocumentType  string    `json:"document_type" gorm:"not null"` // certificate_of_incorporation, tin_certificate, trading_license, national_id, other
+	FileName      string    `json:"file_name"`
+	StoragePath   string    `json:"-" gorm:"not null"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Merchant application status constants
+const (
+	MerchantApplicationPending          = "pending"
+	MerchantApplicationApproved         = "approved"
+	MerchantApplicationRejected         = "rejected"
+	MerchantApplicationMoreInfoRequired = "more_info_required"
+)
+
+var MerchantDocumentTypes = map[string]bool{
+	"certificate_of_incorporation": true,
+	"tin_certificate":              true,
+	"trading_license":              true,
+	"national_id":                  true,
+	"other":                        true,
+}
+
+// IsEditable reports whether the merchant may still change the application
+func (a *MerchantApplication) IsEditable() bool {
+	return a.Status != MerchantApplicationApproved
+}
+
+// EnsureMerchantApplications gives merchants who already had approved deals
+// before KYC review existed an approved application, so they can keep
+// publishing. Merchants without approved deals go through review as usual.
+func EnsureMerchantApplications() {
+	var merchants []User
+	config.DB.Where("role = ?", RoleMerchant).
+		Where("id IN (?)", config.DB.Model(&Deal{}).Select("merchant_id").Where("status = ?", "approved")).
+		Where("id NOT IN (?)", config.DB.Unscoped().Model(&MerchantApplication{}).Select("user_id")).
+		Find(&merchants)
+
+	for _, merchant := range merchants {
+		now := time.Now()
+		application := MerchantApplication{
+			UserID:        merchant.ID,
+			BusinessName:  merchant.FirstName + " " + merchant.LastName,
+			ContactPerson: merchant.FirstName + " " + merchant.LastName,
+			ContactPhone:  merchant.Phone,
+			Status:        MerchantApplicationApproved,
+			ReviewNotes:   "Approved automatically: the merchant had approved deals before KYC review was introduced",
+			ReviewedAt:    &now,
+			SubmittedAt:   now,
+		}
+		if err := config.DB.Create(&application).Error; err != nil {
+			log.Printf("Failed to create merchant application for user %d: %v", merchant.ID, err)
+			continue
+		}
+		log.Printf("✅ Approved existing merchant %d with live deals", merchant.ID)
+	}
+}
+
+// IsMerchantApproved reports whether the merchant has an approved application
+func IsMerchantApproved(userID uint) bool {
+	var count int64
+	config.DB.Model(&MerchantApplication{}).
+		Where("user_id = ? AND status = ?", userID, MerchantApplicationApproved).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+// MerchantOrganisation groups the staff of one merchant business. Deals keep
+// pointing at the owner's user ID (Deal.MerchantID), so OwnerID is what every
+// merchant-scoped query filters on.
+type MerchantOrganisation struct {
+	ID        uint             `json:"id" gorm:"primaryKey"`
+	OwnerID   uint             `json:"owner_id" gorm:"uniqueIndex;not null"`
+	Name      string           `json:"name" gorm:"not null"`
+	Members   []MerchantMember `json:"members,omitempty" gorm:"foreignKey:OrganisationID"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	DeletedAt gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+type MerchantMember struct {
+	ID             uint                 `json:"id" gorm:"primaryKey"`
+	OrganisationID uint                 `json:"organisation_id" gorm:"index;not null"`
+	Organisation   MerchantOrganisation `json:"-" gorm:"foreignKey:OrganisationID"`
+	UserID         uint                 `json:"user_id" gorm:"uniqueIndex;not null"` // a user belongs to one organisation
+	User           User                 `json:"user" gorm:"foreignKey:UserID"`
+	Role           string               `json:"role" gorm:"not null"` // owner, manager, cashier
+	// GrantedMerchantRole is set when joining made the user a merchant, so
+	// removing them can make them a customer again
+	GrantedMerchantRole bool      `json:"-" gorm:"default:false"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+type MerchantStaffInvitation struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganisationID uint       `json:"organisation_id" gorm:"index;not null"`
+	Email          string     `json:"email" gorm:"not null"`
+	Role           string     `json:"role" gorm:"not null"`
+	Token          string     `json:"-" gorm:"size:64;uniqueIndex"`
+	InvitedByID    uint       `json:"invited_by_id"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Merchant staff role constants
+const (
+	MerchantRoleOwner   = "owner"
+	MerchantRoleManager = "manager"
+	MerchantRoleCashier = "cashier"
+)
+
+// Staff invitations are valid for 7 days
+const MerchantStaffInvitationTTL = 7 * 24 * time.Hour
+
+var ErrNotMerchantMember = errors.New("user is not a member of a merchant organisation")
+
+// HasRole reports whether the member holds one of the given roles
+func (m *MerchantMember) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if m.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// MerchantID is the user ID deals and vouchers of this organisation belong to
+func (m *MerchantMember) MerchantID() uint {
+	return m.Organisation.OwnerID
+}
+
+// GetMerchantMembership returns the user's organisation membership. Merchants
+// registered before organisations existed get a single-owner organisation
+// created on first use.
+func GetMerchantMembership(userID uint) (*MerchantMember, error) {
+	var member MerchantMember
+	err := config.DB.Preload("Organisation").Where("user_id = ?", userID).First(&member).Error
+	if err == nil {
+		return &member, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	var user User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.Role != RoleMerchant {
+		return nil, ErrNotMerchantMember
+	}
+
+	// Former staff never get an organisation of their own this way
+	var staffInvitations int64
+	config.DB.Model(&MerchantStaffInvitation{}).
+		Where("LOWER(email) = LOWER(?) AND accepted_at IS NOT NULL", user.Email).
+		Count(&staffInvitations)
+	if staffInvitations > 0 {
+		return nil, ErrNotMerchantMember
+	}
+
+	name := user.FirstName + " " + user.LastName
+	var application MerchantApplication
+	if err := config.DB.Where("user_id = ?", userID).First(&application).Error; err == nil {
+		name = application.BusinessName
+	}
+
+	organisation := MerchantOrganisation{OwnerID: userID, Name: name}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organisation).Error; err != nil {
+			return err
+		}
+		member = MerchantMember{OrganisationID: organisation.ID, UserID: userID, Role: MerchantRoleOwner}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	member.Organisation = organisation
+	return &member, nil
+}
+
+// Generate invitation token
+func (i *MerchantStaffInvitation) GenerateToken() error {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+
+	i.Token = fmt.Sprintf("%x", bytes)
+	i.ExpiresAt = time.Now().Add(MerchantStaffInvitationTTL)
+
+	return nil
+}
+
+// IsPending reports whether the invitation can still be accepted
+func (i *MerchantStaffInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
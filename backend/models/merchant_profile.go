@@ -0,0 +1,168 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+type Branch struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Phone   string `json:"phone"`
+}
+
+// BranchList is stored as a JSON array, like StringArray
+type BranchList []Branch
+
+// StringMap is stored as a JSON object. Used for opening hours keyed by
+// weekday ("monday": "08:00-17:00") and social links keyed by network.
+type StringMap map[string]string
+
+func (b *BranchList) Scan(value interface{}) error {
+	if value == nil {
+		*b = BranchList{}
+		return nil
+	}
+	return scanJSON(value, b)
+}
+
+func (b BranchList) Value() (driver.Value, error) {
+	if len(b) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(b)
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = StringMap{}
+		return nil
+	}
+	return scanJSON(value, m)
+}
+
+func (m StringMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(m)
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return errors.New("cannot scan JSON column")
+	}
+}
+
+// MerchantProfile is the public face of a merchant's business. Deals only
+// expose this, never the merchant's personal User fields.
+type MerchantProfile struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	UserID       uint           `json:"merchant_id" gorm:"uniqueIndex;not null"`
+	Slug         string         `json:"slug" gorm:"uniqueIndex;not null"`
+	DisplayName  string         `json:"display_name" gorm:"not null"`
+	LogoURL      string         `json:"logo_url"`
+	CoverURL     string         `json:"cover_url"`
+	Description  string         `json:"description" gorm:"type:text"`
+	PublicPhone  string         `json:"public_phone"`
+	PublicEmail  string         `json:"public_email"`
+	Website      string         `json:"website"`
+	OpeningHours StringMap      `json:"opening_hours" gorm:"type:text"`
+	Branches     BranchList     `json:"branches" gorm:"type:text"`
+	SocialLinks  StringMap      `json:"social_links" gorm:"type:text"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PublicMerchant is the merchant summary embedded in public deal responses
+type PublicMerchant struct {
+	ID          uint   `json:"id"`
+	DisplayName string `json:"display_name"`
+	Slug        string `json:"slug"`
+	LogoURL     string `json:"logo_url"`
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// GenerateSlug derives a unique URL slug from the display name
+func (p *MerchantProfile) GenerateSlug() {
+	base := strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(p.DisplayName), "-"), "-")
+	if base == "" {
+		base = "merchant"
+	}
+
+	slug := base
+	for i := 2; ; i++ {
+		var count int64
+		config.DB.Model(&MerchantProfile{}).Unscoped().
+			Where("slug = ? AND user_id <> ?", slug, p.UserID).
+			Count(&count)
+		if count == 0 {
+			break
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	p.Slug = slug
+}
+
+// Public summary of the profile
+func (p *MerchantProfile) Public() PublicMerchant {
+	return PublicMerchant{
+		ID:          p.UserID,
+		DisplayName: p.DisplayName,
+		Slug:        p.Slug,
+		LogoURL:     p.LogoURL,
+	}
+}
+
+// GetPublicMerchants returns public summaries keyed by merchant user ID.
+// Merchants without a profile fall back to their verified business name.
+func GetPublicMerchants(merchantIDs []uint) map[uint]PublicMerchant {
+	result := make(map[uint]PublicMerchant)
+	if len(merchantIDs) == 0 {
+		return result
+	}
+
+	var profiles []MerchantProfile
+	config.DB.Where("user_id IN ?", merchantIDs).Find(&profiles)
+	for _, profile := range profiles {
+		result[profile.UserID] = profile.Public()
+	}
+
+	var missing []uint
+	for _, id := range merchantIDs {
+		if _, ok := result[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) > 0 {
+		var applications []MerchantApplication
+		config.DB.Select("user_id, business_name").Where("user_id IN ?", missing).Find(&applications)
+		for _, application := range applications {
+			result[application.UserID] = PublicMerchant{ID: application.UserID, DisplayName: application.BusinessName}
+		}
+	}
+
+	for _, id := range missing {
+		if _, ok := result[id]; !ok {
+			result[id] = PublicMerchant{ID: id, DisplayName: "Snapadeal Merchant"}
+		}
+	}
+
+	return result
+}
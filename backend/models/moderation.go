@@ -0,0 +1,73 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+// DealClaimDuration is how long an admin's claim on a pending deal lasts.
+// After that the deal goes back to the unclaimed queue, so a reviewer who
+// walks away doesn't block it. Claiming again refreshes the claim.
+const DealClaimDuration = 30 * time.Minute
+
+// DealClaimCutoff is the claimed_at before which claims have lapsed
+func DealClaimCutoff() time.Time {
+	return time.Now().Add(-DealClaimDuration)
+}
+
+// ClaimableBy limits a deals query to deals that adminID may act on: not
+// claimed, claimed by them, or with a lapsed claim
+func ClaimableBy(db *gorm.DB, adminID uint) *gorm.DB {
+	return db.Where("(claimed_by_id IS NULL OR claimed_by_id = ? OR claimed_at < ?)", adminID, DealClaimCutoff())
+}
+
+// ClaimExpiresAt is when the deal's current claim lapses, nil if unclaimed
+func (d *Deal) ClaimExpiresAt() *time.Time {
+	if d.ClaimedByID == nil || d.ClaimedAt == nil {
+		return nil
+	}
+	expires := d.ClaimedAt.Add(DealClaimDuration)
+	if expires.Before(time.Now()) {
+		return nil
+	}
+	return &expires
+}
+
+// RejectionReason is a canned explanation admins pick when rejecting deals
+type RejectionReason struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Title     string    `json:"title" gorm:"unique;not null"`
+	Message   string    `json:"message" gorm:"type:text;not null"` // sent to the merchant
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var defaultRejectionReasons = []RejectionReason{
+	{Title: "Poor image quality", Message: "The deal images are blurry, too small or don't show what is on offer. Please upload clear photos of the product or service."},
+	{Title: "Misleading pricing", Message: "The original price or discount doesn't look accurate. Please use the regular price customers would normally pay."},
+	{Title: "Incomplete description", Message: "The description doesn't explain clearly what customers get. Please add what is included and any conditions."},
+	{Title: "Invalid dates", Message: "The deal dates are in the past or the deal runs for too short a time. Please choose valid start and end dates."},
+	{Title: "Prohibited content", Message: "This deal offers something that isn't allowed on Snapadeal. Please review our merchant guidelines."},
+}
+
+// SeedRejectionReasons creates the default canned reasons on first start
+func SeedRejectionReasons() {
+	var count int64
+	config.DB.Model(&RejectionReason{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	for i, reason := range defaultRejectionReasons {
+		reason.SortOrder = i + 1
+		reason.IsActive = true
+		if err := config.DB.Create(&reason).Error; err != nil {
+			log.Printf("Failed to seed rejection reason %q: %v", reason.Title, err)
+		}
+	}
+}
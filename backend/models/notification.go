@@ -14,6 +14,7 @@ type Notification struct {
 	Type      string    `json:"type" gorm:"not null"` // deal_approved, deal_rejected, deal_purchased, etc.
 	IsRead    bool      `json:"is_read" gorm:"default:false"`
 	Data      string    `json:"data" gorm:"type:text"` // JSON data for additional info
+	BroadcastID *uint   `json:"broadcast_id,omitempty" gorm:"index"` // set on broadcast notifications
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -21,9 +22,16 @@ type Notification struct {
 
 // Notification types constants
 const (
-	NotificationDealApproved  = "deal_approved"
-	NotificationDealRejected  = "deal_rejected"
-	NotificationDealPurchased = "deal_purchased"
-	NotificationDealExpiring  = "deal_expiring"
-	NotificationSystemUpdate  = "system_update"
+	NotificationDealApproved         = "deal_approved"
+	NotificationDealRejected         = "deal_rejected"
+	NotificationDealPurchased        = "deal_purchased"
+	NotificationDealExpiring         = "deal_expiring"
+	NotificationSystemUpdate         = "system_update"
+	NotificationMerchantApproved     = "merchant_approved"
+	NotificationMerchantRejected     = "merchant_rejected"
+	NotificationMerchantInfoRequired = "merchant_info_required"
+	NotificationSecurityAlert        = "security_alert"
+	NotificationPurchaseReceipt      = "purchase_receipt"
+	NotificationTransactionRefunded  = "transaction_refunded"
+	NotificationAnnouncement         = "announcement" // admin broadcasts
 )
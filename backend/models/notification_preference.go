@@ -0,0 +1,189 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"snapadeal/config"
+)
+
+// Notification delivery channels
+const (
+	NotificationChannelInApp = "in_app"
+	NotificationChannelEmail = "email"
+	NotificationChannelSMS   = "sms"
+)
+
+var NotificationChannels = []string{NotificationChannelInApp, NotificationChannelEmail, NotificationChannelSMS}
+
+// NotificationPreference overrides the role default for one notification type
+// on one channel. Only overrides are stored.
+type NotificationPreference struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_notification_preference"`
+	Type      string    `json:"type" gorm:"not null;uniqueIndex:idx_notification_preference"`
+	Channel   string    `json:"channel" gorm:"not null;uniqueIndex:idx_notification_preference"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationSetting is the effective state of one type/channel pair for a user
+type NotificationSetting struct {
+	Type      string `json:"type"`
+	Channel   string `json:"channel"`
+	Enabled   bool   `json:"enabled"`
+	Mandatory bool   `json:"mandatory"`
+}
+
+var ErrMandatoryNotification = errors.New("this notification is mandatory and cannot be disabled")
+
+// notificationDefaults lists, per role, the notification types the role
+// receives and the channels enabled for each until the user changes them
+var notificationDefaults = map[string]map[string][]string{
+	"customer": {
+		NotificationPurchaseReceipt:     {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationTransactionRefunded: {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationDealExpiring:        {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationSystemUpdate:        {NotificationChannelInApp},
+		NotificationAnnouncement:        {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationSecurityAlert:       {NotificationChannelInApp, NotificationChannelEmail},
+	},
+	"merchant": {
+		NotificationDealApproved:         {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationDealRejected:         {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationDealPurchased:        {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationDealExpiring:         {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationMerchantApproved:     {NotificationChannelInApp, NotificationChannelEmail, NotificationChannelSMS},
+		NotificationMerchantRejected:     {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationMerchantInfoRequired: {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationSystemUpdate:         {NotificationChannelInApp},
+		NotificationAnnouncement:         {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationSecurityAlert:        {NotificationChannelInApp, NotificationChannelEmail},
+	},
+	"admin": {
+		NotificationSystemUpdate:  {NotificationChannelInApp},
+		NotificationAnnouncement:  {NotificationChannelInApp, NotificationChannelEmail},
+		NotificationSecurityAlert: {NotificationChannelInApp, NotificationChannelEmail},
+	},
+}
+
+// mandatoryNotifications are type/channel pairs users cannot switch off:
+// security alerts, receipts, refunds and decisions about a merchant's account
+var mandatoryNotifications = map[string][]string{
+	NotificationPurchaseReceipt:      {NotificationChannelEmail},
+	NotificationTransactionRefunded:  {NotificationChannelEmail},
+	NotificationSecurityAlert:        {NotificationChannelInApp, NotificationChannelEmail},
+	NotificationMerchantApproved:     {NotificationChannelInApp, NotificationChannelEmail},
+	NotificationMerchantRejected:     {NotificationChannelInApp, NotificationChannelEmail},
+	NotificationMerchantInfoRequired: {NotificationChannelInApp, NotificationChannelEmail},
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func IsNotificationChannel(channel string) bool {
+	return containsString(NotificationChannels, channel)
+}
+
+func IsMandatoryNotification(notificationType, channel string) bool {
+	return containsString(mandatoryNotifications[notificationType], channel)
+}
+
+// NotificationTypesForRole returns the notification types a role can configure
+func NotificationTypesForRole(role string) []string {
+	var types []string
+	for _, notificationType := range []string{
+		NotificationDealApproved, NotificationDealRejected, NotificationDealPurchased,
+		NotificationDealExpiring, NotificationMerchantApproved, NotificationMerchantRejected,
+		NotificationMerchantInfoRequired, NotificationPurchaseReceipt, NotificationTransactionRefunded,
+		NotificationSystemUpdate, NotificationAnnouncement, NotificationSecurityAlert,
+	} {
+		if _, ok := notificationDefaults[role][notificationType]; ok {
+			types = append(types, notificationType)
+		}
+	}
+	return types
+}
+
+// NotificationPreferences resolves a user's effective channels
+type NotificationPreferences struct {
+	role      string
+	overrides map[string]bool // type + "/" + channel
+}
+
+// LoadNotificationPreferences reads the stored overrides for the user
+func LoadNotificationPreferences(user User) (NotificationPreferences, error) {
+	prefs := NotificationPreferences{role: user.Role, overrides: make(map[string]bool)}
+
+	var rows []NotificationPreference
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&rows).Error; err != nil {
+		return prefs, err
+	}
+	for _, row := range rows {
+		prefs.overrides[row.Type+"/"+row.Channel] = row.Enabled
+	}
+	return prefs, nil
+}
+
+// Enabled reports whether the notification type should go out on the channel.
+// Mandatory pairs always do; otherwise the user's override wins over the role
+// default. Types outside the role's defaults are delivered in-app only.
+func (p NotificationPreferences) Enabled(notificationType, channel string) bool {
+	if IsMandatoryNotification(notificationType, channel) {
+		return true
+	}
+	if enabled, ok := p.overrides[notificationType+"/"+channel]; ok {
+		return enabled
+	}
+	if defaults, ok := notificationDefaults[p.role][notificationType]; ok {
+		return containsString(defaults, channel)
+	}
+	return channel == NotificationChannelInApp
+}
+
+// Settings lists the effective state of every type and channel for the role
+func (p NotificationPreferences) Settings() []NotificationSetting {
+	var settings []NotificationSetting
+	for _, notificationType := range NotificationTypesForRole(p.role) {
+		for _, channel := range NotificationChannels {
+			settings = append(settings, NotificationSetting{
+				Type:      notificationType,
+				Channel:   channel,
+				Enabled:   p.Enabled(notificationType, channel),
+				Mandatory: IsMandatoryNotification(notificationType, channel),
+			})
+		}
+	}
+	return settings
+}
+
+// SetNotificationPreference stores the user's choice for a type and channel
+func SetNotificationPreference(userID uint, notificationType, channel string, enabled bool) error {
+	if !enabled && IsMandatoryNotification(notificationType, channel) {
+		return ErrMandatoryNotification
+	}
+
+	var pref NotificationPreference
+	err := config.DB.Where("user_id = ? AND type = ? AND channel = ?", userID, notificationType, channel).First(&pref).Error
+	if err != nil {
+		pref = NotificationPreference{UserID: userID, Type: notificationType, Channel: channel}
+	}
+	pref.Enabled = enabled
+	return config.DB.Save(&pref).Error
+}
+
+// UsersOptedOut returns the IDs of users who switched off the type on the channel
+func UsersOptedOut(notificationType, channel string) []uint {
+	var userIDs []uint
+	config.DB.Model(&NotificationPreference{}).
+		Where("type = ? AND channel = ? AND enabled = ?", notificationType, channel, false).
+		Pluck("user_id", &userIDs)
+	return userIDs
+}
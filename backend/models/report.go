@@ -0,0 +1,123 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"snapadeal/config"
+)
+
+// Scheduled report types
+const (
+	ReportMerchantSales = "merchant_sales" // merchant owners and managers
+	ReportAdminDigest   = "admin_digest"   // admins who can view statistics
+)
+
+// ReportDefinition describes a report users can subscribe to
+type ReportDefinition struct {
+	Key             string        `json:"key"`
+	Name            string        `json:"name"`
+	Description     string        `json:"description"`
+	DefaultSchedule string        `json:"default_schedule"` // cron expression, server time
+	DefaultPeriod   time.Duration `json:"-"`                // covered by the first run
+}
+
+var ReportDefinitions = []ReportDefinition{
+	{
+		Key:             ReportMerchantSales,
+		Name:            "Weekly sales summary",
+		Description:     "Sales, top deals, deals awaiting approval and refunds for your business",
+		DefaultSchedule: "0 7 * * 1",
+		DefaultPeriod:   7 * 24 * time.Hour,
+	},
+	{
+		Key:             ReportAdminDigest,
+		Name:            "Daily platform digest",
+		Description:     "Platform sales, top deals, the approval backlog, refunds and new users",
+		DefaultSchedule: "0 7 * * *",
+		DefaultPeriod:   24 * time.Hour,
+	},
+}
+
+// GetReportDefinition looks up a report by key
+func GetReportDefinition(key string) (ReportDefinition, bool) {
+	for _, definition := range ReportDefinitions {
+		if definition.Key == key {
+			return definition, true
+		}
+	}
+	return ReportDefinition{}, false
+}
+
+// ReportSubscription is a user's choice to receive a report by email on a
+// schedule. NextRunAt is worked out from Schedule whenever it changes and
+// after every run.
+type ReportSubscription struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_report_subscription"`
+	Report    string     `json:"report" gorm:"not null;uniqueIndex:idx_report_subscription"`
+	Schedule  string     `json:"schedule" gorm:"not null"` // cron expression, server time
+	Enabled   bool       `json:"enabled" gorm:"index"`
+	NextRunAt *time.Time `json:"next_run_at" gorm:"index"`
+	LastRunAt *time.Time `json:"last_run_at"` // end of the period last reported on
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Report run status constants
+const (
+	ReportRunSent    = "sent"    // handed to the email outbox
+	ReportRunFailed  = "failed"  // building or queueing the email failed
+	ReportRunSkipped = "skipped" // the recipient can no longer receive the report
+)
+
+// Report run triggers
+const (
+	ReportTriggerSchedule = "schedule"
+	ReportTriggerManual   = "manual"
+)
+
+// ReportSendCooldown is how long a user waits between sending a report on
+// demand, as each send builds the report and emails it
+const ReportSendCooldown = 15 * time.Minute
+
+// ReportRun records one attempt to produce and send a report
+type ReportRun struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint      `json:"subscription_id" gorm:"index;not null"`
+	UserID         uint      `json:"user_id" gorm:"index;not null"`
+	User           *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Report         string    `json:"report" gorm:"index;not null"`
+	Trigger        string    `json:"trigger" gorm:"not null"`
+	Status         string    `json:"status" gorm:"index;not null"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	Error          string    `json:"error,omitempty" gorm:"type:text"`
+	DurationMs     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index"`
+}
+
+// reportUnsubscribePrefix marks unsubscribe tokens that turn off a report
+// rather than a notification preference
+const reportUnsubscribePrefix = "report."
+
+// ReportUnsubscribeType is the notification type carried by a report's
+// unsubscribe link
+func ReportUnsubscribeType(report string) string {
+	return reportUnsubscribePrefix + report
+}
+
+// ParseReportUnsubscribeType returns the report an unsubscribe link is for
+func ParseReportUnsubscribeType(notificationType string) (string, bool) {
+	if !strings.HasPrefix(notificationType, reportUnsubscribePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(notificationType, reportUnsubscribePrefix), true
+}
+
+// DisableReportSubscription turns off the user's subscription to the report
+func DisableReportSubscription(userID uint, report string) error {
+	return config.DB.Model(&ReportSubscription{}).
+		Where("user_id = ? AND report = ?", userID, report).
+		Updates(map[string]interface{}{"enabled": false, "next_run_at": nil}).Error
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+type Permission struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Key         string    `json:"key" gorm:"unique;not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type Role struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"unique;not null"`
+	Description string         `json:"description"`
+	IsSystem    bool           `json:"is_system" gorm:"default:false"` // seeded roles can't be deleted
+	Permissions []Permission   `json:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Permission key constants
+const (
+	PermissionDealsView         = "deals.view"
+	PermissionDealsApprove      = "deals.approve"
+	PermissionDealsReject       = "deals.reject"
+	PermissionDealsManageOwn    = "deals.manage_own"
+	PermissionUsersView         = "users.view"
+	PermissionUsersSuspend      = "users.suspend"
+	PermissionUsersManage       = "users.manage"
+	PermissionTransactionsView  = "transactions.view"
+	PermissionRefundsIssue      = "refunds.issue"
+	PermissionStatsView         = "stats.view"
+	PermissionNotificationsSend = "notifications.broadcast"
+	PermissionRolesManage       = "roles.manage"
+	PermissionAdminsInvite      = "admins.invite"
+	PermissionMerchantsReview   = "merchants.review"
+	PermissionEmailsManage      = "emails.manage"
+	PermissionMediaManage       = "media.manage"
+	PermissionAuditView         = "audit.view"
+	PermissionCategoriesManage  = "categories.manage"
+	PermissionDealChecksManage  = "deal_checks.manage"
+)
+
+// Default role names, matching the legacy User.Role values
+const (
+	RoleAdmin    = "admin"
+	RoleMerchant = "merchant"
+	RoleCustomer = "customer"
+	RoleSupport  = "support"
+)
+
+var defaultPermissions = []Permission{
+	{Key: PermissionDealsView, Description: "View all deals regardless of status or merchant"},
+	{Key: PermissionDealsApprove, Description: "Approve pending deals"},
+	{Key: PermissionDealsReject, Description: "Reject pending deals"},
+	{Key: PermissionDealsManageOwn, Description: "Create, update and delete own deals"},
+	{Key: PermissionUsersView, Description: "View user accounts"},
+	{Key: PermissionUsersSuspend, Description: "Change user account status"},
+	{Key: PermissionUsersManage, Description: "Change user roles, force password resets and end sessions"},
+	{Key: PermissionTransactionsView, Description: "View all transactions"},
+	{Key: PermissionRefundsIssue, Description: "Issue refunds on transactions"},
+	{Key: PermissionStatsView, Description: "View platform dashboards and statistics"},
+	{Key: PermissionNotificationsSend, Description: "Broadcast notifications to users"},
+	{Key: PermissionRolesManage, Description: "Manage roles and role assignments"},
+	{Key: PermissionAdminsInvite, Description: "Invite new administrators"},
+	{Key: PermissionMerchantsReview, Description: "Review merchant onboarding applications"},
+	{Key: PermissionEmailsManage, Description: "Inspect and retry outbound emails"},
+	{Key: PermissionMediaManage, Description: "Inspect uploaded media and clean up orphaned files"},
+	{Key: PermissionAuditView, Description: "View and export the audit log of privileged actions"},
+	{Key: PermissionCategoriesManage, Description: "Create, edit, reorder and deactivate deal categories"},
+	{Key: PermissionDealChecksManage, Description: "Configure automated deal checks and blocked terms"},
+}
+
+// defaultRolePermissions mirrors the behaviour of the old RoleMiddleware:
+// admins can do everything, merchants manage their own deals.
+var defaultRolePermissions = map[string][]string{
+	RoleAdmin:    nil, // nil means every permission
+	RoleMerchant: {PermissionDealsManageOwn},
+	RoleCustomer: {},
+	RoleSupport: {
+		PermissionDealsView,
+		PermissionUsersView,
+		PermissionTransactionsView,
+		PermissionStatsView,
+	},
+}
+
+var defaultRoleDescriptions = map[string]string{
+	RoleAdmin:    "Full platform access",
+	RoleMerchant: "Merchant managing their own deals",
+	RoleCustomer: "Customer buying deals",
+	RoleSupport:  "Support agent with read-only access to deals, users and transactions",
+}
+
+// SeedRolesAndPermissions creates the default permissions and roles. The admin
+// role is re-synced on every start so it always holds newly added permissions;
+// other roles are only populated when first created so admin edits survive.
+func SeedRolesAndPermissions() {
+	for _, p := range defaultPermissions {
+		permission := p
+		if err := config.DB.Where("key = ?", permission.Key).
+			Attrs(Permission{Description: permission.Description}).
+			FirstOrCreate(&permission).Error; err != nil {
+			log.Printf("Failed to seed permission %s: %v", p.Key, err)
+		}
+	}
+
+	var allPermissions []Permission
+	config.DB.Find(&allPermissions)
+
+	for name, keys := range defaultRolePermissions {
+		var role Role
+		result := config.DB.Where("name = ?", name).First(&role)
+		created := false
+		if result.Error == gorm.ErrRecordNotFound {
+			role = Role{Name: name, Description: defaultRoleDescriptions[name], IsSystem: true}
+			if err := config.DB.Create(&role).Error; err != nil {
+				log.Printf("Failed to seed role %s: %v", name, err)
+				continue
+			}
+			created = true
+		}
+
+		if name == RoleAdmin {
+			config.DB.Model(&role).Association("Permissions").Replace(allPermissions)
+		} else if created && len(keys) > 0 {
+			var permissions []Permission
+			config.DB.Where("key IN ?", keys).Find(&permissions)
+			config.DB.Model(&role).Association("Permissions").Replace(permissions)
+		}
+	}
+}
+
+// GetUserPermissions returns the permission keys granted to a user, both via
+// the role named by User.Role and via explicit role assignments.
+func GetUserPermissions(userID uint) ([]string, error) {
+	var user User
+	if err := config.DB.Select("id, role").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err := config.DB.Model(&Permission{}).
+		Distinct("permissions.key").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_at IS NULL").
+		Where("roles.name = ? OR roles.id IN (?)", user.Role,
+			config.DB.Table("user_roles").Select("role_id").Where("user_id = ?", userID)).
+		Pluck("permissions.key", &keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// UserHasPermissions reports whether the user holds every one of the given permissions
+func UserHasPermissions(userID uint, required ...string) (bool, error) {
+	keys, err := GetUserPermissions(userID)
+	if err != nil {
+		return false, err
+	}
+
+	granted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		granted[key] = true
+	}
+
+	for _, key := range required {
+		if !granted[key] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -16,7 +16,8 @@ type Transaction struct {
 	PaymentMethod    string    `json:"payment_method" gorm:"not null"` // mtn_money, airtel_money
 	PhoneNumber      string    `json:"phone_number" gorm:"not null"`
 	PaymentReference string    `json:"payment_reference"`
-	Status           string    `json:"status" gorm:"default:'pending'"` // pending, completed, failed
+	Status           string    `json:"status" gorm:"default:'pending'"` // pending, completed, failed, refunded
+	Vouchers         []Voucher `json:"vouchers,omitempty" gorm:"foreignKey:TransactionID"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
@@ -27,4 +28,5 @@ const (
 	TransactionStatusPending   = "pending"
 	TransactionStatusCompleted = "completed"
 	TransactionStatusFailed    = "failed"
+	TransactionStatusRefunded  = "refunded"
 )
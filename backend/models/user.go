@@ -8,7 +8,6 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
-	"snapadeal/config"
 )
 
 type User struct {
@@ -20,6 +19,8 @@ type User struct {
 	Password          string         `json:"-" gorm:"not null"`
 	Role              string         `json:"role" gorm:"default:'customer'"` // customer, merchant, admin
 	Status            string         `json:"status" gorm:"default:'pending'"` // pending, active, inactive, suspended
+	StatusReason      string         `json:"status_reason,omitempty" gorm:"type:text"` // why an admin last changed the status
+	StatusChangedAt   *time.Time     `json:"status_changed_at,omitempty"`
 	IsVerified        bool           `json:"is_verified" gorm:"default:false"`
 	EmailVerifiedAt   *time.Time     `json:"email_verified_at"`
 	OTPCode           string         `json:"-" gorm:"size:6"`
@@ -27,11 +28,40 @@ type User struct {
 	ResetToken        string         `json:"-" gorm:"size:64"`
 	ResetTokenExpires *time.Time     `json:"-"`
 	LastLoginAt       *time.Time     `json:"last_login_at"`
+	Locale            string         `json:"locale" gorm:"default:'en'"` // email language, e.g. en, sw
+	Roles             []Role         `json:"roles,omitempty" gorm:"many2many:user_roles"` // extra roles on top of Role
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
 	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// User status constants
+const (
+	UserStatusPending   = "pending" // email not verified yet
+	UserStatusActive    = "active"
+	UserStatusInactive  = "inactive"
+	UserStatusSuspended = "suspended"
+)
+
+// userStatusTransitions lists the statuses an admin can move a user to from
+// each status. Pending users become active by verifying their email.
+var userStatusTransitions = map[string][]string{
+	UserStatusPending:   {UserStatusSuspended},
+	UserStatusActive:    {UserStatusInactive, UserStatusSuspended},
+	UserStatusInactive:  {UserStatusActive, UserStatusSuspended},
+	UserStatusSuspended: {UserStatusActive, UserStatusInactive},
+}
+
+// UserStatusTransitions returns the statuses a user can be moved to
+func UserStatusTransitions(from string) []string {
+	return userStatusTransitions[from]
+}
+
+// CanChangeUserStatus reports whether an admin may move a user between statuses
+func CanChangeUserStatus(from, to string) bool {
+	return containsString(userStatusTransitions[from], to)
+}
+
 func (u *User) HashPassword() error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -41,6 +71,17 @@ func (u *User) HashPassword() error {
 	return nil
 }
 
+// ScramblePassword replaces the password with a random one nobody knows, so
+// the user has to set a new one through a reset link
+func (u *User) ScramblePassword() error {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+	u.Password = fmt.Sprintf("%x", bytes)
+	return u.HashPassword()
+}
+
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
@@ -130,26 +171,3 @@ func (u *User) UpdateLastLogin() {
 	now := time.Now()
 	u.LastLoginAt = &now
 }
-
-func CreateDefaultAdmin() {
-	var admin User
-	result := config.DB.Where("email = ?", "admin@snapadeal.com").First(&admin)
-	
-	if result.Error == gorm.ErrRecordNotFound {
-		admin = User{
-			FirstName:       "Super",
-			LastName:        "Admin",
-			Email:           "admin@snapadeal.com",
-			Phone:           "+256700000000",
-			Password:        "admin123",
-			Role:            "admin",
-			Status:          "active",
-			IsVerified:      true,
-			EmailVerifiedAt: &time.Time{},
-		}
-		now := time.Now()
-		admin.EmailVerifiedAt = &now
-		admin.HashPassword()
-		config.DB.Create(&admin)
-	}
-}
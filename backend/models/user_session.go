@@ -0,0 +1,94 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"snapadeal/config"
+)
+
+// SessionTTL is how long a login lasts, matching the JWT expiry
+const SessionTTL = 7 * 24 * time.Hour
+
+// sessionTouchInterval limits how often LastSeenAt is written, so an active
+// session doesn't cost a write on every request
+const sessionTouchInterval = 5 * time.Minute
+
+var ErrSessionRevoked = errors.New("session has ended, please log in again")
+
+// UserSession is one login. Its TokenID goes in the JWT, so revoking the
+// session logs that device out even though the token hasn't expired.
+type UserSession struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"index;not null"`
+	TokenID       string     `json:"-" gorm:"size:32;uniqueIndex;not null"`
+	IPAddress     string     `json:"ip_address"`
+	UserAgent     string     `json:"user_agent" gorm:"type:text"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the session can still be used
+func (s *UserSession) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// CreateUserSession records a new login for the user
+func CreateUserSession(userID uint, ipAddress, userAgent string) (*UserSession, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := UserSession{
+		UserID:     userID,
+		TokenID:    hex.EncodeToString(bytes),
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(SessionTTL),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ActiveSession returns the live session for a token ID and notes that it
+// was just used
+func ActiveSession(tokenID, ipAddress string) (*UserSession, error) {
+	var session UserSession
+	if err := config.DB.Where("token_id = ?", tokenID).First(&session).Error; err != nil {
+		return nil, ErrSessionRevoked
+	}
+	if !session.IsActive() {
+		return nil, ErrSessionRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		config.DB.Model(&session).Updates(map[string]interface{}{"last_seen_at": time.Now(), "ip_address": ipAddress})
+	}
+	return &session, nil
+}
+
+// SessionsRevokedHook is called after a user's sessions are revoked. main
+// points it at the notification hub so open streams close as well.
+var SessionsRevokedHook func(userID uint)
+
+// RevokeUserSessions ends every active session of the user and returns how
+// many there were
+func RevokeUserSessions(userID uint, reason string) (int64, error) {
+	result := config.DB.Model(&UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	if result.Error == nil && result.RowsAffected > 0 && SessionsRevokedHook != nil {
+		SessionsRevokedHook(userID)
+	}
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"snapadeal/config"
+)
+
+// Voucher is one redeemable unit of a completed purchase
+type Voucher struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Code          string     `json:"code" gorm:"uniqueIndex;size:16;not null"`
+	TransactionID uint       `json:"transaction_id" gorm:"index;not null"`
+	DealID        uint       `json:"deal_id" gorm:"index;not null"`
+	Deal          Deal       `json:"deal,omitempty" gorm:"foreignKey:DealID"`
+	UserID        uint       `json:"user_id" gorm:"index;not null"`
+	MerchantID    uint       `json:"merchant_id" gorm:"index;not null"`
+	Status        string     `json:"status" gorm:"default:'active'"` // active, redeemed, void
+	RedeemedAt    *time.Time `json:"redeemed_at"`
+	RedeemedByID  *uint      `json:"redeemed_by_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Voucher status constants
+const (
+	VoucherStatusActive   = "active"
+	VoucherStatusRedeemed = "redeemed"
+	VoucherStatusVoid     = "void" // the purchase was refunded
+)
+
+var ErrVoucherRedeemed = errors.New("voucher has already been redeemed")
+
+// Unambiguous characters only, so codes can be read out over the counter
+const voucherAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func generateVoucherCode() (string, error) {
+	code := make([]byte, 10)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(voucherAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = voucherAlphabet[n.Int64()]
+	}
+	return "SNAP-" + string(code), nil
+}
+
+// IssueVouchers creates one voucher per purchased unit of a completed
+// transaction. It is idempotent so repeated payment callbacks are harmless.
+func IssueVouchers(transaction Transaction, merchantID uint) ([]Voucher, error) {
+	var vouchers []Voucher
+	config.DB.Where("transaction_id = ?", transaction.ID).Find(&vouchers)
+	if len(vouchers) > 0 {
+		return vouchers, nil
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < transaction.Quantity; i++ {
+			code, err := generateVoucherCode()
+			if err != nil {
+				return err
+			}
+			voucher := Voucher{
+				Code:          code,
+				TransactionID: transaction.ID,
+				DealID:        transaction.DealID,
+				UserID:        transaction.UserID,
+				MerchantID:    merchantID,
+				Status:        VoucherStatusActive,
+			}
+			if err := tx.Create(&voucher).Error; err != nil {
+				return err
+			}
+			vouchers = append(vouchers, voucher)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vouchers, nil
+}
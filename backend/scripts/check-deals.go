@@ -48,7 +48,7 @@ func main() {
 	if approvedDeals == 0 && totalDeals > 0 {
 		fmt.Println("\n⚠️  You have deals but none are approved!")
 		fmt.Println("💡 To approve deals:")
-		fmt.Println("   1. Login as admin (admin@snapadeal.com / admin123)")
+		fmt.Println("   1. Login as an admin")
 		fmt.Println("   2. Go to admin panel and approve pending deals")
 		fmt.Println("   3. Or use the API: PUT /api/v1/admin/deals/{id}/approve")
 	}
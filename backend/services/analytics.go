@@ -0,0 +1,385 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+
+	"gorm.io/gorm"
+)
+
+// Analytics bucket sizes. Weeks start on Monday.
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// Analytics grouping
+const (
+	GroupByCategory = "category"
+	GroupByMerchant = "merchant"
+)
+
+// MaxAnalyticsBuckets keeps a request from asking for years of daily buckets
+const MaxAnalyticsBuckets = 400
+
+// AnalyticsQuery selects the transactions to aggregate. To is exclusive and
+// neither end has to line up with a bucket.
+type AnalyticsQuery struct {
+	From       time.Time
+	To         time.Time
+	Interval   string
+	GroupBy    string // "", category or merchant
+	MerchantID uint   // 0 for every merchant
+	CategoryID uint   // includes subcategories
+}
+
+// AnalyticsPoint holds the metrics for one bucket. Orders, units and revenue
+// count completed transactions; attempts count every transaction started.
+type AnalyticsPoint struct {
+	Bucket       string  `json:"bucket,omitempty"` // first day of the bucket, YYYY-MM-DD
+	Revenue      float64 `json:"revenue"`
+	Orders       int64   `json:"orders"`
+	Units        int64   `json:"units"`
+	Attempts     int64   `json:"attempts"`
+	Conversion   float64 `json:"conversion"`    // orders / attempts
+	NewCustomers int64   `json:"new_customers"` // first completed purchase within the series
+}
+
+type AnalyticsSeries struct {
+	GroupID uint             `json:"group_id,omitempty"`
+	Label   string           `json:"label"`
+	Points  []AnalyticsPoint `json:"points"`
+	Totals  AnalyticsPoint   `json:"totals"`
+}
+
+type AnalyticsCount struct {
+	Bucket string `json:"bucket"`
+	Count  int64  `json:"count"`
+}
+
+// ValidateAnalyticsQuery checks the interval, grouping and bucket count
+func ValidateAnalyticsQuery(q AnalyticsQuery) error {
+	if q.Interval != IntervalDay && q.Interval != IntervalWeek && q.Interval != IntervalMonth {
+		return errors.New("interval must be day, week or month")
+	}
+	if q.GroupBy != "" && q.GroupBy != GroupByCategory && q.GroupBy != GroupByMerchant {
+		return errors.New("group_by must be category or merchant")
+	}
+	if !q.To.After(q.From) {
+		return errors.New("to must be after from")
+	}
+	if len(analyticsBuckets(q)) > MaxAnalyticsBuckets {
+		return fmt.Errorf("range too long, at most %d %s buckets", MaxAnalyticsBuckets, q.Interval)
+	}
+	return nil
+}
+
+// Buckets follow the server's time zone as a fixed UTC offset, which is
+// right for Uganda (no daylight saving). SQLite keeps timestamps as text
+// with whatever offset they were written in, so columns go through
+// datetime() there to compare as UTC.
+func isPostgres() bool {
+	return config.DB.Dialector.Name() == "postgres"
+}
+
+func localOffsetSeconds() int {
+	_, offset := time.Now().Zone()
+	return offset
+}
+
+func bucketExpr(column, interval string) string {
+	offset := localOffsetSeconds()
+	if isPostgres() {
+		local := fmt.Sprintf("(%s AT TIME ZONE 'UTC' + INTERVAL '%d seconds')", column, offset)
+		return fmt.Sprintf("to_char(date_trunc('%s', %s), 'YYYY-MM-DD')", interval, local)
+	}
+
+	shift := fmt.Sprintf("'%+d seconds'", offset)
+	switch interval {
+	case IntervalWeek:
+		return fmt.Sprintf("date(%s, %s, 'weekday 0', '-6 days')", column, shift)
+	case IntervalMonth:
+		return fmt.Sprintf("strftime('%%Y-%%m-01', %s, %s)", column, shift)
+	}
+	return fmt.Sprintf("date(%s, %s)", column, shift)
+}
+
+// utcColumn makes a timestamp column compare and aggregate as UTC
+func utcColumn(column string) string {
+	if isPostgres() {
+		return column
+	}
+	return "datetime(" + column + ")"
+}
+
+// timeRange is a where clause with its arguments limiting column to [from, to)
+func timeRange(column string, from, to time.Time) (string, []interface{}) {
+	column = utcColumn(column)
+	if isPostgres() {
+		return column + " >= ? AND " + column + " < ?", []interface{}{from, to}
+	}
+	const layout = "2006-01-02 15:04:05"
+	return column + " >= ? AND " + column + " < ?",
+		[]interface{}{from.UTC().Format(layout), to.UTC().Format(layout)}
+}
+
+// bucketStart is the start of the bucket t falls in
+func bucketStart(t time.Time, interval string) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return t.AddDate(0, 0, 1-t.Day())
+	}
+	return t
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// analyticsBuckets lists every bucket in the range so empty ones show as zero
+func analyticsBuckets(q AnalyticsQuery) []string {
+	var buckets []string
+	for t := bucketStart(q.From, q.Interval); t.Before(q.To); t = nextBucket(t, q.Interval) {
+		buckets = append(buckets, t.Format("2006-01-02"))
+		if len(buckets) > MaxAnalyticsBuckets {
+			break
+		}
+	}
+	return buckets
+}
+
+// groupColumn is the deal column series are split by, empty without GroupBy
+func groupColumn(groupBy string) string {
+	switch groupBy {
+	case GroupByCategory:
+		return "d.category_id"
+	case GroupByMerchant:
+		return "d.merchant_id"
+	}
+	return ""
+}
+
+// scopedTransactions is the base query over transactions joined to their
+// deal, limited to the query's merchant and category
+func scopedTransactions(q AnalyticsQuery) *gorm.DB {
+	query := config.DB.Table("transactions AS t").
+		Joins("JOIN deals AS d ON d.id = t.deal_id").
+		Where("t.deleted_at IS NULL")
+	if q.MerchantID != 0 {
+		query = query.Where("d.merchant_id = ?", q.MerchantID)
+	}
+	if q.CategoryID != 0 {
+		query = query.Where("d.category_id IN ?", models.CategoryDescendantIDs(q.CategoryID))
+	}
+	return query
+}
+
+// TransactionAnalytics aggregates transactions into one series per group,
+// or a single series without GroupBy, ordered by revenue
+func TransactionAnalytics(q AnalyticsQuery) ([]AnalyticsSeries, AnalyticsPoint, error) {
+	bucket := bucketExpr("t.created_at", q.Interval)
+	completed := models.TransactionStatusCompleted
+
+	// Without grouping every row lands in series 0. A literal 0 can't go in
+	// GROUP BY, both databases would read it as a column position.
+	group, groupBy, firstGroupBy := groupColumn(q.GroupBy), "bucket, group_id", "t.user_id, group_id"
+	if group == "" {
+		group, groupBy, firstGroupBy = "0", "bucket", "t.user_id"
+	}
+
+	var rows []struct {
+		Bucket   string
+		GroupID  uint
+		Attempts int64
+		Orders   int64
+		Units    int64
+		Revenue  float64
+	}
+	where, args := timeRange("t.created_at", q.From, q.To)
+	err := scopedTransactions(q).
+		Select(fmt.Sprintf(`%s AS bucket, %s AS group_id, COUNT(*) AS attempts,
+			SUM(CASE WHEN t.status = '%s' THEN 1 ELSE 0 END) AS orders,
+			COALESCE(SUM(CASE WHEN t.status = '%s' THEN t.quantity ELSE 0 END), 0) AS units,
+			COALESCE(SUM(CASE WHEN t.status = '%s' THEN t.amount ELSE 0 END), 0) AS revenue`,
+			bucket, group, completed, completed, completed)).
+		Where(where, args...).
+		Group(groupBy).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, AnalyticsPoint{}, err
+	}
+
+	// A customer is new to a series in the bucket of their first completed
+	// purchase in it. Customers who first bought before the range aren't new.
+	var firsts []struct {
+		Bucket  string
+		GroupID uint
+		Count   int64
+	}
+	firstQuery := scopedTransactions(q).
+		Select(fmt.Sprintf("t.user_id, %s AS group_id, MIN(%s) AS first_at", group, utcColumn("t.created_at"))).
+		Where("t.status = ?", completed).
+		Group(firstGroupBy)
+	where, args = timeRange("firsts.first_at", q.From, q.To)
+	err = config.DB.Table("(?) AS firsts", firstQuery).
+		Select(fmt.Sprintf("%s AS bucket, firsts.group_id AS group_id, COUNT(*) AS count", bucketExpr("firsts.first_at", q.Interval))).
+		Where(where, args...).
+		Group("bucket, firsts.group_id").
+		Scan(&firsts).Error
+	if err != nil {
+		return nil, AnalyticsPoint{}, err
+	}
+
+	buckets := analyticsBuckets(q)
+	index := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		index[b] = i
+	}
+
+	seriesByGroup := make(map[uint]*AnalyticsSeries)
+	seriesFor := func(groupID uint) *AnalyticsSeries {
+		series, ok := seriesByGroup[groupID]
+		if !ok {
+			series = &AnalyticsSeries{GroupID: groupID, Points: make([]AnalyticsPoint, len(buckets))}
+			for i, b := range buckets {
+				series.Points[i].Bucket = b
+			}
+			seriesByGroup[groupID] = series
+		}
+		return series
+	}
+	if q.GroupBy == "" {
+		seriesFor(0)
+	}
+
+	for _, row := range rows {
+		i, ok := index[row.Bucket]
+		if !ok {
+			continue
+		}
+		point := &seriesFor(row.GroupID).Points[i]
+		point.Attempts += row.Attempts
+		point.Orders += row.Orders
+		point.Units += row.Units
+		point.Revenue += row.Revenue
+	}
+	for _, first := range firsts {
+		if i, ok := index[first.Bucket]; ok {
+			seriesFor(first.GroupID).Points[i].NewCustomers += first.Count
+		}
+	}
+
+	var totals AnalyticsPoint
+	series := make([]AnalyticsSeries, 0, len(seriesByGroup))
+	for _, s := range seriesByGroup {
+		for i := range s.Points {
+			point := &s.Points[i]
+			point.Conversion = conversion(point.Orders, point.Attempts)
+			addAnalyticsPoint(&s.Totals, *point)
+			addAnalyticsPoint(&totals, *point)
+		}
+		s.Totals.Conversion = conversion(s.Totals.Orders, s.Totals.Attempts)
+		series = append(series, *s)
+	}
+	totals.Conversion = conversion(totals.Orders, totals.Attempts)
+
+	labelAnalyticsSeries(series, q.GroupBy)
+	sort.SliceStable(series, func(i, j int) bool {
+		if series[i].Totals.Revenue != series[j].Totals.Revenue {
+			return series[i].Totals.Revenue > series[j].Totals.Revenue
+		}
+		return series[i].Label < series[j].Label
+	})
+
+	return series, totals, nil
+}
+
+func addAnalyticsPoint(total *AnalyticsPoint, point AnalyticsPoint) {
+	total.Revenue += point.Revenue
+	total.Orders += point.Orders
+	total.Units += point.Units
+	total.Attempts += point.Attempts
+	total.NewCustomers += point.NewCustomers
+}
+
+func conversion(orders, attempts int64) float64 {
+	if attempts == 0 {
+		return 0
+	}
+	return math.Round(float64(orders)/float64(attempts)*10000) / 10000
+}
+
+func labelAnalyticsSeries(series []AnalyticsSeries, groupBy string) {
+	labels := make(map[uint]string)
+	switch groupBy {
+	case GroupByCategory:
+		var categories []models.Category
+		config.DB.Unscoped().Select("id, name").Find(&categories)
+		for _, category := range categories {
+			labels[category.ID] = category.Name
+		}
+	case GroupByMerchant:
+		var ids []uint
+		for _, s := range series {
+			ids = append(ids, s.GroupID)
+		}
+		var merchants []models.User
+		config.DB.Unscoped().Select("id, first_name, last_name").Where("id IN ?", ids).Find(&merchants)
+		for _, merchant := range merchants {
+			labels[merchant.ID] = merchant.FirstName + " " + merchant.LastName
+		}
+	}
+
+	for i := range series {
+		switch {
+		case groupBy == "":
+			series[i].Label = "All"
+		case labels[series[i].GroupID] != "":
+			series[i].Label = labels[series[i].GroupID]
+		default:
+			series[i].Label = fmt.Sprintf("#%d", series[i].GroupID)
+		}
+	}
+}
+
+// NewUserAnalytics counts registrations per bucket, zero-filled
+func NewUserAnalytics(q AnalyticsQuery) ([]AnalyticsCount, error) {
+	var rows []AnalyticsCount
+	where, args := timeRange("created_at", q.From, q.To)
+	err := config.DB.Model(&models.User{}).
+		Select(fmt.Sprintf("%s AS bucket, COUNT(*) AS count", bucketExpr("created_at", q.Interval))).
+		Where(where, args...).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
+	}
+
+	buckets := analyticsBuckets(q)
+	result := make([]AnalyticsCount, len(buckets))
+	for i, b := range buckets {
+		result[i] = AnalyticsCount{Bucket: b, Count: counts[b]}
+	}
+	return result, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+
+	"gorm.io/gorm"
+)
+
+// BroadcastScheduler sends admin broadcasts once they fall due. Broadcasts
+// created without a schedule are due immediately and sent on the next wake.
+type BroadcastScheduler struct {
+	email        *EmailService
+	wake         chan struct{}
+	pollInterval time.Duration
+	batchSize    int
+}
+
+// Broadcasts is the shared scheduler, created and started from main once the
+// environment is loaded
+var Broadcasts *BroadcastScheduler
+
+func NewBroadcastScheduler(email *EmailService) *BroadcastScheduler {
+	return &BroadcastScheduler{
+		email:        email,
+		wake:         make(chan struct{}, 1),
+		pollInterval: 30 * time.Second,
+		batchSize:    500,
+	}
+}
+
+// Start launches the scheduler loop
+func (s *BroadcastScheduler) Start() {
+	// Broadcasts interrupted mid-send are resumed; users with a recipient
+	// row are skipped
+	config.DB.Model(&models.Broadcast{}).
+		Where("status = ?", models.BroadcastStatusSending).
+		Update("status", models.BroadcastStatusScheduled)
+
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.sendDue()
+
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Wake makes the scheduler check for due broadcasts now
+func (s *BroadcastScheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *BroadcastScheduler) sendDue() {
+	var due []models.Broadcast
+	if err := config.DB.Where("status = ? AND scheduled_at <= ?", models.BroadcastStatusScheduled, time.Now()).
+		Order("scheduled_at ASC").
+		Find(&due).Error; err != nil {
+		fmt.Printf("❌ Failed to read scheduled broadcasts: %v\n", err)
+		return
+	}
+
+	for _, broadcast := range due {
+		s.send(broadcast)
+	}
+}
+
+func (s *BroadcastScheduler) send(broadcast models.Broadcast) {
+	// Claim the broadcast so a cancel or another instance can't race the send
+	claim := config.DB.Model(&models.Broadcast{}).
+		Where("id = ? AND status = ?", broadcast.ID, models.BroadcastStatusScheduled).
+		Update("status", models.BroadcastStatusSending)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+
+	fmt.Printf("📣 Sending broadcast %d: %s\n", broadcast.ID, broadcast.Title)
+
+	inApp, emails, err := s.deliver(broadcast)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"recipient_count": inApp,
+		"email_count":     emails,
+		"sent_at":         &now,
+		"status":          models.BroadcastStatusSent,
+	}
+	if err != nil {
+		updates["status"] = models.BroadcastStatusFailed
+		updates["error"] = err.Error()
+		fmt.Printf("❌ Broadcast %d failed: %v\n", broadcast.ID, err)
+	} else {
+		fmt.Printf("✅ Broadcast %d sent: %d in-app, %d emails\n", broadcast.ID, inApp, emails)
+	}
+
+	if err := config.DB.Model(&models.Broadcast{}).Where("id = ?", broadcast.ID).Updates(updates).Error; err != nil {
+		fmt.Printf("❌ Failed to update broadcast %d: %v\n", broadcast.ID, err)
+	}
+}
+
+// deliver creates the in-app notifications and queues the emails for every
+// user in the segment, in batches. Announcements are on for every role by
+// default, so the explicit opt-outs are exactly the users to leave out.
+func (s *BroadcastScheduler) deliver(broadcast models.Broadcast) (int, int, error) {
+	sendInApp := broadcast.HasChannel(models.NotificationChannelInApp)
+	sendEmail := broadcast.HasChannel(models.NotificationChannelEmail)
+
+	optedOutInApp := idSet(models.UsersOptedOut(models.NotificationAnnouncement, models.NotificationChannelInApp))
+	optedOutEmail := idSet(models.UsersOptedOut(models.NotificationAnnouncement, models.NotificationChannelEmail))
+
+	// Users reached by an interrupted earlier run
+	var deliveredIDs []uint
+	config.DB.Model(&models.BroadcastRecipient{}).Where("broadcast_id = ?", broadcast.ID).Pluck("user_id", &deliveredIDs)
+	delivered := idSet(deliveredIDs)
+
+	var users []models.User
+	result := broadcast.Segment.Users(config.DB).
+		Select("id", "email", "first_name", "locale").
+		FindInBatches(&users, s.batchSize, func(tx *gorm.DB, batch int) error {
+			var recipients []models.BroadcastRecipient
+			var notifications []models.Notification
+			var emailUsers []models.User
+			for _, user := range users {
+				if delivered[user.ID] {
+					continue
+				}
+
+				recipient := models.BroadcastRecipient{
+					BroadcastID: broadcast.ID,
+					UserID:      user.ID,
+					InApp:       sendInApp && !optedOutInApp[user.ID],
+					Email:       sendEmail && !optedOutEmail[user.ID] && user.Email != "",
+				}
+				if !recipient.InApp && !recipient.Email {
+					continue
+				}
+				recipients = append(recipients, recipient)
+
+				if recipient.InApp {
+					notifications = append(notifications, models.Notification{
+						UserID:      user.ID,
+						Title:       broadcast.Title,
+						Message:     broadcast.Message,
+						Type:        models.NotificationAnnouncement,
+						BroadcastID: &broadcast.ID,
+					})
+				}
+				if recipient.Email {
+					emailUsers = append(emailUsers, user)
+				}
+			}
+			if len(recipients) == 0 {
+				return nil
+			}
+
+			// Recipients are recorded before their emails are queued, so a
+			// crash mid-batch can miss an email but never sends one twice
+			err := config.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&recipients).Error; err != nil {
+					return err
+				}
+				if len(notifications) > 0 {
+					return tx.Create(&notifications).Error
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, notification := range notifications {
+				PublishNotification(notification)
+			}
+
+			for _, user := range emailUsers {
+				unsubscribeURL := UnsubscribeURL(user.ID, models.NotificationAnnouncement, models.NotificationChannelEmail)
+				if err := s.email.WithLocale(user.Locale).SendNotificationEmail(user.Email, user.FirstName, broadcast.Title, broadcast.Message, unsubscribeURL); err != nil {
+					fmt.Printf("⚠️ Failed to queue broadcast %d email to user %d: %v\n", broadcast.ID, user.ID, err)
+					config.DB.Model(&models.BroadcastRecipient{}).
+						Where("broadcast_id = ? AND user_id = ?", broadcast.ID, user.ID).
+						Update("email", false)
+				}
+			}
+			return nil
+		})
+
+	var inAppCount, emailCount int64
+	config.DB.Model(&models.BroadcastRecipient{}).Where("broadcast_id = ? AND in_app = ?", broadcast.ID, true).Count(&inAppCount)
+	config.DB.Model(&models.BroadcastRecipient{}).Where("broadcast_id = ? AND email = ?", broadcast.ID, true).Count(&emailCount)
+
+	return int(inAppCount), int(emailCount), result.Error
+}
+
+func idSet(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@yearly",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) accepted an invalid expression", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string // empty when the schedule never fires
+	}{
+		{"weekly on Monday", "0 7 * * 1", "2026-10-21 10:00", "2026-10-26 07:00"},
+		{"strictly after the current minute", "5 4 * * *", "2026-10-19 04:05", "2026-10-20 04:05"},
+		{"seconds are ignored", "5 4 * * *", "2026-10-19 04:04", "2026-10-19 04:05"},
+		{"every 15 minutes", "*/15 * * * *", "2026-10-19 10:07", "2026-10-19 10:15"},
+		{"step over a range", "0-30/10 9 * * *", "2026-10-19 09:25", "2026-10-19 09:30"},
+		{"step over a range wraps to the next day", "0-30/10 9 * * *", "2026-10-19 09:31", "2026-10-20 09:00"},
+		{"step from a start value runs to the end", "5/15 * * * *", "2026-10-19 10:21", "2026-10-19 10:35"},
+		{"lists", "0 8,17 * * *", "2026-10-19 09:00", "2026-10-19 17:00"},
+		{"hour rolls into the next day", "30 23 * * *", "2026-10-19 23:45", "2026-10-20 23:30"},
+		{"day of month or day of week, weekday first", "0 0 1,15 * 5", "2026-10-19 00:00", "2026-10-23 00:00"},
+		{"day of month or day of week, date first", "0 0 1,15 * 5", "2026-10-30 12:00", "2026-11-01 00:00"},
+		{"day of week only", "0 12 * * 3", "2026-10-19 00:00", "2026-10-21 12:00"},
+		{"7 is Sunday", "0 0 * * 7", "2026-10-19 00:00", "2026-10-25 00:00"},
+		{"0 is Sunday", "0 0 * * 0", "2026-10-19 00:00", "2026-10-25 00:00"},
+		{"weekday range", "0 9 * * 1-5", "2026-10-24 10:00", "2026-10-26 09:00"},
+		{"@hourly", "@hourly", "2026-10-19 10:07", "2026-10-19 11:00"},
+		{"@daily", "@daily", "2026-10-19 10:07", "2026-10-20 00:00"},
+		{"@weekly", "@weekly", "2026-10-19 10:07", "2026-10-25 00:00"},
+		{"@monthly", "@monthly", "2026-10-19 10:07", "2026-11-01 00:00"},
+		{"skips months without the day", "0 0 31 * *", "2026-11-01 00:00", "2026-12-31 00:00"},
+		{"month rolls into the next year", "0 9 * 12 *", "2026-12-31 10:00", "2027-12-01 09:00"},
+		{"leap day", "0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		{"never fires", "0 0 30 2 *", "2026-10-19 00:00", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			got := schedule.Next(at(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Fatalf("Next = %s, want the zero time", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Fatalf("Next(%s) = %s, want %s", tt.from, got.Format("2006-01-02 15:04 Mon"), want.Format("2006-01-02 15:04 Mon"))
+			}
+		})
+	}
+}
+
+func TestCronNextKeepsLocation(t *testing.T) {
+	kampala := time.FixedZone("EAT", 3*60*60)
+	schedule, err := ParseCron("0 7 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := schedule.Next(time.Date(2026, 10, 19, 8, 0, 0, 0, kampala))
+	if want := time.Date(2026, 10, 20, 7, 0, 0, 0, kampala); !got.Equal(want) || got.Location() != kampala {
+		t.Fatalf("Next = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"snapadeal/config"
+	"snapadeal/models"
+
+	"gorm.io/gorm"
+)
+
+// claimedDiscount matches discounts written into deal text, such as
+// "70% off", "40 % discount" or "save 25%"
+var claimedDiscount = regexp.MustCompile(`(?i)(\d{1,3}(?:\.\d+)?)\s*%\s*(?:off|discount)|\bsave\s+(\d{1,3}(?:\.\d+)?)\s*%`)
+
+// dealTextField is a piece of deal text the text-based checks look at
+type dealTextField struct {
+	name string
+	text string
+}
+
+func dealTextFields(deal *models.Deal) []dealTextField {
+	return []dealTextField{
+		{"title", deal.Title},
+		{"short_description", deal.ShortDescription},
+		{"description", deal.Description},
+		{"fine_prints", deal.FinePrints},
+	}
+}
+
+// CheckDeal runs the configured content checks against a deal and records
+// the findings on it. It doesn't save the deal.
+func CheckDeal(deal *models.Deal) models.DealFindings {
+	deal.CalculateDiscountPercent()
+
+	var rules []models.DealCheckRule
+	config.DB.Find(&rules)
+
+	findings := models.DealFindings{}
+	for _, rule := range rules {
+		if rule.Severity == models.CheckSeverityOff {
+			continue
+		}
+		findings = append(findings, runDealCheck(rule, deal)...)
+	}
+
+	now := time.Now()
+	deal.CheckFindings = findings
+	deal.CheckBlocked = findings.Blocked()
+	deal.CheckedAt = &now
+	return findings
+}
+
+func runDealCheck(rule models.DealCheckRule, deal *models.Deal) models.DealFindings {
+	finding := func(field, message string) models.DealFinding {
+		return models.DealFinding{Rule: rule.Key, Severity: rule.Severity, Field: field, Message: message}
+	}
+
+	switch rule.Key {
+	case models.CheckDiscountMismatch:
+		var findings models.DealFindings
+		for _, field := range dealTextFields(deal) {
+			for _, match := range claimedDiscount.FindAllStringSubmatch(field.text, -1) {
+				value := match[1]
+				if value == "" {
+					value = match[2]
+				}
+				claimed, _ := strconv.ParseFloat(value, 64)
+				if math.Abs(claimed-float64(deal.DiscountPercent)) > rule.Threshold {
+					findings = append(findings, finding(field.name, fmt.Sprintf(
+						"The %s says %q but the prices give a %d%% discount",
+						strings.ReplaceAll(field.name, "_", " "), match[0], deal.DiscountPercent)))
+					break
+				}
+			}
+		}
+		return findings
+
+	case models.CheckDiscountTooSteep:
+		if float64(deal.DiscountPercent) >= rule.Threshold {
+			return models.DealFindings{finding("discount_price", fmt.Sprintf(
+				"A %d%% discount is unusually steep, check the original price", deal.DiscountPercent))}
+		}
+
+	case models.CheckEndDateTooFar:
+		limit := time.Now().Add(time.Duration(rule.Threshold * float64(24*time.Hour)))
+		if deal.EndDate.After(limit) {
+			return models.DealFindings{finding("end_date", fmt.Sprintf(
+				"The deal runs until %s, more than %g days away", deal.EndDate.Format("2 Jan 2006"), rule.Threshold))}
+		}
+
+	case models.CheckEndDatePassed:
+		if !deal.EndDate.IsZero() && deal.EndDate.Before(time.Now()) {
+			return models.DealFindings{finding("end_date", "The end date has already passed")}
+		}
+
+	case models.CheckMissingImages:
+		if deal.ImageURL == "" && len(deal.Images) == 0 {
+			return models.DealFindings{finding("images", "The deal has no images")}
+		}
+
+	case models.CheckShortDescription:
+		if length := utf8.RuneCountInString(strings.TrimSpace(deal.Description)); float64(length) < rule.Threshold {
+			return models.DealFindings{finding("description", fmt.Sprintf(
+				"The description is only %d characters, at least %g are expected", length, rule.Threshold))}
+		}
+
+	case models.CheckBlockedTerms:
+		return checkBlockedTerms(rule, deal)
+	}
+
+	return nil
+}
+
+// checkBlockedTerms reports each blocked term once, on the first field it
+// appears in, with the term's own severity. Terms match whole words.
+func checkBlockedTerms(rule models.DealCheckRule, deal *models.Deal) models.DealFindings {
+	var terms []models.BlockedTerm
+	config.DB.Find(&terms)
+
+	var findings models.DealFindings
+	for _, term := range terms {
+		if term.Severity == models.CheckSeverityOff {
+			continue
+		}
+		pattern, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(term.Term) + `\b`)
+		if err != nil {
+			continue
+		}
+		for _, field := range dealTextFields(deal) {
+			if pattern.MatchString(field.text) {
+				findings = append(findings, models.DealFinding{
+					Rule:     rule.Key,
+					Severity: term.Severity,
+					Field:    field.name,
+					Message:  fmt.Sprintf("The %s contains the blocked term %q", strings.ReplaceAll(field.name, "_", " "), term.Term),
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// RecheckPendingDeals runs the checks again on every pending deal, for
+// after the rules or blocked terms change. It returns how many deals were
+// checked and how many are now blocked.
+func RecheckPendingDeals() (checked, blocked int, err error) {
+	var deals []models.Deal
+	err = config.DB.Where("status = ?", "pending").FindInBatches(&deals, 200, func(tx *gorm.DB, batch int) error {
+		for i := range deals {
+			deal := &deals[i]
+			CheckDeal(deal)
+			if err := config.DB.Model(deal).
+				Select("discount_percent", "check_findings", "check_blocked", "checked_at").
+				Updates(deal).Error; err != nil {
+				return err
+			}
+			checked++
+			if deal.CheckBlocked {
+				blocked++
+			}
+		}
+		return nil
+	}).Error
+	return checked, blocked, err
+}
@@ -1,10 +1,15 @@
 package services
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"mime"
+	"mime/quotedprintable"
 	"net/smtp"
 	"os"
 	"strings"
+	"time"
 )
 
 type EmailService struct {
@@ -14,6 +19,7 @@ type EmailService struct {
 	Password string
 	From     string
 	FromName string
+	Locale   string // template locale, see EmailLocales
 }
 
 func NewEmailService() *EmailService {
@@ -24,194 +30,239 @@ func NewEmailService() *EmailService {
 		Password: os.Getenv("SMTP_PASS"),
 		From:     os.Getenv("SMTP_USER"),
 		FromName: os.Getenv("SMTP_FROM_NAME"),
+		Locale:   DefaultEmailLocale,
 	}
 }
 
+// WithLocale returns a copy of the service that renders templates in the
+// recipient's locale
+func (e *EmailService) WithLocale(locale string) *EmailService {
+	localised := *e
+	localised.Locale = locale
+	return &localised
+}
+
+// EmailAttachment is a file sent with an email
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// EmailMessage is a fully rendered email ready to send
+type EmailMessage struct {
+	To          string
+	Subject     string
+	Template    string // template name, for the outbox log
+	HTML        string
+	Text        string   // plain-text alternative, optional
+	Headers     []string // extra headers, e.g. List-Unsubscribe
+	Attachments []EmailAttachment
+}
+
+// SendEmail sends a ready-made HTML body
 func (e *EmailService) SendEmail(to, subject, body string) error {
-	if e.Host == "" || e.Username == "" || e.Password == "" {
+	return e.Send(EmailMessage{To: to, Subject: subject, HTML: body})
+}
+
+// SendTemplate renders a template in the service's locale and sends it.
+// When unsubscribeURL is set the footer links to it and List-Unsubscribe
+// headers are added for one-click unsubscribe (RFC 8058).
+func (e *EmailService) SendTemplate(to, name string, data EmailData, unsubscribeURL string, attachments ...EmailAttachment) error {
+	if unsubscribeURL != "" {
+		data["UnsubscribeURL"] = unsubscribeURL
+	}
+
+	rendered, err := RenderEmail(name, e.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	message := EmailMessage{
+		To:          to,
+		Subject:     rendered.Subject,
+		Template:    name,
+		HTML:        rendered.HTML,
+		Text:        rendered.Text,
+		Attachments: attachments,
+	}
+	if unsubscribeURL != "" {
+		message.Headers = []string{
+			fmt.Sprintf("List-Unsubscribe: <%s>", unsubscribeURL),
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		}
+	}
+
+	return e.Send(message)
+}
+
+// Send queues the message in the outbox, or delivers it directly when the
+// outbox isn't running (e.g. from CLI commands)
+func (e *EmailService) Send(message EmailMessage) error {
+	if e.Host == "" {
 		fmt.Println("⚠️ Email service not configured - skipping email send")
 		return nil // Don't fail if email is not configured
 	}
 
-	auth := smtp.PlainAuth("", e.Username, e.Password, e.Host)
-
-	fromName := e.FromName
-	if fromName == "" {
-		fromName = "Snapadeal Uganda"
+	if Outbox != nil {
+		_, err := Outbox.Enqueue(message)
+		return err
 	}
+	return e.deliver(message)
+}
 
-	msg := []string{
-		fmt.Sprintf("From: %s <%s>", fromName, e.From),
-		fmt.Sprintf("To: %s", to),
-		fmt.Sprintf("Subject: %s", subject),
-		"MIME-Version: 1.0",
-		"Content-Type: text/html; charset=UTF-8",
-		"",
-		body,
+// deliver sends a message over SMTP. Without SMTP_USER no authentication is
+// attempted, which suits local SMTP stand-ins such as MailHog.
+func (e *EmailService) deliver(message EmailMessage) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
 	}
 
 	return smtp.SendMail(
 		e.Host+":"+e.Port,
 		auth,
-		e.From,
-		[]string{to},
-		[]byte(strings.Join(msg, "\r\n")),
+		e.fromAddress(),
+		[]string{message.To},
+		e.buildMessage(message),
 	)
 }
 
+func (e *EmailService) fromAddress() string {
+	if e.From == "" {
+		return "no-reply@snapadeal.ug"
+	}
+	return e.From
+}
+
+// buildMessage assembles the MIME message: the HTML body, with the plain-text
+// alternative as multipart/alternative and attachments as multipart/mixed
+func (e *EmailService) buildMessage(message EmailMessage) []byte {
+	fromName := e.FromName
+	if fromName == "" {
+		fromName = "Snapadeal Uganda"
+	}
+
+	var buf bytes.Buffer
+	writeLine := func(line string) { buf.WriteString(line + "\r\n") }
+
+	writeLine(fmt.Sprintf("From: %s <%s>", mime.QEncoding.Encode("UTF-8", fromName), e.fromAddress()))
+	writeLine(fmt.Sprintf("To: %s", message.To))
+	writeLine(fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("UTF-8", message.Subject)))
+	writeLine(fmt.Sprintf("Date: %s", time.Now().Format(time.RFC1123Z)))
+	for _, header := range message.Headers {
+		writeLine(header)
+	}
+	writeLine("MIME-Version: 1.0")
+
+	boundary := fmt.Sprintf("snapadeal-%d", time.Now().UnixNano())
+
+	writeBody := func() {
+		if message.Text == "" {
+			writeTextPart(&buf, "text/html", message.HTML)
+			return
+		}
+		writeLine(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q", boundary+"-alt"))
+		writeLine("")
+		writeLine("--" + boundary + "-alt")
+		writeTextPart(&buf, "text/plain", message.Text)
+		writeLine("--" + boundary + "-alt")
+		writeTextPart(&buf, "text/html", message.HTML)
+		writeLine("--" + boundary + "-alt--")
+	}
+
+	if len(message.Attachments) == 0 {
+		writeBody()
+		return buf.Bytes()
+	}
+
+	writeLine(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q", boundary))
+	writeLine("")
+	writeLine("--" + boundary)
+	writeBody()
+	for _, attachment := range message.Attachments {
+		writeLine("--" + boundary)
+		writeLine(fmt.Sprintf("Content-Type: %s; name=%q", attachment.ContentType, attachment.Filename))
+		writeLine("Content-Transfer-Encoding: base64")
+		writeLine(fmt.Sprintf("Content-Disposition: attachment; filename=%q", attachment.Filename))
+		writeLine("")
+		writeLine(wrapBase64(attachment.Data))
+	}
+	writeLine("--" + boundary + "--")
+
+	return buf.Bytes()
+}
+
+func writeTextPart(buf *bytes.Buffer, contentType, body string) {
+	buf.WriteString(fmt.Sprintf("Content-Type: %s; charset=UTF-8\r\n", contentType))
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	writer := quotedprintable.NewWriter(buf)
+	writer.Write([]byte(body))
+	writer.Close()
+	buf.WriteString("\r\n")
+}
+
+// wrapBase64 encodes data in 76-character lines as MIME requires
+func wrapBase64(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var lines []string
+	for len(encoded) > 76 {
+		lines = append(lines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	lines = append(lines, encoded)
+	return strings.Join(lines, "\r\n")
+}
+
 func (e *EmailService) SendOTPEmail(to, name, otp string) error {
-	subject := "Verify Your Snapadeal Account"
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: linear-gradient(135deg, #00b894 0%%, #0984e3 100%%); color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
-        .content { background: #f8f9fa; padding: 30px; border-radius: 0 0 10px 10px; }
-        .otp-code { background: #fff; border: 2px solid #00b894; padding: 20px; text-align: center; font-size: 32px; font-weight: bold; color: #00b894; margin: 20px 0; border-radius: 8px; letter-spacing: 5px; }
-        .footer { text-align: center; margin-top: 20px; color: #666; font-size: 12px; }
-        .btn { background: #00b894; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; display: inline-block; margin: 10px 0; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>🎉 Welcome to Snapadeal!</h1>
-            <p>Verify your account to start saving</p>
-        </div>
-        <div class="content">
-            <h2>Hi %s,</h2>
-            <p>Thank you for joining Snapadeal Uganda! To complete your registration, please verify your email address using the OTP code below:</p>
-            
-            <div class="otp-code">%s</div>
-            
-            <p><strong>This code will expire in 10 minutes.</strong></p>
-            
-            <p>If you didn't create an account with Snapadeal, please ignore this email.</p>
-            
-            <p>Best regards,<br>The Snapadeal Team</p>
-        </div>
-        <div class="footer">
-            <p>© 2025 Snapadeal Uganda. All rights reserved.</p>
-            <p>This is an automated email. Please do not reply.</p>
-        </div>
-    </div>
-</body>
-</html>`, name, otp)
-
-	return e.SendEmail(to, subject, body)
+	return e.SendTemplate(to, "otp", EmailData{"Name": name, "OTP": otp}, "")
 }
 
 func (e *EmailService) SendPasswordResetEmail(to, name, resetURL string) error {
-	subject := "Reset Your Snapadeal Password"
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: linear-gradient(135deg, #00b894 0%%, #0984e3 100%%); color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
-        .content { background: #f8f9fa; padding: 30px; border-radius: 0 0 10px 10px; }
-        .btn { background: #00b894; color: white; padding: 15px 30px; text-decoration: none; border-radius: 6px; display: inline-block; margin: 20px 0; font-weight: bold; }
-        .footer { text-align: center; margin-top: 20px; color: #666; font-size: 12px; }
-        .warning { background: #fff3cd; border: 1px solid #ffeaa7; padding: 15px; border-radius: 6px; margin: 20px 0; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>🔐 Password Reset Request</h1>
-            <p>Snapadeal Account Recovery</p>
-        </div>
-        <div class="content">
-            <h2>Hi %s,</h2>
-            <p>We received a request to reset your Snapadeal account password. Click the button below to create a new password:</p>
-            
-            <div style="text-align: center;">
-                <a href="%s" class="btn">Reset My Password</a>
-            </div>
-            
-            <div class="warning">
-                <strong>⚠️ Security Notice:</strong>
-                <ul>
-                    <li>This link will expire in 1 hour</li>
-                    <li>If you didn't request this reset, please ignore this email</li>
-                    <li>Never share this link with anyone</li>
-                </ul>
-            </div>
-            
-            <p>If the button doesn't work, copy and paste this link into your browser:</p>
-            <p style="word-break: break-all; color: #666; font-size: 12px;">%s</p>
-            
-            <p>Best regards,<br>The Snapadeal Team</p>
-        </div>
-        <div class="footer">
-            <p>© 2025 Snapadeal Uganda. All rights reserved.</p>
-            <p>This is an automated email. Please do not reply.</p>
-        </div>
-    </div>
-</body>
-</html>`, name, resetURL, resetURL)
-
-	return e.SendEmail(to, subject, body)
+	return e.SendTemplate(to, "password_reset", EmailData{"Name": name, "ResetURL": resetURL}, "")
 }
 
 func (e *EmailService) SendWelcomeEmail(to, name, role string) error {
-	subject := "Welcome to Snapadeal Uganda! 🎉"
-	
-	roleMessage := "start discovering amazing deals"
-	if role == "merchant" {
-		roleMessage = "start creating and managing your deals"
-	} else if role == "admin" {
-		roleMessage = "manage the Snapadeal platform"
-	}
-
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: linear-gradient(135deg, #00b894 0%%, #0984e3 100%%); color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
-        .content { background: #f8f9fa; padding: 30px; border-radius: 0 0 10px 10px; }
-        .feature { background: white; padding: 15px; margin: 10px 0; border-radius: 6px; border-left: 4px solid #00b894; }
-        .footer { text-align: center; margin-top: 20px; color: #666; font-size: 12px; }
-        .btn { background: #00b894; color: white; padding: 15px 30px; text-decoration: none; border-radius: 6px; display: inline-block; margin: 20px 0; font-weight: bold; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>🎉 Welcome to Snapadeal!</h1>
-            <p>Your account has been verified successfully</p>
-        </div>
-        <div class="content">
-            <h2>Hi %s,</h2>
-            <p>Congratulations! Your Snapadeal account has been verified and you can now %s.</p>
-            
-            <div class="feature">
-                <h3>🛍️ What's Next?</h3>
-                <p>Explore thousands of amazing deals from local businesses across Uganda and save up to 90%% on your favorite activities!</p>
-            </div>
-            
-            <div style="text-align: center;">
-                <a href="http://localhost:3000" class="btn">Start Exploring Deals</a>
-            </div>
-            
-            <p>If you have any questions, feel free to contact our support team.</p>
-            
-            <p>Happy saving!<br>The Snapadeal Team</p>
-        </div>
-        <div class="footer">
-            <p>© 2025 Snapadeal Uganda. All rights reserved.</p>
-        </div>
-    </div>
-</body>
-</html>`, name, roleMessage)
-
-	return e.SendEmail(to, subject, body)
+	appURL := os.Getenv("CUSTOMER_APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+
+	return e.SendTemplate(to, "welcome", EmailData{"Name": name, "Role": role, "AppURL": appURL}, "")
+}
+
+func (e *EmailService) SendAdminInvitationEmail(to, inviterName, inviteURL string, expiresAt time.Time) error {
+	return e.SendTemplate(to, "admin_invitation", EmailData{
+		"InviterName": inviterName,
+		"InviteURL":   inviteURL,
+		"ExpiresAt":   expiresAt,
+	}, "")
+}
+
+func (e *EmailService) SendMerchantApplicationEmail(to, name, businessName, status, notes string) error {
+	return e.SendTemplate(to, "merchant_application", EmailData{
+		"Name":         name,
+		"BusinessName": businessName,
+		"Status":       status,
+		"Notes":        notes,
+	}, "")
+}
+
+func (e *EmailService) SendMerchantStaffInvitationEmail(to, businessName, role, inviteURL string) error {
+	return e.SendTemplate(to, "merchant_staff_invitation", EmailData{
+		"BusinessName": businessName,
+		"Role":         role,
+		"InviteURL":    inviteURL,
+	}, "")
+}
+
+// SendNotificationEmail is the generic email for notification events that
+// have no dedicated template
+func (e *EmailService) SendNotificationEmail(to, name, title, message, unsubscribeURL string) error {
+	return e.SendTemplate(to, "notification", EmailData{
+		"Name":    name,
+		"Title":   title,
+		"Message": message,
+	}, unsubscribeURL)
 }
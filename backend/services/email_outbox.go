@@ -0,0 +1,276 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+// EmailOutbox is the database-backed queue of outbound email. EmailService.Send
+// enqueues here and a background worker delivers, so handlers never block on
+// SMTP and a failed send is retried with exponential backoff instead of lost.
+type EmailOutbox struct {
+	email        *EmailService
+	wake         chan struct{}
+	batchSize    int
+	pollInterval time.Duration
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	maxAttempts  int
+	retention    time.Duration // how long sent emails are kept
+}
+
+// Outbox is the shared outbox, created and started from main once the
+// environment is loaded. While nil, emails are sent directly.
+var Outbox *EmailOutbox
+
+func NewEmailOutbox(email *EmailService) *EmailOutbox {
+	return &EmailOutbox{
+		email:        email,
+		wake:         make(chan struct{}, 1),
+		batchSize:    20,
+		pollInterval: 5 * time.Second,
+		baseBackoff:  30 * time.Second,
+		maxBackoff:   time.Hour,
+		maxAttempts:  5,
+		retention:    emailRetention(),
+	}
+}
+
+// emailRetention comes from EMAIL_RETENTION (a duration such as "720h"),
+// defaulting to 30 days
+func emailRetention() time.Duration {
+	retention := 30 * 24 * time.Hour
+	if value := os.Getenv("EMAIL_RETENTION"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			retention = parsed
+		} else {
+			fmt.Printf("⚠️ Invalid EMAIL_RETENTION %q, using %s\n", value, retention)
+		}
+	}
+	return retention
+}
+
+// Enqueue stores the message for delivery and wakes the worker
+func (o *EmailOutbox) Enqueue(message EmailMessage) (*models.OutboundEmail, error) {
+	attachments := make(models.OutboundEmailAttachments, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		attachments = append(attachments, models.OutboundEmailAttachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Data:        attachment.Data,
+		})
+	}
+
+	email := models.OutboundEmail{
+		To:            message.To,
+		Subject:       message.Subject,
+		Template:      message.Template,
+		HTML:          message.HTML,
+		Text:          message.Text,
+		Headers:       models.StringArray(message.Headers),
+		Attachments:   attachments,
+		Status:        models.EmailStatusQueued,
+		MaxAttempts:   o.maxAttempts,
+		NextAttemptAt: time.Now(),
+	}
+	if err := config.DB.Create(&email).Error; err != nil {
+		return nil, err
+	}
+
+	o.notify()
+	return &email, nil
+}
+
+// Retry puts a failed email back in the queue with a fresh set of attempts.
+// Earlier attempts stay in its delivery log.
+func (o *EmailOutbox) Retry(email *models.OutboundEmail) error {
+	email.Status = models.EmailStatusQueued
+	email.MaxAttempts = email.Attempts + o.maxAttempts
+	email.NextAttemptAt = time.Now()
+
+	if err := config.DB.Model(email).Updates(map[string]interface{}{
+		"status":          email.Status,
+		"max_attempts":    email.MaxAttempts,
+		"next_attempt_at": email.NextAttemptAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	o.notify()
+	return nil
+}
+
+// Start launches the delivery worker
+func (o *EmailOutbox) Start() {
+	// Emails claimed by a worker that died mid-send go back in the queue
+	config.DB.Model(&models.OutboundEmail{}).
+		Where("status = ?", models.EmailStatusSending).
+		Update("status", models.EmailStatusQueued)
+
+	// Sent before bodies were cleared on delivery
+	config.DB.Model(&models.OutboundEmail{}).
+		Where("status = ? AND (html <> '' OR text <> '' OR attachments <> '[]')", models.EmailStatusSent).
+		Updates(clearedEmailBody())
+
+	go func() {
+		ticker := time.NewTicker(o.pollInterval)
+		defer ticker.Stop()
+
+		var lastPurge time.Time
+		for {
+			o.processDue()
+
+			if time.Since(lastPurge) > time.Hour {
+				o.purgeSent()
+				lastPurge = time.Now()
+			}
+
+			select {
+			case <-ticker.C:
+			case <-o.wake:
+			}
+		}
+	}()
+}
+
+// purgeSent deletes sent emails and their delivery logs once they are older
+// than the retention period
+func (o *EmailOutbox) purgeSent() {
+	cutoff := time.Now().Add(-o.retention)
+	expired := config.DB.Model(&models.OutboundEmail{}).Select("id").
+		Where("status = ? AND sent_at < ?", models.EmailStatusSent, cutoff)
+
+	if err := config.DB.Where("email_id IN (?)", expired).Delete(&models.EmailDeliveryAttempt{}).Error; err != nil {
+		fmt.Printf("❌ Failed to purge email delivery logs: %v\n", err)
+		return
+	}
+	result := config.DB.Where("status = ? AND sent_at < ?", models.EmailStatusSent, cutoff).Delete(&models.OutboundEmail{})
+	if result.Error != nil {
+		fmt.Printf("❌ Failed to purge sent emails: %v\n", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		fmt.Printf("🧹 Purged %d sent emails older than %s\n", result.RowsAffected, o.retention)
+	}
+}
+
+// clearedEmailBody drops what a sent email no longer needs: bodies and
+// attachments can hold codes, links and vouchers
+func clearedEmailBody() map[string]interface{} {
+	return map[string]interface{}{
+		"html":        "",
+		"text":        "",
+		"attachments": models.OutboundEmailAttachments{},
+	}
+}
+
+func (o *EmailOutbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *EmailOutbox) processDue() {
+	for {
+		var emails []models.OutboundEmail
+		if err := config.DB.Where("status = ? AND next_attempt_at <= ?", models.EmailStatusQueued, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(o.batchSize).
+			Find(&emails).Error; err != nil {
+			fmt.Printf("❌ Failed to read email outbox: %v\n", err)
+			return
+		}
+
+		for _, email := range emails {
+			o.deliver(email)
+		}
+
+		if len(emails) < o.batchSize {
+			return
+		}
+	}
+}
+
+func (o *EmailOutbox) deliver(email models.OutboundEmail) {
+	// Claim the row so another worker or instance doesn't send it too
+	claim := config.DB.Model(&models.OutboundEmail{}).
+		Where("id = ? AND status = ?", email.ID, models.EmailStatusQueued).
+		Update("status", models.EmailStatusSending)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+
+	attempt := email.Attempts + 1
+	err := o.email.deliver(outboundMessage(email))
+
+	logEntry := models.EmailDeliveryAttempt{EmailID: email.ID, Attempt: attempt}
+	updates := map[string]interface{}{"attempts": attempt}
+
+	if err == nil {
+		now := time.Now()
+		logEntry.Status = models.EmailStatusSent
+		updates["status"] = models.EmailStatusSent
+		updates["sent_at"] = &now
+		updates["last_error"] = ""
+		for column, value := range clearedEmailBody() {
+			updates[column] = value
+		}
+		fmt.Printf("📧 Email %d sent to %s\n", email.ID, email.To)
+	} else {
+		logEntry.Status = models.EmailStatusFailed
+		logEntry.Error = err.Error()
+		updates["last_error"] = err.Error()
+
+		if attempt >= email.MaxAttempts {
+			updates["status"] = models.EmailStatusFailed
+			fmt.Printf("❌ Email %d to %s failed permanently after %d attempts: %v\n", email.ID, email.To, attempt, err)
+		} else {
+			updates["status"] = models.EmailStatusQueued
+			updates["next_attempt_at"] = time.Now().Add(o.backoff(attempt))
+			fmt.Printf("⚠️ Email %d to %s failed (attempt %d/%d), retrying: %v\n", email.ID, email.To, attempt, email.MaxAttempts, err)
+		}
+	}
+
+	config.DB.Create(&logEntry)
+	if err := config.DB.Model(&models.OutboundEmail{}).Where("id = ?", email.ID).Updates(updates).Error; err != nil {
+		fmt.Printf("❌ Failed to update email %d: %v\n", email.ID, err)
+	}
+}
+
+// backoff doubles the wait after each failed attempt, up to maxBackoff
+func (o *EmailOutbox) backoff(attempt int) time.Duration {
+	wait := o.baseBackoff
+	for i := 1; i < attempt && wait < o.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > o.maxBackoff {
+		wait = o.maxBackoff
+	}
+	return wait
+}
+
+func outboundMessage(email models.OutboundEmail) EmailMessage {
+	attachments := make([]EmailAttachment, 0, len(email.Attachments))
+	for _, attachment := range email.Attachments {
+		attachments = append(attachments, EmailAttachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Data:        attachment.Data,
+		})
+	}
+
+	return EmailMessage{
+		To:          email.To,
+		Subject:     email.Subject,
+		Template:    email.Template,
+		HTML:        email.HTML,
+		Text:        email.Text,
+		Headers:     email.Headers,
+		Attachments: attachments,
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+// useTestDB points config.DB at a fresh SQLite database holding the given
+// models for the length of the test
+func useTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// smtpStandIn is a minimal in-process SMTP server. It turns away the first
+// rejectFirst messages with a temporary failure and keeps the rest.
+type smtpStandIn struct {
+	listener    net.Listener
+	mu          sync.Mutex
+	rejectFirst int
+	received    []string
+}
+
+func newSMTPStandIn(t *testing.T, rejectFirst int) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &smtpStandIn{listener: listener, rejectFirst: rejectFirst}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 stand-in ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 stand-in")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+
+			s.mu.Lock()
+			if s.rejectFirst > 0 {
+				s.rejectFirst--
+				s.mu.Unlock()
+				reply("451 try again later")
+				continue
+			}
+			s.received = append(s.received, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default: // MAIL, RCPT, RSET, NOOP
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpStandIn) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+func (s *smtpStandIn) EmailService() *EmailService {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return &EmailService{Host: host, Port: port, FromName: "Snapadeal Uganda", Locale: DefaultEmailLocale}
+}
+
+func testOutbox(email *EmailService, maxAttempts int) *EmailOutbox {
+	outbox := NewEmailOutbox(email)
+	outbox.baseBackoff = time.Minute
+	outbox.maxBackoff = 10 * time.Minute
+	outbox.maxAttempts = maxAttempts
+	return outbox
+}
+
+func loadEmail(t *testing.T, id uint) models.OutboundEmail {
+	t.Helper()
+	var email models.OutboundEmail
+	if err := config.DB.Preload("DeliveryLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempt ASC")
+	}).First(&email, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return email
+}
+
+// makeDue pulls the next attempt forward instead of waiting out the backoff
+func makeDue(t *testing.T, id uint) {
+	t.Helper()
+	if err := config.DB.Model(&models.OutboundEmail{}).Where("id = ?", id).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deliveryStatuses(email models.OutboundEmail) []string {
+	var statuses []string
+	for _, entry := range email.DeliveryLog {
+		statuses = append(statuses, entry.Status)
+	}
+	return statuses
+}
+
+func TestOutboxRetriesWithBackoffUntilSent(t *testing.T) {
+	useTestDB(t, &models.OutboundEmail{}, &models.EmailDeliveryAttempt{})
+	server := newSMTPStandIn(t, 2)
+	outbox := testOutbox(server.EmailService(), 5)
+
+	queued, err := outbox.Enqueue(EmailMessage{To: "customer@example.com", Subject: "Your receipt", HTML: "<p>Voucher SNAP-1234</p>", Text: "Voucher SNAP-1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First attempt is turned away and waits the base backoff
+	outbox.processDue()
+	email := loadEmail(t, queued.ID)
+	if email.Status != models.EmailStatusQueued || email.Attempts != 1 || email.LastError == "" {
+		t.Fatalf("after attempt 1: status %s, attempts %d, last error %q", email.Status, email.Attempts, email.LastError)
+	}
+	if wait := time.Until(email.NextAttemptAt); wait < 50*time.Second || wait > time.Minute {
+		t.Fatalf("after attempt 1: next attempt in %s, want about 1m", wait)
+	}
+
+	// Nothing is due yet
+	outbox.processDue()
+	if email = loadEmail(t, queued.ID); email.Attempts != 1 {
+		t.Fatalf("email retried before its backoff: %d attempts", email.Attempts)
+	}
+
+	// Second failure doubles the wait
+	makeDue(t, queued.ID)
+	outbox.processDue()
+	email = loadEmail(t, queued.ID)
+	if wait := time.Until(email.NextAttemptAt); email.Attempts != 2 || wait < 110*time.Second || wait > 2*time.Minute {
+		t.Fatalf("after attempt 2: %d attempts, next attempt in %s, want 2 and about 2m", email.Attempts, wait)
+	}
+
+	// Third attempt is accepted
+	makeDue(t, queued.ID)
+	outbox.processDue()
+	email = loadEmail(t, queued.ID)
+	if email.Status != models.EmailStatusSent || email.Attempts != 3 || email.SentAt == nil || email.LastError != "" {
+		t.Fatalf("after attempt 3: status %s, attempts %d, sent at %v, last error %q", email.Status, email.Attempts, email.SentAt, email.LastError)
+	}
+	if email.HTML != "" || email.Text != "" {
+		t.Fatal("sent email kept its body")
+	}
+	if got := strings.Join(deliveryStatuses(email), ","); got != "failed,failed,sent" {
+		t.Fatalf("delivery log = %s, want failed,failed,sent", got)
+	}
+	if email.DeliveryLog[0].Error == "" {
+		t.Fatal("failed attempt logged without its error")
+	}
+
+	received := server.Received()
+	if len(received) != 1 || !strings.Contains(received[0], "Subject: Your receipt") || !strings.Contains(received[0], "customer@example.com") {
+		t.Fatalf("stand-in received %q", received)
+	}
+}
+
+func TestOutboxGivesUpAfterMaxAttempts(t *testing.T) {
+	useTestDB(t, &models.OutboundEmail{}, &models.EmailDeliveryAttempt{})
+	server := newSMTPStandIn(t, 100)
+	outbox := testOutbox(server.EmailService(), 2)
+
+	queued, err := outbox.Enqueue(EmailMessage{To: "merchant@example.com", Subject: "Weekly sales", Text: "report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outbox.processDue()
+	makeDue(t, queued.ID)
+	outbox.processDue()
+
+	email := loadEmail(t, queued.ID)
+	if email.Status != models.EmailStatusFailed || email.Attempts != 2 {
+		t.Fatalf("status %s after %d attempts, want failed after 2", email.Status, email.Attempts)
+	}
+	if got := strings.Join(deliveryStatuses(email), ","); got != "failed,failed" {
+		t.Fatalf("delivery log = %s, want failed,failed", got)
+	}
+
+	// A failed email is never picked up again on its own
+	makeDue(t, queued.ID)
+	outbox.processDue()
+	if email = loadEmail(t, queued.ID); email.Attempts != 2 {
+		t.Fatalf("failed email was retried: %d attempts", email.Attempts)
+	}
+
+	// An admin retry gives it a fresh set of attempts
+	server.mu.Lock()
+	server.rejectFirst = 0
+	server.mu.Unlock()
+	if err := outbox.Retry(&email); err != nil {
+		t.Fatal(err)
+	}
+	outbox.processDue()
+	email = loadEmail(t, queued.ID)
+	if email.Status != models.EmailStatusSent || email.Attempts != 3 {
+		t.Fatalf("after retry: status %s, attempts %d, want sent after 3", email.Status, email.Attempts)
+	}
+	if got := strings.Join(deliveryStatuses(email), ","); got != "failed,failed,sent" {
+		t.Fatalf("delivery log = %s, want failed,failed,sent", got)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	outbox := testOutbox(nil, 5)
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{12, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := outbox.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"snapadeal/models"
+)
+
+// Email templates live in templates/email: a shared layout.html/layout.txt
+// plus, per locale directory, a footer and one <name>.html/<name>.txt pair
+// per email. The .txt file also defines the subject. A locale without its own
+// copy of a template falls back to English as a whole.
+//
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+const DefaultEmailLocale = "en"
+
+// EmailLocales are the locales with template directories
+var EmailLocales = []string{"en", "sw"}
+
+// EmailData is the template data. UnsubscribeURL and Locale are filled in by
+// the renderer for the layout.
+type EmailData map[string]interface{}
+
+// RenderedEmail is a template rendered for one recipient
+type RenderedEmail struct {
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+type emailTemplateSet struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var (
+	emailTemplateCache   = make(map[string]*emailTemplateSet)
+	emailTemplateCacheMu sync.Mutex
+)
+
+var emailTemplateFuncs = map[string]interface{}{
+	"ugx": FormatUGX,
+	"datetime": func(t time.Time) string {
+		return t.Format("2 Jan 2006 15:04 MST")
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2 Jan 2006")
+	},
+	"percent": func(ratio float64) string {
+		return fmt.Sprintf("%.1f%%", ratio*100)
+	},
+	"lines": func(s string) []string {
+		return strings.Split(strings.TrimSpace(s), "\n")
+	},
+}
+
+// EmailTemplateNames lists the available templates
+func EmailTemplateNames() []string {
+	entries, _ := fs.ReadDir(emailTemplateFS, "templates/email/"+DefaultEmailLocale)
+
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".html")
+		if name != entry.Name() && name != "footer" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// IsEmailLocale reports whether there are templates for the locale
+func IsEmailLocale(locale string) bool {
+	for _, l := range EmailLocales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderEmail renders the named template in the locale, falling back to English
+func RenderEmail(name, locale string, data EmailData) (*RenderedEmail, error) {
+	locale = resolveEmailLocale(name, locale)
+
+	set, err := loadEmailTemplate(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	values := EmailData{"Locale": locale, "UnsubscribeURL": ""}
+	for key, value := range data {
+		values[key] = value
+	}
+
+	var subject, text, body bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return nil, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := set.text.ExecuteTemplate(&text, "layout.txt", values); err != nil {
+		return nil, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := set.html.ExecuteTemplate(&body, "layout.html", values); err != nil {
+		return nil, fmt.Errorf("render %s html: %w", name, err)
+	}
+
+	return &RenderedEmail{
+		Locale:  locale,
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    body.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+func resolveEmailLocale(name, locale string) string {
+	if locale == "" || !IsEmailLocale(locale) {
+		return DefaultEmailLocale
+	}
+	if _, err := fs.Stat(emailTemplateFS, fmt.Sprintf("templates/email/%s/%s.html", locale, name)); err != nil {
+		return DefaultEmailLocale
+	}
+	return locale
+}
+
+func loadEmailTemplate(name, locale string) (*emailTemplateSet, error) {
+	key := locale + "/" + name
+
+	emailTemplateCacheMu.Lock()
+	defer emailTemplateCacheMu.Unlock()
+
+	if set, ok := emailTemplateCache[key]; ok {
+		return set, nil
+	}
+
+	dir := "templates/email/" + locale
+	html, err := htmltemplate.New("layout.html").Funcs(emailTemplateFuncs).ParseFS(emailTemplateFS,
+		"templates/email/layout.html", dir+"/footer.html", dir+"/"+name+".html")
+	if err != nil {
+		return nil, fmt.Errorf("email template %s: %w", key, err)
+	}
+	text, err := texttemplate.New("layout.txt").Funcs(emailTemplateFuncs).ParseFS(emailTemplateFS,
+		"templates/email/layout.txt", dir+"/footer.txt", dir+"/"+name+".txt")
+	if err != nil {
+		return nil, fmt.Errorf("email template %s: %w", key, err)
+	}
+
+	set := &emailTemplateSet{html: html, text: text}
+	emailTemplateCache[key] = set
+	return set, nil
+}
+
+// EmailTemplateSample returns example data for previewing a template
+func EmailTemplateSample(name string) (EmailData, bool) {
+	expiresAt := time.Now().Add(72 * time.Hour)
+	salesReport, _ := models.GetReportDefinition(models.ReportMerchantSales)
+
+	samples := map[string]EmailData{
+		"otp":            {"Name": "Sarah", "OTP": "482913"},
+		"password_reset": {"Name": "Sarah", "ResetURL": "http://localhost:3000/reset-password?token=sample-token"},
+		"welcome":        {"Name": "Sarah", "Role": "customer", "AppURL": "http://localhost:3000"},
+		"admin_invitation": {
+			"InviterName": "Daniel Oyoka",
+			"InviteURL":   "http://localhost:3002/accept-invitation?token=sample-token",
+			"ExpiresAt":   expiresAt,
+		},
+		"merchant_application": {
+			"Name":         "Sarah",
+			"BusinessName": "Kampala Coffee House",
+			"Status":       "more_info_required",
+			"Notes":        "Please upload a clearer copy of your trading licence.",
+		},
+		"merchant_staff_invitation": {
+			"BusinessName": "Kampala Coffee House",
+			"Role":         "cashier",
+			"InviteURL":    "http://localhost:3001/invitations/accept?token=sample-token",
+		},
+		"notification": {
+			"Name":           "Sarah",
+			"Title":          "Deal Approved! 🎉",
+			"Message":        "Your deal 'Two coffees for the price of one' has been approved and is now live!",
+			"UnsubscribeURL": "http://localhost:8080/api/v1/notifications/unsubscribe?token=sample",
+		},
+		"receipt": {
+			"Name":             "Sarah",
+			"ReceiptNumber":    "SNAP-R000123",
+			"DealTitle":        "Two coffees for the price of one",
+			"Quantity":         2,
+			"PaymentMethod":    "MTN Mobile Money",
+			"PaymentReference": "FLW-MOCK-123456",
+			"PaidAt":           time.Now(),
+			"Amount":           30000.0,
+			"VoucherCodes":     []string{"SNAP-7MHVALH56F", "SNAP-XXNALLSFQK"},
+			"FinePrints":       "Valid Monday to Friday\nOne voucher per visit",
+		},
+		"report": {
+			"Name": "Sarah",
+			"Report": &ReportData{
+				Report:     salesReport,
+				MerchantID: 3,
+				From:       time.Now().AddDate(0, 0, -7),
+				To:         time.Now(),
+				Sales:      AnalyticsPoint{Revenue: 456000, Orders: 14, Units: 19, Attempts: 20, Conversion: 0.7},
+				Deals: []ReportDeal{
+					{DealID: 12, Title: "Two coffees for the price of one", Orders: 9, Units: 12, Revenue: 180000},
+					{DealID: 15, Title: "Breakfast for two", Orders: 5, Units: 7, Revenue: 276000},
+				},
+				PendingDeals:      1,
+				PendingDealTitles: []string{"Weekend brunch buffet"},
+				Refunds:           1,
+				RefundAmount:      15000,
+				FailedPayments:    6,
+			},
+		},
+	}
+
+	data, ok := samples[name]
+	return data, ok
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type funnelKey struct {
+	dealID uint
+	day    string
+}
+
+type funnelCounts struct {
+	impressions, views, checkoutStarts, purchases int64
+}
+
+// FunnelRecorder buffers funnel events in memory and adds them to the daily
+// counters in one upsert per deal and day, so recording an event on a hot
+// read path costs a map update rather than a database write. Events still
+// buffered when the process exits are lost.
+type FunnelRecorder struct {
+	mu      sync.Mutex
+	pending map[funnelKey]*funnelCounts
+}
+
+// Funnel is the shared recorder, started from main
+var Funnel = NewFunnelRecorder()
+
+func NewFunnelRecorder() *FunnelRecorder {
+	return &FunnelRecorder{pending: make(map[funnelKey]*funnelCounts)}
+}
+
+// Record counts one event at stage (a models.Funnel* constant) for each deal
+func (r *FunnelRecorder) Record(stage string, dealIDs ...uint) {
+	day := time.Now().Format("2006-01-02")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, dealID := range dealIDs {
+		key := funnelKey{dealID, day}
+		counts, ok := r.pending[key]
+		if !ok {
+			counts = &funnelCounts{}
+			r.pending[key] = counts
+		}
+		switch stage {
+		case models.FunnelImpression:
+			counts.impressions++
+		case models.FunnelView:
+			counts.views++
+		case models.FunnelCheckoutStart:
+			counts.checkoutStarts++
+		case models.FunnelPurchase:
+			counts.purchases++
+		}
+	}
+}
+
+// Start flushes the buffer every interval
+func (r *FunnelRecorder) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Flush(); err != nil {
+				fmt.Printf("❌ Funnel flush failed: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Flush adds the buffered counts to the database in one transaction. If it
+// fails the counts go back in the buffer for the next flush.
+func (r *FunnelRecorder) Flush() error {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = make(map[funnelKey]*funnelCounts)
+	r.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	rows := make([]models.DealDailyStat, 0, len(batch))
+	for key, counts := range batch {
+		rows = append(rows, models.DealDailyStat{
+			DealID:         key.dealID,
+			Day:            key.day,
+			Impressions:    counts.impressions,
+			Views:          counts.views,
+			CheckoutStarts: counts.checkoutStarts,
+			Purchases:      counts.purchases,
+		})
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "deal_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"impressions":     gorm.Expr("deal_daily_stats.impressions + excluded.impressions"),
+				"views":           gorm.Expr("deal_daily_stats.views + excluded.views"),
+				"checkout_starts": gorm.Expr("deal_daily_stats.checkout_starts + excluded.checkout_starts"),
+				"purchases":       gorm.Expr("deal_daily_stats.purchases + excluded.purchases"),
+				"updated_at":      gorm.Expr("excluded.updated_at"),
+			}),
+		}).CreateInBatches(rows, 200).Error
+	})
+	if err != nil {
+		r.mu.Lock()
+		for key, counts := range batch {
+			if current, ok := r.pending[key]; ok {
+				current.impressions += counts.impressions
+				current.views += counts.views
+				current.checkoutStarts += counts.checkoutStarts
+				current.purchases += counts.purchases
+			} else {
+				r.pending[key] = counts
+			}
+		}
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// FunnelCounts are summed funnel counters with the rate between each stage
+type FunnelCounts struct {
+	Impressions    int64   `json:"impressions"`
+	Views          int64   `json:"views"`
+	CheckoutStarts int64   `json:"checkout_starts"`
+	Purchases      int64   `json:"purchases"`
+	ViewRate       float64 `json:"view_rate"`     // views / impressions
+	CheckoutRate   float64 `json:"checkout_rate"` // checkout starts / views
+	PurchaseRate   float64 `json:"purchase_rate"` // purchases / checkout starts
+	Conversion     float64 `json:"conversion"`    // purchases / views
+}
+
+func (f *FunnelCounts) calculateRates() {
+	rate := func(a, b int64) float64 {
+		if b == 0 {
+			return 0
+		}
+		return math.Round(float64(a)/float64(b)*10000) / 10000
+	}
+	f.ViewRate = rate(f.Views, f.Impressions)
+	f.CheckoutRate = rate(f.CheckoutStarts, f.Views)
+	f.PurchaseRate = rate(f.Purchases, f.CheckoutStarts)
+	f.Conversion = rate(f.Purchases, f.Views)
+}
+
+type DealFunnel struct {
+	DealID uint   `json:"deal_id"`
+	Title  string `json:"title"`
+	FunnelCounts
+}
+
+type FunnelDay struct {
+	Day string `json:"day"`
+	FunnelCounts
+}
+
+// FunnelQuery selects funnel counters for the days from From to To,
+// inclusive, optionally for one merchant and category
+type FunnelQuery struct {
+	From       time.Time
+	To         time.Time
+	MerchantID uint
+	CategoryID uint // includes subcategories
+	Limit      int  // deals returned, most viewed first
+}
+
+const funnelSums = `COALESCE(SUM(s.impressions), 0) AS impressions, COALESCE(SUM(s.views), 0) AS views,
+	COALESCE(SUM(s.checkout_starts), 0) AS checkout_starts, COALESCE(SUM(s.purchases), 0) AS purchases`
+
+func (q FunnelQuery) stats() *gorm.DB {
+	query := config.DB.Table("deal_daily_stats AS s").
+		Joins("JOIN deals AS d ON d.id = s.deal_id").
+		Where("s.day >= ? AND s.day <= ?", q.From.Format("2006-01-02"), q.To.Format("2006-01-02"))
+	if q.MerchantID != 0 {
+		query = query.Where("d.merchant_id = ?", q.MerchantID)
+	}
+	if q.CategoryID != 0 {
+		query = query.Where("d.category_id IN ?", models.CategoryDescendantIDs(q.CategoryID))
+	}
+	return query
+}
+
+// DealFunnels returns the busiest deals' funnels, a zero-filled day by day
+// funnel and the totals over the range
+func DealFunnels(q FunnelQuery) ([]DealFunnel, []FunnelDay, FunnelCounts, error) {
+	var totals FunnelCounts
+
+	deals := []DealFunnel{}
+	err := q.stats().
+		Select("s.deal_id AS deal_id, d.title AS title, " + funnelSums).
+		Group("s.deal_id, d.title").
+		Order("views DESC, impressions DESC, s.deal_id ASC").
+		Limit(q.Limit).
+		Scan(&deals).Error
+	if err != nil {
+		return nil, nil, totals, err
+	}
+	for i := range deals {
+		deals[i].calculateRates()
+	}
+
+	var rows []FunnelDay
+	err = q.stats().
+		Select("s.day AS day, " + funnelSums).
+		Group("s.day").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, nil, totals, err
+	}
+
+	byDay := make(map[string]FunnelCounts, len(rows))
+	for _, row := range rows {
+		byDay[row.Day] = row.FunnelCounts
+	}
+
+	var daily []FunnelDay
+	for day := q.From; !day.After(q.To); day = day.AddDate(0, 0, 1) {
+		counts := byDay[day.Format("2006-01-02")]
+		counts.calculateRates()
+		daily = append(daily, FunnelDay{Day: day.Format("2006-01-02"), FunnelCounts: counts})
+
+		totals.Impressions += counts.Impressions
+		totals.Views += counts.Views
+		totals.CheckoutStarts += counts.CheckoutStarts
+		totals.Purchases += counts.Purchases
+	}
+	totals.calculateRates()
+
+	return deals, daily, totals, nil
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/webp"
+)
+
+// Image upload limits
+const (
+	MaxImageUploadSize = 10 << 20   // bytes per image
+	MaxImageDimension  = 6000       // pixels per side
+	MaxImagePixels     = 36_000_000 // width x height, guards against decompression bombs
+	MaxGIFFrames       = 200
+	MaxGIFPixels       = 100_000_000 // all frames of an animation together
+	jpegQuality        = 85
+)
+
+// ImageUploadError explains why an upload was rejected. Code is stable for
+// clients; Message is shown to the user.
+type ImageUploadError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *ImageUploadError) Error() string {
+	return e.Message
+}
+
+func imageUploadError(status int, code, format string, args ...interface{}) *ImageUploadError {
+	return &ImageUploadError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ProcessedImage is an upload that has been decoded and re-encoded, so it
+// carries no metadata (EXIF, GPS, comments) or bytes beyond the pixels
+type ProcessedImage struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// ProcessImageUpload identifies an image by its content rather than its file
+// name, enforces the size and dimension limits, and re-encodes it. JPEG, PNG
+// and GIF keep their format; WebP, which has no encoder in Go, becomes JPEG,
+// or PNG when it has transparency. JPEG EXIF orientation is applied to the
+// pixels since the tag itself is dropped.
+func ProcessImageUpload(r io.Reader) (*ProcessedImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageUploadSize+1))
+	if err != nil {
+		return nil, imageUploadError(http.StatusBadRequest, "unreadable_file", "The file could not be read")
+	}
+	if len(data) > MaxImageUploadSize {
+		return nil, imageUploadError(http.StatusRequestEntityTooLarge, "file_too_large", "Images must be %d MB or smaller", MaxImageUploadSize>>20)
+	}
+	if len(data) == 0 {
+		return nil, imageUploadError(http.StatusBadRequest, "empty_file", "The file is empty")
+	}
+
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return nil, imageUploadError(http.StatusUnsupportedMediaType, "unsupported_type",
+			"The file content is %s; only JPG, PNG, GIF and WebP images are allowed", contentType)
+	}
+
+	// Check dimensions from the header before decoding any pixels
+	var config image.Config
+	if contentType == "image/webp" {
+		config, err = webp.DecodeConfig(bytes.NewReader(data))
+	} else {
+		config, _, err = image.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, imageUploadError(http.StatusBadRequest, "corrupt_image", "The image is damaged or incomplete")
+	}
+	if config.Width > MaxImageDimension || config.Height > MaxImageDimension {
+		return nil, imageUploadError(http.StatusBadRequest, "dimensions_too_large",
+			"The image is %dx%d pixels; the maximum is %dx%d", config.Width, config.Height, MaxImageDimension, MaxImageDimension)
+	}
+	if config.Width*config.Height > MaxImagePixels {
+		return nil, imageUploadError(http.StatusBadRequest, "dimensions_too_large",
+			"The image has %d megapixels; the maximum is %d", config.Width*config.Height/1_000_000, MaxImagePixels/1_000_000)
+	}
+
+	switch contentType {
+	case "image/gif":
+		return reencodeGIF(data)
+	case "image/webp":
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, imageUploadError(http.StatusBadRequest, "corrupt_image", "The image is damaged or incomplete")
+		}
+		if isOpaque(img) {
+			return encodeJPEG(img)
+		}
+		return encodePNG(img)
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, imageUploadError(http.StatusBadRequest, "corrupt_image", "The image is damaged or incomplete")
+		}
+		return encodePNG(img)
+	default:
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, imageUploadError(http.StatusBadRequest, "corrupt_image", "The image is damaged or incomplete")
+		}
+		return encodeJPEG(applyOrientation(img, jpegOrientation(data)))
+	}
+}
+
+// RandomFileName returns a server-generated name with the given extension
+func RandomFileName(extension string) (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x%s", randomBytes, extension), nil
+}
+
+func encodeJPEG(img image.Image) (*ProcessedImage, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return processedImage(buf.Bytes(), "image/jpeg", ".jpg", img.Bounds()), nil
+}
+
+func encodePNG(img image.Image) (*ProcessedImage, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return processedImage(buf.Bytes(), "image/png", ".png", img.Bounds()), nil
+}
+
+// reencodeGIF rebuilds an animated GIF from its frames only, dropping
+// comment and application extension blocks
+func reencodeGIF(data []byte) (*ProcessedImage, error) {
+	// Count frames and pixels from the block structure first: DecodeAll
+	// holds every frame in memory, so a small file of many large,
+	// compressible frames would otherwise be a decompression bomb
+	frames, pixels, err := scanGIF(data)
+	if err != nil {
+		return nil, imageUploadError(http.StatusBadRequest, "corrupt_image", "The image is damaged or incomplete")
+	}
+	if frames > MaxGIFFrames {
+		return nil, imageUploadError(http.StatusBadRequest, "too_many_frames",
+			"The animation has more than %d frames", MaxGIFFrames)
+	}
+	if pixels > MaxGIFPixels {
+		return nil, imageUploadError(http.StatusBadRequest, "dimensions_too_large",
+			"The animation has more than %d megapixels across its frames", MaxGIFPixels/1_000_000)
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(decoded.Image) == 0 {
+		return nil, imageUploadError(http.StatusBadRequest, "corrupt_image", "The image is damaged or incomplete")
+	}
+
+	clean := &gif.GIF{
+		Image:     decoded.Image,
+		Delay:     decoded.Delay,
+		LoopCount: decoded.LoopCount,
+		Disposal:  decoded.Disposal,
+		Config:    decoded.Config,
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, clean); err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, decoded.Config.Width, decoded.Config.Height)
+	return processedImage(buf.Bytes(), "image/gif", ".gif", bounds), nil
+}
+
+// scanGIF walks the blocks of a GIF without decoding any pixels and returns
+// the number of frames and their total area. It stops as soon as either
+// limit is passed.
+func scanGIF(data []byte) (frames, pixels int, err error) {
+	errCorrupt := fmt.Errorf("gif: malformed block structure")
+
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, errCorrupt
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	// skipSubBlocks moves past a chain of data sub-blocks ending in an empty one
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(data) {
+				return errCorrupt
+			}
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension: label, then sub-blocks
+			pos += 2
+			if err := skipSubBlocks(); err != nil {
+				return frames, pixels, err
+			}
+		case 0x2C: // image descriptor, optional local colour table, LZW data
+			if pos+10 > len(data) {
+				return frames, pixels, errCorrupt
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos++ // LZW minimum code size
+			if err := skipSubBlocks(); err != nil {
+				return frames, pixels, err
+			}
+
+			frames++
+			pixels += width * height
+			if frames > MaxGIFFrames || pixels > MaxGIFPixels {
+				return frames, pixels, nil
+			}
+		case 0x3B: // trailer
+			return frames, pixels, nil
+		default:
+			return frames, pixels, errCorrupt
+		}
+	}
+
+	// Some encoders leave out the trailer
+	if frames == 0 {
+		return 0, 0, errCorrupt
+	}
+	return frames, pixels, nil
+}
+
+func processedImage(data []byte, contentType, extension string, bounds image.Rectangle) *ProcessedImage {
+	return &ProcessedImage{
+		Data:        data,
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}
+}
+
+func isOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+	return false
+}
+
+// jpegOrientation reads the EXIF orientation tag (1-8) from a JPEG, or 1 when
+// there is none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			return 1 // start of scan: no EXIF before the image data
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation finds tag 0x0112 in IFD0 of a TIFF-structured EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips the pixels so the image displays
+// upright without its EXIF orientation tag
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90 anticlockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
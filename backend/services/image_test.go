@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func encodeTestGIF(t *testing.T, frames, width, height int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	animation := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		frame.SetColorIndex(i%width, 0, 1)
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// bombGIF builds a GIF of many full-screen frames whose image data is a
+// single tiny block, the shape of a decompression bomb
+func bombGIF(frames, side int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GIF89a")
+	binary.Write(&buf, binary.LittleEndian, [2]uint16{uint16(side), uint16(side)})
+	buf.Write([]byte{0x80, 0, 0}) // global colour table of 2 entries
+	buf.Write([]byte{0, 0, 0, 255, 255, 255})
+	for i := 0; i < frames; i++ {
+		buf.WriteByte(0x2C)
+		binary.Write(&buf, binary.LittleEndian, [4]uint16{0, 0, uint16(side), uint16(side)})
+		buf.WriteByte(0)
+		buf.Write([]byte{2, 2, 0x4C, 0x01, 0}) // LZW code size, one sub-block, terminator
+	}
+	buf.WriteByte(0x3B)
+	return buf.Bytes()
+}
+
+func TestScanGIFCountsFramesAndPixels(t *testing.T) {
+	frames, pixels, err := scanGIF(encodeTestGIF(t, 3, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames != 3 || pixels != 600 {
+		t.Fatalf("scanGIF = %d frames, %d pixels; want 3, 600", frames, pixels)
+	}
+}
+
+func TestScanGIFRejectsTruncatedData(t *testing.T) {
+	data := encodeTestGIF(t, 1, 20, 10)
+	if _, _, err := scanGIF(data[:20]); err == nil {
+		t.Fatal("scanGIF accepted a truncated GIF")
+	}
+}
+
+func TestReencodeGIFRejectsBombsBeforeDecoding(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		code string
+	}{
+		{"too many frames", bombGIF(MaxGIFFrames+1, 10), "too_many_frames"},
+		{"too many pixels", bombGIF(4, 6000), "dimensions_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reencodeGIF(tt.data)
+			var uploadErr *ImageUploadError
+			if !errors.As(err, &uploadErr) || uploadErr.Code != tt.code {
+				t.Fatalf("reencodeGIF error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestReencodeGIFKeepsSmallAnimations(t *testing.T) {
+	processed, err := reencodeGIF(encodeTestGIF(t, 3, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(processed.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Image) != 3 || processed.Width != 20 || processed.Height != 10 {
+		t.Fatalf("re-encoded GIF has %d frames at %dx%d", len(decoded.Image), processed.Width, processed.Height)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// ImageVariant is a resized copy of an upload, no wider than Width
+type ImageVariant struct {
+	Name  string
+	Width int
+}
+
+// ImageVariants are generated for every uploaded image, smallest first
+var ImageVariants = []ImageVariant{
+	{Name: "thumb", Width: 320},
+	{Name: "card", Width: 640},
+	{Name: "full", Width: 1280},
+}
+
+// VariantFileName is the file name of an image's variant, e.g.
+// "ab12cd.jpg" -> "ab12cd_card.jpg". GIF variants are a still PNG.
+func VariantFileName(filename, variant string) string {
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	if strings.EqualFold(ext, ".gif") {
+		ext = ".png"
+	}
+	return fmt.Sprintf("%s_%s%s", base, variant, ext)
+}
+
+// VariantOriginals returns the possible original file names of a variant
+// file name, or nil when the name isn't a variant
+func VariantOriginals(filename string) []string {
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+
+	for _, variant := range ImageVariants {
+		if original, ok := strings.CutSuffix(base, "_"+variant.Name); ok && original != "" {
+			originals := []string{original + ext}
+			if strings.EqualFold(ext, ".png") {
+				originals = append(originals, original+".gif")
+			}
+			return originals
+		}
+	}
+	return nil
+}
+
+// VariantForWidth picks the smallest variant at least width pixels wide, or
+// the largest when none is
+func VariantForWidth(width int) ImageVariant {
+	for _, variant := range ImageVariants {
+		if variant.Width >= width {
+			return variant
+		}
+	}
+	return ImageVariants[len(ImageVariants)-1]
+}
+
+// ImageVariantPool resizes uploads into their variants on background
+// workers so uploads return as soon as the original is saved
+type ImageVariantPool struct {
+	jobs     chan string
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// Variants is the shared pool, started from main
+var Variants = NewImageVariantPool()
+
+func NewImageVariantPool() *ImageVariantPool {
+	return &ImageVariantPool{
+		jobs:     make(chan string, 256),
+		inFlight: make(map[string]bool),
+	}
+}
+
+// Start launches the resize workers
+func (p *ImageVariantPool) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for key := range p.jobs {
+				if err := generateImageVariants(key); err != nil {
+					fmt.Printf("❌ Failed to generate variants for %s: %v\n", key, err)
+				}
+				p.mu.Lock()
+				delete(p.inFlight, key)
+				p.mu.Unlock()
+			}
+		}()
+	}
+}
+
+// Enqueue queues variant generation for the stored image at key. Requests
+// for an image already queued are ignored, and when the queue is full the
+// image is skipped: resizing is CPU-bound, and a later request for a missing
+// variant queues it again.
+func (p *ImageVariantPool) Enqueue(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inFlight[key] {
+		return
+	}
+
+	select {
+	case p.jobs <- key:
+		p.inFlight[key] = true
+	default:
+		fmt.Printf("⚠️ Image variant queue full, skipping %s\n", key)
+	}
+}
+
+func generateImageVariants(key string) error {
+	reader, _, err := Store.Get(key)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(reader) // GIFs decode to their first frame
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	dir, filename := path.Split(key)
+	for _, variant := range ImageVariants {
+		if err := putImageVariant(img, dir+VariantFileName(filename, variant.Name), variant.Width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putImageVariant scales img down to width, never up, and stores it
+func putImageVariant(img image.Image, key string, width int) error {
+	bounds := img.Bounds()
+	resized := img
+	if bounds.Dx() > width {
+		height := bounds.Dy() * width / bounds.Dx()
+		if height < 1 {
+			height = 1
+		}
+		scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+		resized = scaled
+	}
+
+	var buf bytes.Buffer
+	var err error
+	contentType := "image/jpeg"
+	if strings.EqualFold(path.Ext(key), ".png") {
+		contentType = "image/png"
+		err = png.Encode(&buf, resized)
+	} else {
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return err
+	}
+
+	return Store.Put(key, &buf, int64(buf.Len()), contentType)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+// PrivateStorageDir is the storage key prefix of files that are never served
+// under /uploads, such as KYC documents
+const PrivateStorageDir = "private"
+
+// KYCStorageDir is the storage key prefix of merchant KYC documents
+const KYCStorageDir = PrivateStorageDir + "/kyc"
+
+// IsPrivateStorageKey reports whether a key must not be served publicly
+func IsPrivateStorageKey(key string) bool {
+	return key == PrivateStorageDir || strings.HasPrefix(key, PrivateStorageDir+"/")
+}
+
+// KYCDocumentKey is the storage key of a KYC document of an application
+func KYCDocumentKey(applicationID uint, fileName string) string {
+	return KYCStorageDir + "/" + strconv.FormatUint(uint64(applicationID), 10) + "/" + fileName
+}
+
+// MoveLocalKYCDocuments copies KYC documents still on the local disk, where
+// they were written before they went through Store, into Store and removes
+// the local copy. The document's key is the path it had on disk, so its row
+// doesn't change.
+func MoveLocalKYCDocuments() {
+	var documents []models.MerchantDocument
+	if err := config.DB.Find(&documents).Error; err != nil {
+		fmt.Printf("❌ Failed to load KYC documents: %v\n", err)
+		return
+	}
+
+	moved := 0
+	for _, document := range documents {
+		if _, err := Store.Stat(document.StoragePath); err == nil {
+			continue
+		}
+		localPath := filepath.FromSlash(document.StoragePath)
+		file, err := os.Open(localPath)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		if err == nil {
+			err = Store.Put(document.StoragePath, file, info.Size(), document.ContentType)
+		}
+		file.Close()
+		if err != nil {
+			fmt.Printf("❌ Failed to move KYC document %d to storage: %v\n", document.ID, err)
+			continue
+		}
+		os.Remove(localPath)
+		moved++
+	}
+
+	if moved > 0 {
+		fmt.Printf("🧹 Moved %d KYC document(s) from the local disk to storage\n", moved)
+	}
+}
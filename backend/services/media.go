@@ -0,0 +1,318 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+
+	"gorm.io/gorm"
+)
+
+// ImageStorageDir is the storage key prefix of uploaded images, served under
+// /uploads/images
+const ImageStorageDir = "images"
+
+// QuotaExceededError is returned when an upload would take a user past their
+// storage quota
+type QuotaExceededError struct {
+	Used     int64
+	Limit    int64
+	Required int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("upload quota exceeded: %d of %d bytes used, %d more needed", e.Used, e.Limit, e.Required)
+}
+
+// ImageHash is the content hash used to deduplicate uploads
+func ImageHash(processed *ProcessedImage) string {
+	sum := sha256.Sum256(processed.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckMediaQuota fails with a QuotaExceededError when storing the images
+// would exceed the owner's quota. Images the owner already has are free.
+func CheckMediaQuota(ownerID uint, role string, images []*ProcessedImage) error {
+	limit := models.MediaQuota(role)
+	if limit == 0 {
+		return nil
+	}
+
+	var required int64
+	seen := make(map[string]bool)
+	for _, image := range images {
+		hash := ImageHash(image)
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		var existing int64
+		config.DB.Model(&models.MediaAsset{}).Where("owner_id = ? AND hash = ?", ownerID, hash).Count(&existing)
+		if existing == 0 {
+			required += int64(len(image.Data))
+		}
+	}
+
+	used := models.MediaUsage(ownerID)
+	if used+required > limit {
+		return &QuotaExceededError{Used: used, Limit: limit, Required: required}
+	}
+	return nil
+}
+
+// StoreImage saves a processed image for its owner. An image the owner has
+// already uploaded returns their existing asset, flagged as existing; one
+// someone else uploaded gets a new asset sharing their stored file. Only new
+// content is written to storage.
+func StoreImage(ownerID uint, processed *ProcessedImage) (*models.MediaAsset, bool, error) {
+	hash := ImageHash(processed)
+
+	var own models.MediaAsset
+	if err := config.DB.Where("owner_id = ? AND hash = ?", ownerID, hash).First(&own).Error; err == nil {
+		return &own, true, nil
+	}
+
+	asset := models.MediaAsset{
+		OwnerID:     &ownerID,
+		ContentType: processed.ContentType,
+		Size:        int64(len(processed.Data)),
+		Hash:        hash,
+		Width:       processed.Width,
+		Height:      processed.Height,
+	}
+
+	var shared models.MediaAsset
+	sharing := false
+	if err := config.DB.Where("hash = ?", hash).First(&shared).Error; err == nil {
+		if _, err := Store.Stat(shared.Key); err == nil {
+			asset.Key = shared.Key
+			sharing = true
+		}
+	}
+
+	if !sharing {
+		filename, err := RandomFileName(processed.Extension)
+		if err != nil {
+			return nil, false, err
+		}
+		asset.Key = ImageStorageDir + "/" + filename
+		if err := Store.Put(asset.Key, bytes.NewReader(processed.Data), asset.Size, processed.ContentType); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := config.DB.Create(&asset).Error; err != nil {
+		if !sharing {
+			Store.Delete(asset.Key)
+		}
+		return nil, false, err
+	}
+
+	if !sharing {
+		Variants.Enqueue(asset.Key)
+	}
+	return &asset, false, nil
+}
+
+// DeleteMediaAsset removes the asset record, and its stored file and variants
+// once no other asset shares them
+func DeleteMediaAsset(asset models.MediaAsset) error {
+	if err := config.DB.Delete(&asset).Error; err != nil {
+		return err
+	}
+
+	var sharing int64
+	config.DB.Model(&models.MediaAsset{}).Where("key = ?", asset.Key).Count(&sharing)
+	if sharing > 0 {
+		return nil
+	}
+
+	if err := Store.Delete(asset.Key); err != nil {
+		return err
+	}
+	dir, filename := path.Split(asset.Key)
+	for _, variant := range ImageVariants {
+		if err := Store.Delete(dir + VariantFileName(filename, variant.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReferencedMediaKeys counts the references to each stored original. A URL
+// pointing at a variant counts towards its original.
+func ReferencedMediaKeys() (map[string]int, error) {
+	urls, err := models.MediaReferenceURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	references := make(map[string]int)
+	for _, raw := range urls {
+		key, ok := models.MediaKeyFromURL(raw)
+		if !ok {
+			continue
+		}
+		references[key]++
+
+		dir, filename := path.Split(key)
+		for _, original := range VariantOriginals(filename) {
+			references[dir+original]++
+		}
+	}
+	return references, nil
+}
+
+// MediaCleanupResult summarises one run of the cleanup job
+type MediaCleanupResult struct {
+	Imported     int   `json:"imported"`     // untracked files adopted as assets
+	Referenced   int   `json:"referenced"`   // assets in use
+	Unreferenced int   `json:"unreferenced"` // assets in their grace period
+	Deleted      int   `json:"deleted"`
+	FreedBytes   int64 `json:"freed_bytes"`
+}
+
+// CleanupMedia adopts stored images that have no asset record, refreshes
+// every asset's reference count, and deletes assets that have been
+// unreferenced for longer than grace
+func CleanupMedia(grace time.Duration) (MediaCleanupResult, error) {
+	var result MediaCleanupResult
+
+	imported, err := importUntrackedImages()
+	if err != nil {
+		return result, err
+	}
+	result.Imported = imported
+
+	references, err := ReferencedMediaKeys()
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	var assets []models.MediaAsset
+	err = config.DB.FindInBatches(&assets, 500, func(tx *gorm.DB, batch int) error {
+		for _, asset := range assets {
+			count := references[asset.Key]
+			updates := map[string]interface{}{"reference_count": count}
+
+			if count > 0 {
+				updates["unreferenced_since"] = nil
+				result.Referenced++
+			} else if asset.UnreferencedSince == nil {
+				updates["unreferenced_since"] = now
+			}
+
+			if err := config.DB.Model(&models.MediaAsset{}).Where("id = ?", asset.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return result, err
+	}
+
+	var expired []models.MediaAsset
+	config.DB.Where("reference_count = 0 AND unreferenced_since <= ?", now.Add(-grace)).Find(&expired)
+	for _, asset := range expired {
+		if err := DeleteMediaAsset(asset); err != nil {
+			fmt.Printf("❌ Failed to delete media asset %d (%s): %v\n", asset.ID, asset.Key, err)
+			continue
+		}
+		result.Deleted++
+		result.FreedBytes += asset.Size
+	}
+
+	var unreferenced int64
+	config.DB.Model(&models.MediaAsset{}).Where("reference_count = 0").Count(&unreferenced)
+	result.Unreferenced = int(unreferenced)
+
+	return result, nil
+}
+
+// importUntrackedImages records stored originals that predate asset
+// tracking, without an owner, so the cleanup job can manage them too
+func importUntrackedImages() (int, error) {
+	objects, err := Store.List(ImageStorageDir + "/")
+	if err != nil {
+		return 0, err
+	}
+
+	var tracked []string
+	config.DB.Model(&models.MediaAsset{}).Distinct().Pluck("key", &tracked)
+	known := make(map[string]bool, len(tracked))
+	for _, key := range tracked {
+		known[key] = true
+	}
+
+	imported := 0
+	for _, object := range objects {
+		if known[object.Key] || VariantOriginals(path.Base(object.Key)) != nil {
+			continue
+		}
+
+		asset := models.MediaAsset{
+			Key:         object.Key,
+			ContentType: object.ContentType,
+			Size:        object.Size,
+		}
+		if reader, _, err := Store.Get(object.Key); err == nil {
+			hash := sha256.New()
+			_, err = io.Copy(hash, reader)
+			reader.Close()
+			if err == nil {
+				asset.Hash = hex.EncodeToString(hash.Sum(nil))
+			}
+		}
+
+		if err := config.DB.Create(&asset).Error; err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// MediaOrphanGrace is how long an unreferenced asset is kept, as configured
+// by StartMediaCleanup
+var MediaOrphanGrace = 72 * time.Hour
+
+// StartMediaCleanup runs CleanupMedia every interval. The grace period comes
+// from MEDIA_ORPHAN_GRACE (a duration such as "72h"), defaulting to 3 days.
+func StartMediaCleanup(interval time.Duration) {
+	grace := 72 * time.Hour
+	if value := os.Getenv("MEDIA_ORPHAN_GRACE"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			grace = parsed
+		} else {
+			fmt.Printf("⚠️ Invalid MEDIA_ORPHAN_GRACE %q, using %s\n", value, grace)
+		}
+	}
+	MediaOrphanGrace = grace
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			result, err := CleanupMedia(grace)
+			if err != nil {
+				fmt.Printf("❌ Media cleanup failed: %v\n", err)
+			} else if result.Imported > 0 || result.Deleted > 0 {
+				fmt.Printf("🧹 Media cleanup: %d imported, %d deleted (%d bytes freed), %d unreferenced\n",
+					result.Imported, result.Deleted, result.FreedBytes, result.Unreferenced)
+			}
+			<-ticker.C
+		}
+	}()
+}
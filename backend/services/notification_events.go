@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"snapadeal/models"
+)
+
+// DealApprovedEvent tells the merchant their deal is live
+func DealApprovedEvent(deal models.Deal) NotificationEvent {
+	return NotificationEvent{
+		Type:     models.NotificationDealApproved,
+		UserID:   deal.MerchantID,
+		Title:    "Deal Approved! 🎉",
+		Message:  "Your deal '" + deal.Title + "' has been approved and is now live!",
+		Data:     map[string]interface{}{"deal_id": deal.ID},
+		Channels: []string{ChannelInApp, ChannelEmail},
+		SMS:      fmt.Sprintf("Snapadeal: your deal '%s' is approved and live.", deal.Title),
+	}
+}
+
+// DealRejectedEvent tells the merchant their deal was rejected, with the reason
+func DealRejectedEvent(deal models.Deal, reason string) NotificationEvent {
+	message := "Your deal '" + deal.Title + "' has been rejected."
+	if reason != "" {
+		message += " Reason: " + reason
+	}
+
+	return NotificationEvent{
+		Type:     models.NotificationDealRejected,
+		UserID:   deal.MerchantID,
+		Title:    "Deal Rejected",
+		Message:  message,
+		Data:     map[string]interface{}{"deal_id": deal.ID, "reason": reason},
+		Channels: []string{ChannelInApp, ChannelEmail},
+	}
+}
+
+// DealPurchasedEvent tells the merchant a customer paid for their deal
+func DealPurchasedEvent(deal models.Deal, transaction models.Transaction) NotificationEvent {
+	return NotificationEvent{
+		Type:    models.NotificationDealPurchased,
+		UserID:  deal.MerchantID,
+		Title:   "New Sale! 💰",
+		Message: fmt.Sprintf("Your deal '%s' was purchased by a customer!", deal.Title),
+		Data: map[string]interface{}{
+			"deal_id":        deal.ID,
+			"transaction_id": transaction.ID,
+			"quantity":       transaction.Quantity,
+		},
+		Channels: []string{ChannelInApp, ChannelEmail, ChannelSMS},
+		SMS:      fmt.Sprintf("Snapadeal: new sale! %d x '%s' (UGX %.0f).", transaction.Quantity, deal.Title, transaction.Amount),
+	}
+}
+
+// PurchaseReceiptEvent sends the customer their receipt and voucher codes. The
+// transaction must have Deal, User and Vouchers loaded.
+func PurchaseReceiptEvent(transaction models.Transaction) NotificationEvent {
+	codes := make([]string, 0, len(transaction.Vouchers))
+	for _, voucher := range transaction.Vouchers {
+		codes = append(codes, voucher.Code)
+	}
+
+	message := fmt.Sprintf("Your purchase of %d x '%s' for %s is confirmed.", transaction.Quantity, transaction.Deal.Title, FormatUGX(transaction.Amount))
+	if len(codes) > 0 {
+		message += " Voucher codes: " + strings.Join(codes, ", ")
+	}
+
+	return NotificationEvent{
+		Type:    models.NotificationPurchaseReceipt,
+		UserID:  transaction.UserID,
+		Title:   "Purchase Confirmed! 🎟️",
+		Message: message,
+		Data: map[string]interface{}{
+			"transaction_id": transaction.ID,
+			"deal_id":        transaction.DealID,
+			"receipt_number": ReceiptNumber(transaction),
+			"voucher_codes":  codes,
+		},
+		Channels: []string{ChannelInApp, ChannelEmail, ChannelSMS},
+		Email: func(e *EmailService, user models.User, unsubscribeURL string) error {
+			return e.SendReceiptEmail(transaction)
+		},
+		SMS: fmt.Sprintf("Snapadeal: payment of %s received for '%s'. Voucher(s): %s", FormatUGX(transaction.Amount), transaction.Deal.Title, strings.Join(codes, ", ")),
+	}
+}
+
+// TransactionRefundedEvent tells the customer their purchase was refunded
+// and its vouchers can no longer be used
+func TransactionRefundedEvent(transaction models.Transaction) NotificationEvent {
+	return NotificationEvent{
+		Type:     models.NotificationTransactionRefunded,
+		UserID:   transaction.UserID,
+		Title:    "Purchase refunded",
+		Message:  fmt.Sprintf("Your purchase of '%s' for %s has been refunded. Its vouchers can no longer be used.", transaction.Deal.Title, FormatUGX(transaction.Amount)),
+		Data:     map[string]interface{}{"transaction_id": transaction.ID, "deal_id": transaction.DealID},
+		Channels: []string{ChannelInApp, ChannelEmail},
+	}
+}
+
+// MerchantApplicationReviewedEvent tells the applicant the outcome of their KYC review
+func MerchantApplicationReviewedEvent(application models.MerchantApplication) NotificationEvent {
+	event := NotificationEvent{
+		UserID:   application.UserID,
+		Data:     map[string]interface{}{"application_id": application.ID, "status": application.Status},
+		Channels: []string{ChannelInApp, ChannelEmail},
+		Email: func(e *EmailService, user models.User, unsubscribeURL string) error {
+			return e.SendMerchantApplicationEmail(user.Email, user.FirstName, application.BusinessName, application.Status, application.ReviewNotes)
+		},
+	}
+
+	switch application.Status {
+	case models.MerchantApplicationApproved:
+		event.Type = models.NotificationMerchantApproved
+		event.Title = "Merchant Account Approved! 🎉"
+		event.Message = "Your business '" + application.BusinessName + "' has been verified. You can now create deals!"
+		event.Channels = append(event.Channels, ChannelSMS)
+		event.SMS = "Snapadeal: your merchant account for '" + application.BusinessName + "' is approved. You can now create deals."
+	case models.MerchantApplicationRejected:
+		event.Type = models.NotificationMerchantRejected
+		event.Title = "Merchant Application Rejected"
+		event.Message = "Your merchant application for '" + application.BusinessName + "' has been rejected. Reason: " + application.ReviewNotes
+	default:
+		event.Type = models.NotificationMerchantInfoRequired
+		event.Title = "More Information Needed"
+		event.Message = "Please update your merchant application for '" + application.BusinessName + "': " + application.ReviewNotes
+	}
+
+	return event
+}
+
+// PasswordChangedEvent is a mandatory security alert sent after a password reset
+func PasswordChangedEvent(user models.User) NotificationEvent {
+	return NotificationEvent{
+		Type:     models.NotificationSecurityAlert,
+		UserID:   user.ID,
+		Title:    "Your password was changed",
+		Message:  "The password for your Snapadeal account was just reset. If this wasn't you, contact support immediately.",
+		Data:     map[string]interface{}{"event": "password_reset"},
+		Channels: []string{ChannelInApp, ChannelEmail, ChannelSMS},
+		SMS:      "Snapadeal: your password was just changed. If this wasn't you, contact support immediately.",
+	}
+}
+
+// AccountSuspendedEvent tells a user an admin suspended their account. It
+// goes out by email and SMS too, as the user can no longer log in to see it.
+func AccountSuspendedEvent(user models.User, reason string) NotificationEvent {
+	return NotificationEvent{
+		Type:     models.NotificationSecurityAlert,
+		UserID:   user.ID,
+		Title:    "Your account has been suspended",
+		Message:  "Your Snapadeal account has been suspended. Reason: " + reason + " If you think this is a mistake, contact support.",
+		Data:     map[string]interface{}{"event": "account_suspended", "reason": reason},
+		Channels: []string{ChannelInApp, ChannelEmail, ChannelSMS},
+		SMS:      "Snapadeal: your account has been suspended. Check your email for details or contact support.",
+	}
+}
+
+// AccountReinstatedEvent tells a user their suspension has been lifted
+func AccountReinstatedEvent(user models.User) NotificationEvent {
+	return NotificationEvent{
+		Type:     models.NotificationSecurityAlert,
+		UserID:   user.ID,
+		Title:    "Your account has been reinstated",
+		Message:  "Your Snapadeal account is no longer suspended.",
+		Data:     map[string]interface{}{"event": "account_reinstated"},
+		Channels: []string{ChannelInApp, ChannelEmail},
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+// StreamEvent is one Server-Sent Event pushed to a connected client
+type StreamEvent struct {
+	ID    string      // SSE id, set to the notification ID so clients can resume
+	Event string      // notification, unread_count
+	Data  interface{} // JSON-encoded by the stream handler
+}
+
+// NotificationHub is an in-process pub/sub of stream events keyed by user.
+// Each open stream subscribes with its own buffered channel; slow clients
+// drop events rather than block publishers and catch up via Last-Event-ID.
+type NotificationHub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan StreamEvent]struct{}
+}
+
+var Hub = &NotificationHub{subscribers: make(map[uint]map[chan StreamEvent]struct{})}
+
+// Subscribe registers a stream for the user. Call the returned function to unsubscribe.
+func (h *NotificationHub) Subscribe(userID uint) (<-chan StreamEvent, func()) {
+	ch := make(chan StreamEvent, 32)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan StreamEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends an event to every open stream of the user
+func (h *NotificationHub) Publish(userID uint, event StreamEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			fmt.Printf("⚠️ Notification stream for user %d is full - dropping event\n", userID)
+		}
+	}
+}
+
+// Disconnect closes every open stream of the user, e.g. once their sessions
+// are revoked
+func (h *NotificationHub) Disconnect(userID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[userID] {
+		close(ch)
+	}
+	delete(h.subscribers, userID)
+}
+
+// IsConnected reports whether the user has at least one open stream
+func (h *NotificationHub) IsConnected(userID uint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[userID]) > 0
+}
+
+// PublishNotification pushes a newly created notification and the new unread
+// count. Call it once the notification is committed, so the stream never
+// announces a row a client can't fetch yet.
+func PublishNotification(notification models.Notification) {
+	if !Hub.IsConnected(notification.UserID) {
+		return
+	}
+
+	Hub.Publish(notification.UserID, StreamEvent{
+		ID:    fmt.Sprintf("%d", notification.ID),
+		Event: "notification",
+		Data:  notification,
+	})
+	publishUnreadCount(notification.UserID)
+}
+
+// PublishUnreadCount pushes the user's current unread notification count
+func PublishUnreadCount(userID uint) {
+	if !Hub.IsConnected(userID) {
+		return
+	}
+	publishUnreadCount(userID)
+}
+
+func publishUnreadCount(userID uint) {
+	var unreadCount int64
+	config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Count(&unreadCount)
+
+	Hub.Publish(userID, StreamEvent{
+		Event: "unread_count",
+		Data:  map[string]int64{"unread_count": unreadCount},
+	})
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+// Notification delivery channels
+const (
+	ChannelInApp = models.NotificationChannelInApp
+	ChannelEmail = models.NotificationChannelEmail
+	ChannelSMS   = models.NotificationChannelSMS
+)
+
+// NotificationEvent is a typed event rendered for each channel it goes out on.
+// Build these with the constructors in notification_events.go.
+type NotificationEvent struct {
+	Type     string // one of the models.Notification* constants
+	UserID   uint
+	Title    string                 // in-app title and default email subject
+	Message  string                 // in-app body
+	Data     map[string]interface{} // stored as JSON on the in-app row
+	Channels []string               // channels the event can be sent on; user preferences pick from these
+
+	// Email renders and sends the email for this event. Defaults to the
+	// generic notification email built from Title and Message. unsubscribeURL
+	// is empty for mandatory notifications.
+	Email func(e *EmailService, user models.User, unsubscribeURL string) error
+	// SMS is the text message body. Defaults to "Title: Message".
+	SMS string
+}
+
+type deliveryJob struct {
+	channel string
+	event   NotificationEvent
+	user    models.User
+}
+
+// NotificationService persists in-app notifications and delivers email and
+// SMS asynchronously on a worker pool, retrying failures with backoff so
+// handlers never wait on SMTP.
+type NotificationService struct {
+	email       *EmailService
+	sms         SMSProvider
+	jobs        chan deliveryJob
+	maxAttempts int
+	baseBackoff time.Duration
+}
+
+// Notifications is the shared dispatcher, created and started from main once
+// the environment is loaded
+var Notifications *NotificationService
+
+func NewNotificationService(email *EmailService, sms SMSProvider) *NotificationService {
+	return &NotificationService{
+		email:       email,
+		sms:         sms,
+		jobs:        make(chan deliveryJob, 256),
+		maxAttempts: 3,
+		baseBackoff: 2 * time.Second,
+	}
+}
+
+// Start launches the delivery workers
+func (s *NotificationService) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range s.jobs {
+				s.deliver(job)
+			}
+		}()
+	}
+}
+
+// Notify persists the in-app notification and queues the other channels,
+// skipping any the recipient has switched off. It returns the in-app row, or
+// nil when not sent in-app.
+func (s *NotificationService) Notify(event NotificationEvent) (*models.Notification, error) {
+	var user models.User
+	if err := config.DB.First(&user, event.UserID).Error; err != nil {
+		return nil, fmt.Errorf("notification recipient %d not found: %w", event.UserID, err)
+	}
+
+	prefs, err := models.LoadNotificationPreferences(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var notification *models.Notification
+	for _, channel := range event.Channels {
+		if !prefs.Enabled(event.Type, channel) {
+			continue
+		}
+
+		switch channel {
+		case ChannelInApp:
+			row, err := s.createInApp(event)
+			if err != nil {
+				return nil, err
+			}
+			notification = row
+		case ChannelEmail, ChannelSMS:
+			s.enqueue(deliveryJob{channel: channel, event: event, user: user})
+		}
+	}
+
+	return notification, nil
+}
+
+func (s *NotificationService) createInApp(event NotificationEvent) (*models.Notification, error) {
+	data := ""
+	if event.Data != nil {
+		encoded, err := json.Marshal(event.Data)
+		if err != nil {
+			return nil, err
+		}
+		data = string(encoded)
+	}
+
+	notification := models.Notification{
+		UserID:  event.UserID,
+		Title:   event.Title,
+		Message: event.Message,
+		Type:    event.Type,
+		Data:    data,
+	}
+	if err := config.DB.Create(&notification).Error; err != nil {
+		return nil, err
+	}
+	PublishNotification(notification)
+	return &notification, nil
+}
+
+func (s *NotificationService) enqueue(job deliveryJob) {
+	select {
+	case s.jobs <- job:
+	default:
+		// Queue is full - deliver on a goroutine rather than block the request
+		go s.deliver(job)
+	}
+}
+
+func (s *NotificationService) deliver(job deliveryJob) {
+	var err error
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		err = s.send(job)
+		if err == nil {
+			return
+		}
+
+		fmt.Printf("⚠️ %s notification %q to user %d failed (attempt %d/%d): %v\n",
+			job.channel, job.event.Type, job.user.ID, attempt, s.maxAttempts, err)
+
+		if attempt < s.maxAttempts {
+			time.Sleep(s.baseBackoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+
+	fmt.Printf("❌ Giving up on %s notification %q to user %d: %v\n", job.channel, job.event.Type, job.user.ID, err)
+}
+
+func (s *NotificationService) send(job deliveryJob) error {
+	switch job.channel {
+	case ChannelEmail:
+		unsubscribeURL := ""
+		if !models.IsMandatoryNotification(job.event.Type, ChannelEmail) {
+			unsubscribeURL = UnsubscribeURL(job.user.ID, job.event.Type, ChannelEmail)
+		}
+		email := s.email.WithLocale(job.user.Locale)
+		if job.event.Email != nil {
+			return job.event.Email(email, job.user, unsubscribeURL)
+		}
+		return email.SendNotificationEmail(job.user.Email, job.user.FirstName, job.event.Title, job.event.Message, unsubscribeURL)
+	case ChannelSMS:
+		if job.user.Phone == "" {
+			return nil
+		}
+		text := job.event.SMS
+		if text == "" {
+			text = job.event.Title + ": " + job.event.Message
+		}
+		return s.sms.Send(job.user.Phone, text)
+	default:
+		return fmt.Errorf("unknown channel %s", job.channel)
+	}
+}
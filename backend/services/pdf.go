@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDFDocument is a minimal text-only PDF writer (A4, Helvetica) - enough for
+// receipts and reports without pulling in a PDF library. Lines are laid out
+// top to bottom and flow onto new pages automatically.
+type PDFDocument struct {
+	pages [][]string // content stream operators per page
+	y     float64
+}
+
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+func NewPDFDocument() *PDFDocument {
+	doc := &PDFDocument{}
+	doc.newPage()
+	return doc
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, nil)
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// Text writes a paragraph, wrapping it to the page width
+func (d *PDFDocument) Text(size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	// Helvetica averages roughly half an em per character
+	maxChars := int((pdfPageWidth - 2*pdfMargin) / (size * 0.5))
+	for _, line := range wrapText(pdfSafeText(text), maxChars) {
+		d.ensureSpace(size * 1.4)
+		d.y -= size * 1.4
+		d.write(fmt.Sprintf("BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET", font, size, pdfMargin, d.y, pdfEscape(line)))
+	}
+}
+
+// Row writes a label on the left and a value aligned at a fixed column
+func (d *PDFDocument) Row(size float64, label, value string) {
+	d.ensureSpace(size * 1.4)
+	d.y -= size * 1.4
+	d.write(fmt.Sprintf("BT /F2 %.1f Tf %.1f %.1f Td (%s) Tj ET", size, pdfMargin, d.y, pdfEscape(pdfSafeText(label))))
+	d.write(fmt.Sprintf("BT /F1 %.1f Tf %.1f %.1f Td (%s) Tj ET", size, pdfMargin+170, d.y, pdfEscape(pdfSafeText(value))))
+}
+
+// Rule draws a horizontal line across the page
+func (d *PDFDocument) Rule() {
+	d.ensureSpace(12)
+	d.y -= 8
+	d.write(fmt.Sprintf("0.8 G %.1f %.1f m %.1f %.1f l S 0 G", pdfMargin, d.y, pdfPageWidth-pdfMargin, d.y))
+	d.y -= 4
+}
+
+// Space adds vertical whitespace
+func (d *PDFDocument) Space(height float64) {
+	d.y -= height
+}
+
+func (d *PDFDocument) ensureSpace(height float64) {
+	if d.y-height < pdfMargin {
+		d.newPage()
+	}
+}
+
+func (d *PDFDocument) write(op string) {
+	d.pages[len(d.pages)-1] = append(d.pages[len(d.pages)-1], op)
+}
+
+// Bytes renders the document
+func (d *PDFDocument) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	addObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1: catalog, 2: page tree, 3-4: fonts, then a page and content stream per page
+	pageIDs := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageIDs[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+
+	addObject("<< /Type /Catalog /Pages 2 0 R >>")
+	addObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageIDs, " "), len(d.pages)))
+	addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, ops := range d.pages {
+		content := strings.Join(ops, "\n")
+		addObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+i*2))
+		addObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// pdfSafeText keeps the Latin-1 characters the standard fonts can draw and
+// drops the rest (emoji in deal titles, for example)
+func pdfSafeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\n' || r == '\t':
+			b.WriteRune(' ')
+		case r >= 0x20 && r <= 0xff:
+			b.WriteByte(byte(r))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func pdfEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(text)
+}
+
+func wrapText(text string, maxChars int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > maxChars {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"snapadeal/models"
+)
+
+// FormatUGX formats an amount as whole Uganda shillings, e.g. "UGX 38,000"
+func FormatUGX(amount float64) string {
+	digits := fmt.Sprintf("%.0f", amount)
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	groups = append([]string{digits}, groups...)
+
+	if negative {
+		return "UGX -" + strings.Join(groups, ",")
+	}
+	return "UGX " + strings.Join(groups, ",")
+}
+
+// ReceiptNumber is the customer-facing receipt number for a transaction
+func ReceiptNumber(transaction models.Transaction) string {
+	return fmt.Sprintf("SNAP-R%06d", transaction.ID)
+}
+
+func ReceiptFilename(transaction models.Transaction) string {
+	return fmt.Sprintf("snapadeal-receipt-%s.pdf", ReceiptNumber(transaction))
+}
+
+// BuildReceiptPDF renders the receipt. The transaction must have Deal, User
+// and Vouchers loaded.
+func BuildReceiptPDF(transaction models.Transaction) []byte {
+	doc := NewPDFDocument()
+
+	doc.Text(22, true, "Snapadeal Uganda")
+	doc.Text(12, false, "Payment receipt")
+	doc.Rule()
+
+	doc.Row(11, "Receipt number", ReceiptNumber(transaction))
+	doc.Row(11, "Date", transaction.UpdatedAt.Format("2 Jan 2006 15:04"))
+	doc.Row(11, "Customer", strings.TrimSpace(transaction.User.FirstName+" "+transaction.User.LastName))
+	doc.Row(11, "Email", transaction.User.Email)
+	doc.Row(11, "Payment method", paymentMethodLabel(transaction.PaymentMethod))
+	doc.Row(11, "Payment reference", transaction.PaymentReference)
+	doc.Rule()
+
+	doc.Text(13, true, "Order")
+	doc.Row(11, "Deal", transaction.Deal.Title)
+	// What was paid per unit, not the deal's current price
+	unitPrice := transaction.Amount
+	if transaction.Quantity > 0 {
+		unitPrice = transaction.Amount / float64(transaction.Quantity)
+	}
+	doc.Row(11, "Unit price", FormatUGX(unitPrice))
+	doc.Row(11, "Quantity", fmt.Sprintf("%d", transaction.Quantity))
+	doc.Row(12, "Total paid", FormatUGX(transaction.Amount))
+	doc.Rule()
+
+	doc.Text(13, true, "Your vouchers")
+	if len(transaction.Vouchers) == 0 {
+		doc.Text(11, false, "Your vouchers are being issued and will appear in your account shortly.")
+	}
+	for _, voucher := range transaction.Vouchers {
+		doc.Text(14, true, voucher.Code)
+	}
+	doc.Text(10, false, "Show a voucher code at the merchant to redeem your deal. Each code can be used once.")
+
+	if transaction.Deal.FinePrints != "" {
+		doc.Rule()
+		doc.Text(13, true, "Fine print")
+		for _, line := range strings.Split(transaction.Deal.FinePrints, "\n") {
+			if strings.TrimSpace(line) != "" {
+				doc.Text(10, false, line)
+			}
+		}
+	}
+
+	doc.Space(20)
+	doc.Text(9, false, "Thank you for shopping with Snapadeal Uganda.")
+
+	return doc.Bytes()
+}
+
+// SendReceiptEmail emails the receipt with the PDF attached. The transaction
+// must have Deal, User and Vouchers loaded.
+func (e *EmailService) SendReceiptEmail(transaction models.Transaction) error {
+	codes := make([]string, 0, len(transaction.Vouchers))
+	for _, voucher := range transaction.Vouchers {
+		codes = append(codes, voucher.Code)
+	}
+
+	return e.SendTemplate(transaction.User.Email, "receipt", EmailData{
+		"Name":             transaction.User.FirstName,
+		"ReceiptNumber":    ReceiptNumber(transaction),
+		"DealTitle":        transaction.Deal.Title,
+		"Quantity":         transaction.Quantity,
+		"PaymentMethod":    paymentMethodLabel(transaction.PaymentMethod),
+		"PaymentReference": transaction.PaymentReference,
+		"PaidAt":           transaction.UpdatedAt,
+		"Amount":           transaction.Amount,
+		"VoucherCodes":     codes,
+		"FinePrints":       transaction.Deal.FinePrints,
+	}, "", EmailAttachment{
+		Filename:    ReceiptFilename(transaction),
+		ContentType: "application/pdf",
+		Data:        BuildReceiptPDF(transaction),
+	})
+}
+
+func paymentMethodLabel(method string) string {
+	switch method {
+	case "mtn_money":
+		return "MTN Mobile Money"
+	case "airtel_money":
+		return "Airtel Money"
+	case "mobile_money":
+		return "Mobile Money"
+	default:
+		return method
+	}
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"snapadeal/config"
+	"snapadeal/models"
+)
+
+var ErrReportNotAvailable = errors.New("this report is not available for your account")
+
+// maxReportPeriod caps how far back a report reaches, e.g. after a
+// subscription was switched off for months
+const maxReportPeriod = 31 * 24 * time.Hour
+
+// ReportDeal is one deal's completed sales in a report
+type ReportDeal struct {
+	DealID     uint    `json:"deal_id"`
+	Title      string  `json:"title"`
+	MerchantID uint    `json:"merchant_id"`
+	Orders     int64   `json:"orders"`
+	Units      int64   `json:"units"`
+	Revenue    float64 `json:"revenue"`
+}
+
+// ReportData is everything a report email and its CSV show
+type ReportData struct {
+	Report              models.ReportDefinition
+	MerchantID          uint // 0 for the platform digest
+	From                time.Time
+	To                  time.Time
+	Sales               AnalyticsPoint
+	Deals               []ReportDeal // every deal that sold, by revenue
+	PendingDeals        int64
+	PendingDealTitles   []string // merchant reports only, oldest first
+	PendingApplications int64    // digest only
+	Refunds             int64
+	RefundAmount        float64
+	FailedPayments      int64
+	NewUsers            int64 // digest only
+}
+
+// TopDeals is the first few deals by revenue
+func (r *ReportData) TopDeals() []ReportDeal {
+	if len(r.Deals) > 5 {
+		return r.Deals[:5]
+	}
+	return r.Deals
+}
+
+// ReportScope checks the user may receive the report and returns the
+// merchant it covers: merchant reports go to organisation owners and
+// managers, the digest to admins who can view statistics
+func ReportScope(userID uint, report string) (uint, error) {
+	switch report {
+	case models.ReportMerchantSales:
+		member, err := models.GetMerchantMembership(userID)
+		if err != nil || !member.HasRole(models.MerchantRoleOwner, models.MerchantRoleManager) {
+			return 0, ErrReportNotAvailable
+		}
+		return member.MerchantID(), nil
+	case models.ReportAdminDigest:
+		allowed, err := models.UserHasPermissions(userID, models.PermissionStatsView)
+		if err != nil || !allowed {
+			return 0, ErrReportNotAvailable
+		}
+		return 0, nil
+	}
+	return 0, ErrReportNotAvailable
+}
+
+// BuildReport gathers the report's figures for [from, to)
+func BuildReport(report string, merchantID uint, from, to time.Time) (*ReportData, error) {
+	definition, ok := models.GetReportDefinition(report)
+	if !ok {
+		return nil, ErrReportNotAvailable
+	}
+	data := &ReportData{Report: definition, MerchantID: merchantID, From: from, To: to}
+
+	q := AnalyticsQuery{From: from, To: to, Interval: IntervalDay, MerchantID: merchantID}
+	_, totals, err := TransactionAnalytics(q)
+	if err != nil {
+		return nil, err
+	}
+	data.Sales = totals
+
+	where, args := timeRange("t.created_at", from, to)
+	data.Deals = []ReportDeal{}
+	err = scopedTransactions(q).
+		Select(`d.id AS deal_id, d.title AS title, d.merchant_id AS merchant_id, COUNT(*) AS orders,
+			COALESCE(SUM(t.quantity), 0) AS units, COALESCE(SUM(t.amount), 0) AS revenue`).
+		Where("t.status = ?", models.TransactionStatusCompleted).
+		Where(where, args...).
+		Group("d.id, d.title, d.merchant_id").
+		Order("revenue DESC, d.id ASC").
+		Scan(&data.Deals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Refunds count when they happened, not when the purchase was made
+	var refunds struct {
+		Count  int64
+		Amount float64
+	}
+	refundWhere, refundArgs := timeRange("t.updated_at", from, to)
+	err = scopedTransactions(q).
+		Select("COUNT(*) AS count, COALESCE(SUM(t.amount), 0) AS amount").
+		Where("t.status = ?", models.TransactionStatusRefunded).
+		Where(refundWhere, refundArgs...).
+		Scan(&refunds).Error
+	if err != nil {
+		return nil, err
+	}
+	data.Refunds = refunds.Count
+	data.RefundAmount = refunds.Amount
+
+	scopedTransactions(q).
+		Where("t.status = ?", models.TransactionStatusFailed).
+		Where(where, args...).
+		Count(&data.FailedPayments)
+
+	pending := config.DB.Model(&models.Deal{}).Where("status = ?", "pending")
+	if merchantID != 0 {
+		pending = pending.Where("merchant_id = ?", merchantID)
+	}
+	pending.Count(&data.PendingDeals)
+
+	if merchantID != 0 {
+		config.DB.Model(&models.Deal{}).
+			Where("status = ? AND merchant_id = ?", "pending", merchantID).
+			Order("created_at ASC").
+			Limit(10).
+			Pluck("title", &data.PendingDealTitles)
+	} else {
+		config.DB.Model(&models.MerchantApplication{}).
+			Where("status = ?", "pending").
+			Count(&data.PendingApplications)
+
+		userWhere, userArgs := timeRange("created_at", from, to)
+		config.DB.Model(&models.User{}).Where(userWhere, userArgs...).Count(&data.NewUsers)
+	}
+
+	return data, nil
+}
+
+// CSV is the per-deal breakdown attached to the report email
+func (r *ReportData) CSV() []byte {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := []string{"deal_id", "deal_title", "orders", "units", "revenue"}
+	if r.MerchantID == 0 {
+		header = []string{"deal_id", "deal_title", "merchant_id", "orders", "units", "revenue"}
+	}
+	writer.Write(header)
+
+	for _, deal := range r.Deals {
+		row := []string{strconv.FormatUint(uint64(deal.DealID), 10), CSVCell(deal.Title)}
+		if r.MerchantID == 0 {
+			row = append(row, strconv.FormatUint(uint64(deal.MerchantID), 10))
+		}
+		row = append(row,
+			strconv.FormatInt(deal.Orders, 10),
+			strconv.FormatInt(deal.Units, 10),
+			strconv.FormatFloat(deal.Revenue, 'f', -1, 64))
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	return buf.Bytes()
+}
+
+// CSVCell stops spreadsheet apps from running user-supplied text, such as a
+// deal title or user agent, as a formula
+func CSVCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ReportScheduler sends subscribed reports when their schedule falls due
+type ReportScheduler struct {
+	email        *EmailService
+	pollInterval time.Duration
+}
+
+// Reports is the shared scheduler, created and started from main once the
+// environment is loaded
+var Reports *ReportScheduler
+
+func NewReportScheduler(email *EmailService) *ReportScheduler {
+	return &ReportScheduler{email: email, pollInterval: time.Minute}
+}
+
+// Start launches the scheduler loop
+func (s *ReportScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.runDue()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *ReportScheduler) runDue() {
+	now := time.Now()
+
+	var due []models.ReportSubscription
+	if err := config.DB.Where("enabled = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").
+		Find(&due).Error; err != nil {
+		fmt.Printf("❌ Failed to read due reports: %v\n", err)
+		return
+	}
+
+	for _, subscription := range due {
+		// Move the subscription to its next run first, which also claims
+		// this run against another instance polling at the same time
+		var next *time.Time
+		if schedule, err := ParseCron(subscription.Schedule); err == nil {
+			if t := schedule.Next(now); !t.IsZero() {
+				next = &t
+			}
+		}
+		claim := config.DB.Model(&models.ReportSubscription{}).
+			Where("id = ? AND enabled = ? AND next_run_at <= ?", subscription.ID, true, now).
+			Update("next_run_at", next)
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		s.Run(subscription, models.ReportTriggerSchedule)
+	}
+}
+
+// NextReportRun is when a schedule next fires after now, nil if it never does
+func NextReportRun(schedule string) (*time.Time, error) {
+	parsed, err := ParseCron(schedule)
+	if err != nil {
+		return nil, err
+	}
+	next := parsed.Next(time.Now())
+	if next.IsZero() {
+		return nil, fmt.Errorf("schedule %q never runs", schedule)
+	}
+	return &next, nil
+}
+
+// Run builds the report for the period since the last scheduled run and
+// emails it, recording the attempt. Manual runs don't move the period on, so
+// the next scheduled report still covers everything since the last one.
+func (s *ReportScheduler) Run(subscription models.ReportSubscription, trigger string) models.ReportRun {
+	started := time.Now()
+	definition, _ := models.GetReportDefinition(subscription.Report)
+
+	run := models.ReportRun{
+		SubscriptionID: subscription.ID,
+		UserID:         subscription.UserID,
+		Report:         subscription.Report,
+		Trigger:        trigger,
+		PeriodEnd:      started,
+		PeriodStart:    started.Add(-definition.DefaultPeriod),
+	}
+	if subscription.LastRunAt != nil && started.Sub(*subscription.LastRunAt) <= maxReportPeriod {
+		run.PeriodStart = *subscription.LastRunAt
+	}
+
+	err := s.deliver(subscription, run)
+	switch {
+	case err == ErrReportNotAvailable:
+		run.Status = models.ReportRunSkipped
+		run.Error = err.Error()
+		models.DisableReportSubscription(subscription.UserID, subscription.Report)
+		fmt.Printf("⚠️ Report %s no longer available to user %d, subscription disabled\n", subscription.Report, subscription.UserID)
+	case err != nil:
+		run.Status = models.ReportRunFailed
+		run.Error = err.Error()
+		fmt.Printf("❌ Report %s for user %d failed: %v\n", subscription.Report, subscription.UserID, err)
+	default:
+		run.Status = models.ReportRunSent
+		if trigger == models.ReportTriggerSchedule {
+			config.DB.Model(&models.ReportSubscription{}).Where("id = ?", subscription.ID).Update("last_run_at", run.PeriodEnd)
+		}
+		fmt.Printf("✅ Report %s sent to user %d\n", subscription.Report, subscription.UserID)
+	}
+
+	run.DurationMs = time.Since(started).Milliseconds()
+	if err := config.DB.Create(&run).Error; err != nil {
+		fmt.Printf("❌ Failed to record report run: %v\n", err)
+	}
+	return run
+}
+
+func (s *ReportScheduler) deliver(subscription models.ReportSubscription, run models.ReportRun) error {
+	if s.email.Host == "" {
+		return errors.New("email service not configured")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, subscription.UserID).Error; err != nil || user.Status == "suspended" {
+		return ErrReportNotAvailable
+	}
+
+	merchantID, err := ReportScope(user.ID, subscription.Report)
+	if err != nil {
+		return err
+	}
+
+	data, err := BuildReport(subscription.Report, merchantID, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		return err
+	}
+
+	unsubscribeURL := UnsubscribeURL(user.ID, models.ReportUnsubscribeType(subscription.Report), models.NotificationChannelEmail)
+	return s.email.WithLocale(user.Locale).SendTemplate(user.Email, "report", EmailData{
+		"Name":   user.FirstName,
+		"Report": data,
+	}, unsubscribeURL, EmailAttachment{
+		Filename:    fmt.Sprintf("snapadeal-%s-%s.csv", strings.ReplaceAll(subscription.Report, "_", "-"), run.PeriodEnd.Format("20060102")),
+		ContentType: "text/csv",
+		Data:        data.CSV(),
+	})
+}
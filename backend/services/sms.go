@@ -0,0 +1,32 @@
+package services
+
+import (
+	"fmt"
+	"os"
+)
+
+// SMSProvider sends a text message to a phone number
+type SMSProvider interface {
+	Send(to, message string) error
+}
+
+// LogSMSProvider is the local fake: it only logs the message. Used until a
+// real gateway is configured, and in development.
+type LogSMSProvider struct{}
+
+func (LogSMSProvider) Send(to, message string) error {
+	fmt.Printf("📱 [SMS to %s] %s\n", to, message)
+	return nil
+}
+
+// NewSMSProvider returns the provider selected by SMS_PROVIDER. Only the
+// log-only provider exists today; unknown values fall back to it.
+func NewSMSProvider() SMSProvider {
+	switch os.Getenv("SMS_PROVIDER") {
+	case "", "log":
+		return LogSMSProvider{}
+	default:
+		fmt.Printf("⚠️ Unknown SMS_PROVIDER %q - falling back to log-only SMS\n", os.Getenv("SMS_PROVIDER"))
+		return LogSMSProvider{}
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrStorageNotFound is returned when an object doesn't exist
+var ErrStorageNotFound = errors.New("object not found")
+
+// StorageObject describes a stored object
+type StorageObject struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Storage holds uploaded media. Keys are slash-separated paths such as
+// "images/ab12cd.jpg", the same path the file is served under /uploads.
+type Storage interface {
+	Put(key string, data io.Reader, size int64, contentType string) error
+	// Get opens an object for reading; the caller closes it. Readers that
+	// also implement io.Seeker support range requests.
+	Get(key string) (io.ReadCloser, *StorageObject, error)
+	Stat(key string) (*StorageObject, error)
+	Delete(key string) error
+	// List returns every object whose key starts with prefix
+	List(prefix string) ([]StorageObject, error)
+	// SignedURL is a time-limited URL the object can be fetched from
+	// without going through the API
+	SignedURL(key string, expires time.Duration) (string, error)
+}
+
+// Store is the media storage, created from main once the environment is
+// loaded. See NewStorage.
+var Store Storage
+
+// NewStorage builds the driver named by STORAGE_DRIVER: "local" (default)
+// or "s3" for S3-compatible object storage such as AWS S3 or MinIO
+func NewStorage() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		if os.Getenv("STORAGE_SERVE_REDIRECT") == "true" {
+			fmt.Println("⚠️ STORAGE_SERVE_REDIRECT only applies to object storage; local files are streamed")
+		}
+		return NewLocalStorage(dir), nil
+	case "s3":
+		return NewS3StorageFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+// CleanStorageKey normalises a requested key, rejecting anything that would
+// escape the storage root
+func CleanStorageKey(key string) (string, error) {
+	if strings.Contains(key, "..") || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return cleaned, nil
+}
+
+// LocalStorage keeps objects as files under a directory. Only suitable for a
+// single API instance.
+type LocalStorage struct {
+	Dir string
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{Dir: dir}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes via a temporary file and rename, so a half-written object is
+// never served
+func (s *LocalStorage) Put(key string, data io.Reader, size int64, contentType string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadCloser, *StorageObject, error) {
+	object, err := s.Stat(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, _ := s.path(key)
+	file, err := os.Open(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrStorageNotFound
+		}
+		return nil, nil, err
+	}
+	return file, object, nil
+}
+
+func (s *LocalStorage) Stat(key string) (*StorageObject, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(target)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, ErrStorageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &StorageObject{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  mime.TypeByExtension(filepath.Ext(target)),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory tree under prefix, skipping temporary files from
+// writes in progress
+func (s *LocalStorage) List(prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	err := filepath.WalkDir(s.Dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == s.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.Dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StorageObject{
+			Key:          key,
+			Size:         info.Size(),
+			ContentType:  mime.TypeByExtension(filepath.Ext(file)),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	return objects, err
+}
+
+// SignedURL returns the public /uploads URL: local files are served by the
+// API to anyone, so there is nothing to sign
+func (s *LocalStorage) SignedURL(key string, expires time.Duration) (string, error) {
+	cleaned, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	return "/uploads/" + cleaned, nil
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Storage stores objects in an S3-compatible bucket (AWS S3, MinIO,
+// DigitalOcean Spaces, ...). Requests are signed with AWS Signature
+// Version 4.
+type S3Storage struct {
+	Endpoint  *url.URL // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // bucket in the path rather than the host name, as MinIO expects
+	client    *http.Client
+}
+
+// NewS3StorageFromEnv reads S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY,
+// S3_SECRET_KEY and S3_PATH_STYLE
+func NewS3StorageFromEnv() (*S3Storage, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	pathStyle, _ := strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
+	return NewS3Storage(endpoint, region, os.Getenv("S3_BUCKET"), os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), pathStyle)
+}
+
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) (*S3Storage, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3 storage needs S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+	}
+
+	return &S3Storage{
+		Endpoint:  parsed,
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: pathStyle,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Put uploads the object in a single request. Media uploads are capped well
+// below the 5 GB single-PUT limit, so multipart upload isn't needed.
+func (s *S3Storage) Put(key string, data io.Reader, size int64, contentType string) error {
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.doObject(http.MethodPut, key, body, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s.checkResponse(resp, key)
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, *StorageObject, error) {
+	resp, err := s.doObject(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkResponse(resp, key); err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	return resp.Body, s.objectFromResponse(key, resp), nil
+}
+
+func (s *S3Storage) Stat(key string) (*StorageObject, error) {
+	resp, err := s.doObject(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := s.checkResponse(resp, key); err != nil {
+		return nil, err
+	}
+	return s.objectFromResponse(key, resp), nil
+}
+
+// Delete removes the object; deleting a missing object is not an error
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.doObject(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := s.checkResponse(resp, key); err != nil && err != ErrStorageNotFound {
+		return err
+	}
+	return nil
+}
+
+// SignedURL presigns a GET for the object, valid for up to 7 days
+func (s *S3Storage) SignedURL(key string, expires time.Duration) (string, error) {
+	return s.presign(key, expires, time.Now().UTC())
+}
+
+func (s *S3Storage) presign(key string, expires time.Duration, now time.Time) (string, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return "", fmt.Errorf("signed URL expiry must be between 1s and 7 days")
+	}
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	objectURL.RawQuery = canonicalQuery(query)
+
+	headers := http.Header{}
+	headers.Set("Host", objectURL.Host)
+	signature := s.signature(http.MethodGet, objectURL, headers, "UNSIGNED-PAYLOAD", now)
+
+	return objectURL.String() + "&X-Amz-Signature=" + signature, nil
+}
+
+// List pages through ListObjectsV2, 1000 keys at a time
+func (s *S3Storage) List(prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	continuation := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		}
+
+		listURL := s.bucketURL("")
+		listURL.RawQuery = canonicalQuery(query)
+
+		resp, err := s.do(http.MethodGet, listURL, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkResponse(resp, prefix); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("S3 list %s: %w", prefix, err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, StorageObject{
+				Key:          content.Key,
+				Size:         content.Size,
+				ContentType:  mime.TypeByExtension(path.Ext(content.Key)),
+				LastModified: content.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		continuation = result.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	cleaned, err := CleanStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.bucketURL(cleaned), nil
+}
+
+// bucketURL addresses a key, or the bucket itself when key is empty
+func (s *S3Storage) bucketURL(key string) *url.URL {
+	bucketURL := *s.Endpoint
+	basePath := strings.TrimSuffix(bucketURL.Path, "/")
+	if s.PathStyle {
+		bucketURL.Path = basePath + "/" + s.Bucket + "/" + key
+	} else {
+		bucketURL.Host = s.Bucket + "." + bucketURL.Host
+		bucketURL.Path = basePath + "/" + key
+	}
+	bucketURL.RawPath = uriEncode(bucketURL.Path, false)
+	return &bucketURL
+}
+
+func (s *S3Storage) doObject(method, key string, body []byte, headers http.Header) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(method, objectURL, body, headers)
+}
+
+func (s *S3Storage) do(method string, objectURL *url.URL, body []byte, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	now := time.Now().UTC()
+	payloadHash := sha256Hex(body)
+	req.Header.Set("Host", objectURL.Host)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := signedHeaderNames(req.Header)
+	signature := s.signature(method, objectURL, req.Header, payloadHash, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, s.scope(now), strings.Join(signedHeaders, ";"), signature))
+	req.Header.Del("Host") // net/http sends req.Host
+	req.ContentLength = int64(len(body))
+
+	return s.client.Do(req)
+}
+
+func (s *S3Storage) checkResponse(resp *http.Response, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrStorageNotFound
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 %s %s: %s %s", resp.Request.Method, key, resp.Status, strings.TrimSpace(string(message)))
+}
+
+func (s *S3Storage) objectFromResponse(key string, resp *http.Response) *StorageObject {
+	object := &StorageObject{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		object.LastModified = modified
+	}
+	return object
+}
+
+func (s *S3Storage) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature computes the SigV4 signature over the canonical request
+func (s *S3Storage) signature(method string, u *url.URL, headers http.Header, payloadHash string, now time.Time) string {
+	names := signedHeaderNames(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers.Get(name)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(u.Path, false),
+		canonicalQuery(u.Query()),
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		s.scope(now),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signedHeaderNames lists the lower-cased header names to sign: Host,
+// Content-Type and the x-amz-* headers
+func signedHeaderNames(headers http.Header) []string {
+	var names []string
+	for name := range headers {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range values[key] {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, as
+// SigV4 requires; slashes are kept in paths
+func uriEncode(value string, encodeSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe link")
+
+// UnsubscribeToken signs the user, notification type and channel so an email
+// link can switch that one preference off without logging in. It is
+// deliberately not a JWT so it can never be used as a session token.
+func UnsubscribeToken(userID uint, notificationType, channel string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s:%s", userID, notificationType, channel)))
+	return payload + "." + unsubscribeSignature(payload)
+}
+
+// ParseUnsubscribeToken verifies a token from UnsubscribeToken
+func ParseUnsubscribeToken(token string) (uint, string, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(unsubscribeSignature(parts[0]))) {
+		return 0, "", "", ErrInvalidUnsubscribeToken
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, "", "", ErrInvalidUnsubscribeToken
+	}
+
+	fields := strings.Split(string(decoded), ":")
+	if len(fields) != 3 {
+		return 0, "", "", ErrInvalidUnsubscribeToken
+	}
+	userID, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, "", "", ErrInvalidUnsubscribeToken
+	}
+
+	return uint(userID), fields[1], fields[2], nil
+}
+
+// UnsubscribeURL is the one-click link placed in notification emails
+func UnsubscribeURL(userID uint, notificationType, channel string) string {
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = "http://localhost:8080"
+	}
+	return apiURL + "/api/v1/notifications/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(userID, notificationType, channel))
+}
+
+func unsubscribeSignature(payload string) string {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "your-secret-key"
+	}
+
+	mac := hmac.New(sha256.New, []byte("unsubscribe:"+secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
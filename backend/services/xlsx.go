@@ -0,0 +1,192 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/flate"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XLSXWriter streams a single-sheet Excel workbook - enough for exports
+// without pulling in a spreadsheet library. Rows are written straight into
+// the sheet's zip entry, so memory use doesn't grow with the row count.
+// Strings are stored inline rather than in a shared strings table for the
+// same reason.
+type XLSXWriter struct {
+	zip     *zip.Writer
+	deflate *flate.Writer // compressor of the sheet entry, created last
+	sheet   *bufio.Writer
+	rows    int
+}
+
+var xlsxStaticParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`},
+	// Style 1 is bold, for the header row
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`},
+}
+
+// NewXLSXWriter starts a workbook with one sheet called sheetName
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	x := &XLSXWriter{zip: zip.NewWriter(w)}
+	z := x.zip
+
+	// Keep hold of the compressor, as zip.Writer.Flush doesn't flush the
+	// data it holds back
+	z.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		fw, err := flate.NewWriter(out, flate.DefaultCompression)
+		x.deflate = fw
+		return fw, err
+	})
+
+	for _, part := range xlsxStaticParts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := z.Create("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(f, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`, xmlEscape(sheetName))
+
+	// The sheet goes last so rows can stream into it until Close
+	f, err = z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x.sheet = bufio.NewWriter(f)
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	return x, nil
+}
+
+// WriteHeader writes a bold row of column titles
+func (x *XLSXWriter) WriteHeader(titles []string) error {
+	cells := make([]interface{}, len(titles))
+	for i, title := range titles {
+		cells[i] = title
+	}
+	return x.writeRow(cells, 1)
+}
+
+// WriteRow writes one row. Numbers become numeric cells, times are written
+// as RFC 3339 UTC text and nil leaves the cell empty.
+func (x *XLSXWriter) WriteRow(cells []interface{}) error {
+	return x.writeRow(cells, 0)
+}
+
+func (x *XLSXWriter) writeRow(cells []interface{}, style int) error {
+	x.rows++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+
+	styleAttr := ""
+	if style != 0 {
+		styleAttr = fmt.Sprintf(` s="%d"`, style)
+	}
+
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(x.rows)
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case int:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case int64:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case uint:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case float64:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			value := 0
+			if v {
+				value = 1
+			}
+			fmt.Fprintf(x.sheet, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, styleAttr, value)
+		case time.Time:
+			x.writeString(ref, styleAttr, v.UTC().Format(time.RFC3339))
+		default:
+			x.writeString(ref, styleAttr, fmt.Sprint(v))
+		}
+	}
+
+	_, err := x.sheet.WriteString("</row>")
+	return err
+}
+
+func (x *XLSXWriter) writeString(ref, styleAttr, value string) {
+	fmt.Fprintf(x.sheet, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, xmlEscape(value))
+}
+
+// Flush pushes the rows written so far out to the underlying writer
+func (x *XLSXWriter) Flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	if err := x.deflate.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Flush()
+}
+
+// Close finishes the sheet and the zip archive
+func (x *XLSXWriter) Close() error {
+	x.sheet.WriteString("</sheetData></worksheet>")
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+// xlsxColumn turns a zero-based column index into its letters: A, B, ... AA
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
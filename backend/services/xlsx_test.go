@@ -0,0 +1,207 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := xlsxColumn(tt.index); got != tt.want {
+			t.Errorf("xlsxColumn(%d) = %s, want %s", tt.index, got, tt.want)
+		}
+	}
+}
+
+type xlsxTestSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSXPart(t *testing.T, archive *zip.Reader, name string) []byte {
+	t.Helper()
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	t.Fatalf("workbook has no %s", name)
+	return nil
+}
+
+func TestXLSXWriterCells(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := NewXLSXWriter(&buf, "Sales & <Refunds>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.WriteHeader([]string{"Deal", "Qty", "Amount", "Paid", "When", "Note"}); err != nil {
+		t.Fatal(err)
+	}
+	row := []interface{}{"Pizza & <Chips>", 3, 45000.5, true, time.Date(2026, 10, 19, 10, 30, 0, 0, time.FixedZone("EAT", 3*60*60)), nil}
+	if err := x.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.WriteRow([]interface{}{"  padded  ", int64(7), uint(8), false}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("workbook is not a valid zip: %v", err)
+	}
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/workbook.xml"} {
+		if err := xml.Unmarshal(readXLSXPart(t, archive, part), new(struct{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", part, err)
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(readXLSXPart(t, archive, "xl/workbook.xml"), &workbook); err != nil {
+		t.Fatal(err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != "Sales & <Refunds>" {
+		t.Fatalf("sheets = %+v", workbook.Sheets)
+	}
+
+	var sheet xlsxTestSheet
+	if err := xml.Unmarshal(readXLSXPart(t, archive, "xl/worksheets/sheet1.xml"), &sheet); err != nil {
+		t.Fatalf("sheet is not well-formed XML: %v", err)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("sheet has %d rows, want 3", len(sheet.Rows))
+	}
+
+	header := sheet.Rows[0]
+	if header.R != 1 || len(header.Cells) != 6 || header.Cells[5].Ref != "F1" || header.Cells[5].Inline != "Note" {
+		t.Fatalf("header row = %+v", header)
+	}
+	for _, cell := range header.Cells {
+		if cell.Style != "1" {
+			t.Fatalf("header cell %s is not bold", cell.Ref)
+		}
+	}
+
+	type cell struct{ ref, kind, value string }
+	tests := []struct {
+		row   int
+		cells []cell
+	}{
+		{1, []cell{
+			{"A2", "inlineStr", "Pizza & <Chips>"},
+			{"B2", "", "3"},
+			{"C2", "", "45000.5"},
+			{"D2", "b", "1"},
+			{"E2", "inlineStr", "2026-10-19T07:30:00Z"},
+		}},
+		{2, []cell{
+			{"A3", "inlineStr", "  padded  "},
+			{"B3", "", "7"},
+			{"C3", "", "8"},
+			{"D3", "b", "0"},
+		}},
+	}
+	for _, tt := range tests {
+		got := sheet.Rows[tt.row].Cells
+		if len(got) != len(tt.cells) {
+			t.Fatalf("row %d has %d cells, want %d (nil cells are left out)", tt.row+1, len(got), len(tt.cells))
+		}
+		for i, want := range tt.cells {
+			value := got[i].Value
+			if got[i].Type == "inlineStr" {
+				value = got[i].Inline
+			}
+			if got[i].Ref != want.ref || got[i].Type != want.kind || value != want.value || got[i].Style != "" {
+				t.Errorf("cell %s = {%s %q %q style %q}, want {%s %q %q}", want.ref, got[i].Ref, got[i].Type, value, got[i].Style, want.ref, want.kind, want.value)
+			}
+		}
+	}
+}
+
+func TestXLSXWriterFlushStreamsRows(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := NewXLSXWriter(&buf, "Export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.WriteHeader([]string{"ID"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	flushed := buf.Len()
+	if flushed == 0 {
+		t.Fatal("Flush wrote nothing")
+	}
+
+	for i := 0; i < 1000; i++ {
+		if err := x.WriteRow([]interface{}{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() <= flushed {
+		t.Fatal("rows were not streamed out on Flush")
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet xlsxTestSheet
+	if err := xml.Unmarshal(readXLSXPart(t, archive, "xl/worksheets/sheet1.xml"), &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 1001 || sheet.Rows[1000].Cells[0].Ref != "A1001" || sheet.Rows[1000].Cells[0].Value != "999" {
+		t.Fatalf("sheet has %d rows, last %+v", len(sheet.Rows), sheet.Rows[len(sheet.Rows)-1])
+	}
+}
@@ -99,9 +99,9 @@ echo "   🏪 Merchant Portal:  http://localhost:3001"
 echo "   ⚙️ Admin Portal:     http://localhost:3002"
 echo "   📡 Backend API:      http://localhost:8080"
 echo ""
-echo "🔐 Default Admin Account:"
-echo "   📧 Email:     admin@snapadeal.com"
-echo "   🔑 Password:  admin123"
+echo "🔐 First Admin Account:"
+echo "   Set ADMIN_BOOTSTRAP_EMAIL / ADMIN_BOOTSTRAP_PASSWORD in backend/.env"
+echo "   or run: cd backend && go run main.go create-admin -email <email> -phone <phone>"
 echo ""
 echo "💾 Database Information:"
 echo "   📁 Type:      SQLite"